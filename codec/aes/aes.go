@@ -10,17 +10,56 @@ import (
 	"github.com/asdine/storm/v3/codec"
 )
 
-const name = "aes-"
+const (
+	name      = "aes-"
+	boundName = "bound-"
+)
+
+// Options are used to customize the AES codecs.
+type Options struct {
+	bindLocation bool
+}
+
+// BindLocation authenticates the encrypted data with the location where Storm stores it,
+// the path of its bucket and its key. Data moved to another location fails to decrypt.
+// Data encrypted without this option can't be decrypted with it, and vice versa.
+func BindLocation() func(*Options) {
+	return func(opts *Options) {
+		opts.bindLocation = true
+	}
+}
 
 // AES is an Codec that encrypts the data and uses a sub marshaller to actually serialize the data
 type AES struct {
 	subMarshaller codec.MarshalUnmarshaler
 	aesGCM        cipher.AEAD
+	bindLocation  bool
+
+	// additional data authenticated with the encrypted data
+	location []byte
 }
 
 // NewAES creates a new AES encryption marshaller. It takes a sub marshaller to actually serialize the data and a 16/32 bytes private key to
 // encrypt all data using AES in GCM block mode.
-func NewAES(subMarshaller codec.MarshalUnmarshaler, key []byte) (*AES, error) {
+func NewAES(subMarshaller codec.MarshalUnmarshaler, key []byte, options ...func(*Options)) (*AES, error) {
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts Options
+	for _, option := range options {
+		option(&opts)
+	}
+
+	return &AES{
+		subMarshaller: subMarshaller,
+		aesGCM:        aesGCM,
+		bindLocation:  opts.bindLocation,
+	}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
 	aesCipher, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("error creating AES cipher: %w", err)
@@ -31,18 +70,30 @@ func NewAES(subMarshaller codec.MarshalUnmarshaler, key []byte) (*AES, error) {
 		return nil, fmt.Errorf("error creating GCM block mode: %w", err)
 	}
 
-	return &AES{
-		subMarshaller: subMarshaller,
-		aesGCM:        aesGCM,
-	}, nil
+	return aesGCM, nil
 }
 
 // Name returns a compound of the inner marshaller prefixed by 'aes-'
 func (c *AES) Name() string {
 	// Return a dynamic name, because the marshalling will also fail if the inner marshalling changes.
+	if c.bindLocation {
+		return name + boundName + c.subMarshaller.Name()
+	}
 	return name + c.subMarshaller.Name()
 }
 
+// Bind returns a codec encrypting the data stored at the given location if the BindLocation option is set,
+// or the codec itself otherwise.
+func (c *AES) Bind(location []byte) codec.MarshalUnmarshaler {
+	if !c.bindLocation {
+		return c
+	}
+
+	bound := *c
+	bound.location = location
+	return &bound
+}
+
 // Marshal marshals the given data object to an encrypted byte array
 func (c *AES) Marshal(v interface{}) ([]byte, error) {
 	data, err := c.subMarshaller.Marshal(v)
@@ -50,26 +101,57 @@ func (c *AES) Marshal(v interface{}) ([]byte, error) {
 		return nil, err
 	}
 
+	return c.encrypt(data)
+}
+
+// Unmarshal unmarshals the given encrypted byte array to the given type
+func (c *AES) Unmarshal(data []byte, v interface{}) error {
+	decrypted, err := c.decrypt(data)
+	if err != nil {
+		return err
+	}
+
+	return c.subMarshaller.Unmarshal(decrypted, v)
+}
+
+// Rebind decrypts the given data stored at the location of the codec and encrypts it
+// for the given location. It returns nil if the BindLocation option is not set.
+func (c *AES) Rebind(data []byte, location []byte) ([]byte, error) {
+	if !c.bindLocation {
+		return nil, nil
+	}
+
+	decrypted, err := c.decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+
+	bound := *c
+	bound.location = location
+	return bound.encrypt(decrypted)
+}
+
+// encrypt returns the nonce followed by the encrypted data.
+func (c *AES) encrypt(data []byte) ([]byte, error) {
 	nonce := make([]byte, c.aesGCM.NonceSize())
-	_, err = io.ReadFull(rand.Reader, nonce)
+	_, err := io.ReadFull(rand.Reader, nonce)
 	if err != nil {
 		return nil, fmt.Errorf("error reading random nonce: %w", err)
 	}
 
-	return c.aesGCM.Seal(nonce, nonce, data, nil), nil
+	return c.aesGCM.Seal(nonce, nonce, data, c.location), nil
 }
 
-// Unmarshal unmarshals the given encrypted byte array to the given type
-func (c *AES) Unmarshal(data []byte, v interface{}) error {
+func (c *AES) decrypt(data []byte) ([]byte, error) {
 	nonceSize := c.aesGCM.NonceSize()
 	if len(data) < nonceSize {
-		return fmt.Errorf("not enough data for aes decryption (%d < %d)", len(data), nonceSize)
+		return nil, fmt.Errorf("not enough data for aes decryption (%d < %d)", len(data), nonceSize)
 	}
 
-	decrypted, err := c.aesGCM.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	decrypted, err := c.aesGCM.Open(nil, data[:nonceSize], data[nonceSize:], c.location)
 	if err != nil {
-		return fmt.Errorf("error decrypting data: %w", err)
+		return nil, fmt.Errorf("error decrypting data: %w", err)
 	}
 
-	return c.subMarshaller.Unmarshal(decrypted, v)
+	return decrypted, nil
 }
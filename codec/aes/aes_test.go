@@ -18,3 +18,38 @@ func TestAES(t *testing.T) {
 
 	internal.RoundtripTester(t, aes)
 }
+
+func TestAESBindLocation(t *testing.T) {
+	unbound, err := NewAES(json.Codec, testKey)
+	require.NoError(t, err)
+	require.Equal(t, unbound, unbound.Bind([]byte("a")))
+
+	c, err := NewAES(json.Codec, testKey, BindLocation())
+	require.NoError(t, err)
+	require.Equal(t, "aes-bound-json", c.Name())
+	internal.RoundtripTester(t, c.Bind([]byte("a")))
+
+	encrypted, err := c.Bind([]byte("a")).Marshal("value")
+	require.NoError(t, err)
+
+	var value string
+	require.NoError(t, c.Bind([]byte("a")).Unmarshal(encrypted, &value))
+	require.Equal(t, "value", value)
+	require.Error(t, c.Bind([]byte("b")).Unmarshal(encrypted, &value))
+	require.Error(t, c.Unmarshal(encrypted, &value))
+	require.Error(t, unbound.Unmarshal(encrypted, &value))
+
+	// data is moved to another location
+	moved, err := c.Bind([]byte("a")).(*AES).Rebind(encrypted, []byte("b"))
+	require.NoError(t, err)
+	require.NoError(t, c.Bind([]byte("b")).Unmarshal(moved, &value))
+	require.Equal(t, "value", value)
+	require.Error(t, c.Bind([]byte("a")).Unmarshal(moved, &value))
+
+	_, err = c.Bind([]byte("b")).(*AES).Rebind(encrypted, []byte("c"))
+	require.Error(t, err)
+
+	moved, err = unbound.Rebind(encrypted, []byte("b"))
+	require.NoError(t, err)
+	require.Nil(t, moved)
+}
@@ -0,0 +1,205 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/asdine/storm/v3/codec"
+)
+
+const keyringName = "aes-keyring-"
+
+// version of the format of the data encrypted by a Keyring
+const keyringVersion = 1
+
+// length of the version and the key ID prefixing the encrypted data
+const headerSize = 5
+
+// ErrUnknownKey is returned when decrypting data encrypted with a key missing from the keyring.
+var ErrUnknownKey = errors.New("data encrypted with an unknown key")
+
+// Keyring is an AES codec able to use several keys. Data is encrypted with the active key
+// and prefixed with its ID, so that it can be decrypted with any key of the keyring.
+// Keys can be rotated by adding a new key and making it the active one,
+// the data encrypted with the previous keys can then be re-encrypted using ReEncrypt.
+type Keyring struct {
+	subMarshaller codec.MarshalUnmarshaler
+	active        uint32
+	keys          map[uint32]cipher.AEAD
+	bindLocation  bool
+
+	// additional data authenticated with the encrypted data
+	location []byte
+}
+
+// NewKeyring creates a keyring encrypting the data with the key of the active ID.
+// It takes a sub marshaller to actually serialize the data and 16/32 bytes keys indexed by ID.
+func NewKeyring(subMarshaller codec.MarshalUnmarshaler, keys map[uint32][]byte, active uint32, options ...func(*Options)) (*Keyring, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %d: %w", active, ErrUnknownKey)
+	}
+
+	var opts Options
+	for _, option := range options {
+		option(&opts)
+	}
+
+	k := Keyring{
+		subMarshaller: subMarshaller,
+		active:        active,
+		keys:          make(map[uint32]cipher.AEAD, len(keys)),
+		bindLocation:  opts.bindLocation,
+	}
+
+	for id, key := range keys {
+		aesGCM, err := newGCM(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", id, err)
+		}
+		k.keys[id] = aesGCM
+	}
+
+	return &k, nil
+}
+
+// Name returns a compound of the inner marshaller prefixed by 'aes-keyring-'.
+// It doesn't depend on the keys, so that they can be rotated.
+func (k *Keyring) Name() string {
+	if k.bindLocation {
+		return keyringName + boundName + k.subMarshaller.Name()
+	}
+	return keyringName + k.subMarshaller.Name()
+}
+
+// Bind returns a keyring encrypting the data stored at the given location if the BindLocation option is set,
+// or the keyring itself otherwise.
+func (k *Keyring) Bind(location []byte) codec.MarshalUnmarshaler {
+	if !k.bindLocation {
+		return k
+	}
+
+	bound := *k
+	bound.location = location
+	return &bound
+}
+
+// ActiveKey returns the ID of the key used to encrypt the data.
+func (k *Keyring) ActiveKey() uint32 {
+	return k.active
+}
+
+// Marshal marshals the given data object to a byte array encrypted with the active key.
+func (k *Keyring) Marshal(v interface{}) ([]byte, error) {
+	data, err := k.subMarshaller.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.encrypt(data)
+}
+
+// Unmarshal unmarshals the given encrypted byte array to the given type.
+func (k *Keyring) Unmarshal(data []byte, v interface{}) error {
+	decrypted, err := k.decrypt(data)
+	if err != nil {
+		return err
+	}
+
+	return k.subMarshaller.Unmarshal(decrypted, v)
+}
+
+// KeyID returns the ID of the key used to encrypt the given data.
+func (k *Keyring) KeyID(data []byte) (uint32, error) {
+	if len(data) < headerSize || data[0] != keyringVersion {
+		return 0, errors.New("data not encrypted by an aes keyring")
+	}
+
+	return binary.BigEndian.Uint32(data[1:headerSize]), nil
+}
+
+// ReEncrypt encrypts the given data with the active key. It returns nil if the data
+// is already encrypted with the active key or if it wasn't encrypted by a keyring.
+func (k *Keyring) ReEncrypt(data []byte) ([]byte, error) {
+	id, err := k.KeyID(data)
+	if err != nil || id == k.active {
+		return nil, nil
+	}
+
+	decrypted, err := k.decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.encrypt(decrypted)
+}
+
+// Rebind decrypts the given data stored at the location of the keyring and encrypts it
+// with the active key for the given location. It returns nil if the BindLocation option is not set.
+func (k *Keyring) Rebind(data []byte, location []byte) ([]byte, error) {
+	if !k.bindLocation {
+		return nil, nil
+	}
+
+	decrypted, err := k.decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+
+	bound := *k
+	bound.location = location
+	return bound.encrypt(decrypted)
+}
+
+// encrypt returns the version, the ID of the active key, the nonce and the encrypted data.
+// The version and the key ID are authenticated with the data.
+func (k *Keyring) encrypt(data []byte) ([]byte, error) {
+	aesGCM := k.keys[k.active]
+
+	out := make([]byte, headerSize+aesGCM.NonceSize(), headerSize+aesGCM.NonceSize()+len(data)+aesGCM.Overhead())
+	out[0] = keyringVersion
+	binary.BigEndian.PutUint32(out[1:headerSize], k.active)
+
+	nonce := out[headerSize:]
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("error reading random nonce: %w", err)
+	}
+
+	return aesGCM.Seal(out, nonce, data, k.additionalData(out[:headerSize])), nil
+}
+
+func (k *Keyring) decrypt(data []byte) ([]byte, error) {
+	id, err := k.KeyID(data)
+	if err != nil {
+		return nil, err
+	}
+
+	aesGCM, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("key %d: %w", id, ErrUnknownKey)
+	}
+
+	nonceSize := aesGCM.NonceSize()
+	if len(data) < headerSize+nonceSize {
+		return nil, fmt.Errorf("not enough data for aes decryption (%d < %d)", len(data), headerSize+nonceSize)
+	}
+
+	nonce := data[headerSize : headerSize+nonceSize]
+	decrypted, err := aesGCM.Open(nil, nonce, data[headerSize+nonceSize:], k.additionalData(data[:headerSize]))
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data: %w", err)
+	}
+
+	return decrypted, nil
+}
+
+// additionalData returns the header of the encrypted data followed by its location.
+func (k *Keyring) additionalData(header []byte) []byte {
+	ad := make([]byte, 0, len(header)+len(k.location))
+	ad = append(ad, header...)
+	return append(ad, k.location...)
+}
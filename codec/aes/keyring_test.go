@@ -0,0 +1,103 @@
+package aes
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/asdine/storm/v3/codec/internal"
+	"github.com/asdine/storm/v3/codec/json"
+)
+
+var testKey2, _ = base64.StdEncoding.DecodeString("1Y1YUcOtnq3CZ4gR4hTgPQ==")
+
+func TestKeyring(t *testing.T) {
+	old, err := NewKeyring(json.Codec, map[uint32][]byte{1: testKey}, 1)
+	require.NoError(t, err)
+	internal.RoundtripTester(t, old)
+	require.Equal(t, "aes-keyring-json", old.Name())
+
+	encrypted, err := old.Marshal("value")
+	require.NoError(t, err)
+
+	id, err := old.KeyID(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), id)
+
+	// data encrypted with the previous keys can be decrypted
+	k, err := NewKeyring(json.Codec, map[uint32][]byte{1: testKey, 2: testKey2}, 2)
+	require.NoError(t, err)
+	require.Equal(t, old.Name(), k.Name())
+	require.Equal(t, uint32(2), k.ActiveKey())
+
+	var value string
+	require.NoError(t, k.Unmarshal(encrypted, &value))
+	require.Equal(t, "value", value)
+
+	reencrypted, err := k.ReEncrypt(encrypted)
+	require.NoError(t, err)
+	id, err = k.KeyID(reencrypted)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), id)
+	require.NoError(t, k.Unmarshal(reencrypted, &value))
+	require.Equal(t, "value", value)
+
+	// data encrypted with the active key or not encrypted is left as is
+	reencrypted, err = k.ReEncrypt(reencrypted)
+	require.NoError(t, err)
+	require.Nil(t, reencrypted)
+
+	reencrypted, err = k.ReEncrypt([]byte("value"))
+	require.NoError(t, err)
+	require.Nil(t, reencrypted)
+
+	// the key ID is authenticated
+	encrypted[4] = 2
+	require.Error(t, k.Unmarshal(encrypted, &value))
+
+	encrypted[4] = 3
+	err = k.Unmarshal(encrypted, &value)
+	require.True(t, errors.Is(err, ErrUnknownKey))
+
+	_, err = NewKeyring(json.Codec, map[uint32][]byte{1: testKey}, 2)
+	require.True(t, errors.Is(err, ErrUnknownKey))
+
+	_, err = NewKeyring(json.Codec, map[uint32][]byte{1: []byte("short")}, 1)
+	require.Error(t, err)
+}
+
+func TestKeyringBindLocation(t *testing.T) {
+	k, err := NewKeyring(json.Codec, map[uint32][]byte{1: testKey}, 1, BindLocation())
+	require.NoError(t, err)
+	require.Equal(t, "aes-keyring-bound-json", k.Name())
+	internal.RoundtripTester(t, k.Bind([]byte("a")))
+
+	encrypted, err := k.Bind([]byte("a")).Marshal("value")
+	require.NoError(t, err)
+
+	var value string
+	require.NoError(t, k.Bind([]byte("a")).Unmarshal(encrypted, &value))
+	require.Error(t, k.Bind([]byte("b")).Unmarshal(encrypted, &value))
+
+	// data is re-encrypted for the same location
+	k2, err := NewKeyring(json.Codec, map[uint32][]byte{1: testKey, 2: testKey2}, 2, BindLocation())
+	require.NoError(t, err)
+
+	_, err = k2.Bind([]byte("b")).(*Keyring).ReEncrypt(encrypted)
+	require.Error(t, err)
+
+	reencrypted, err := k2.Bind([]byte("a")).(*Keyring).ReEncrypt(encrypted)
+	require.NoError(t, err)
+	require.NoError(t, k2.Bind([]byte("a")).Unmarshal(reencrypted, &value))
+	require.Equal(t, "value", value)
+	require.Error(t, k2.Bind([]byte("b")).Unmarshal(reencrypted, &value))
+
+	// data is moved to another location
+	moved, err := k2.Bind([]byte("a")).(*Keyring).Rebind(encrypted, []byte("b"))
+	require.NoError(t, err)
+	require.NoError(t, k2.Bind([]byte("b")).Unmarshal(moved, &value))
+	require.Equal(t, "value", value)
+	require.Error(t, k2.Bind([]byte("a")).Unmarshal(moved, &value))
+}
@@ -9,3 +9,30 @@ type MarshalUnmarshaler interface {
 	// name of this codec
 	Name() string
 }
+
+// A PartialUnmarshaler is a codec able to decode an entity into a struct
+// declaring only some of its fields. The other fields are skipped.
+type PartialUnmarshaler interface {
+	UnmarshalPartial(b []byte, v interface{}) error
+}
+
+// A Binder is a codec able to authenticate the encoded data with the location where it is stored,
+// so that data moved to another location fails to decode.
+// Bind returns a codec encoding and decoding the data stored at the given location.
+type Binder interface {
+	Bind(location []byte) MarshalUnmarshaler
+}
+
+// A Rebinder is a codec bound to a location, able to move data to another location.
+// Rebind returns the given data, stored at the location of the codec, encoded for the given location.
+// It returns nil if the data doesn't depend on its location.
+type Rebinder interface {
+	Rebind(b []byte, location []byte) ([]byte, error)
+}
+
+// A ReEncrypter is a codec encrypting the data with one of several keys.
+// ReEncrypt returns the given data encrypted with the active key, or nil if it is
+// already encrypted with it or if it wasn't encrypted by the codec.
+type ReEncrypter interface {
+	ReEncrypt(b []byte) ([]byte, error)
+}
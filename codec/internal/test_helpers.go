@@ -39,6 +39,37 @@ func RoundtripTester(t *testing.T, c codec.MarshalUnmarshaler, vals ...interface
 	}
 }
 
+// PartialTester is a test helper to test a PartialUnmarshaler
+func PartialTester(t *testing.T, c codec.MarshalUnmarshaler) {
+	type record struct {
+		Name string
+		Age  int
+		Blob []byte
+	}
+	type partial struct {
+		Age int
+	}
+
+	encoded, err := c.Marshal(&record{Name: "test", Age: 10, Blob: []byte("blob")})
+	if err != nil {
+		t.Fatal("Encode error:", err)
+	}
+
+	pu, ok := c.(codec.PartialUnmarshaler)
+	if !ok {
+		t.Fatal("Codec is not a PartialUnmarshaler")
+	}
+
+	var to partial
+	err = pu.UnmarshalPartial(encoded, &to)
+	if err != nil {
+		t.Fatal("Decode error:", err)
+	}
+	if to.Age != 10 {
+		t.Fatalf("Partial decoding mismatch, expected 10, got %d", to.Age)
+	}
+}
+
 func init() {
 	gob.Register(&testStruct{})
 }
@@ -20,6 +20,11 @@ func (j jsonCodec) Unmarshal(b []byte, v interface{}) error {
 	return json.Unmarshal(b, v)
 }
 
+// UnmarshalPartial decodes the fields declared by v, unknown fields are skipped.
+func (j jsonCodec) UnmarshalPartial(b []byte, v interface{}) error {
+	return json.Unmarshal(b, v)
+}
+
 func (j jsonCodec) Name() string {
 	return name
 }
@@ -8,4 +8,5 @@ import (
 
 func TestJSON(t *testing.T) {
 	internal.RoundtripTester(t, Codec)
+	internal.PartialTester(t, Codec)
 }
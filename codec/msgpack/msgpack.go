@@ -20,6 +20,11 @@ func (m msgpackCodec) Unmarshal(b []byte, v interface{}) error {
 	return mp.Unmarshal(b, v)
 }
 
+// UnmarshalPartial decodes the fields declared by v, unknown fields are skipped.
+func (m msgpackCodec) UnmarshalPartial(b []byte, v interface{}) error {
+	return mp.Unmarshal(b, v)
+}
+
 func (m msgpackCodec) Name() string {
 	return name
 }
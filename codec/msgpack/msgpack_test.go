@@ -8,4 +8,5 @@ import (
 
 func TestMsgpack(t *testing.T) {
 	internal.RoundtripTester(t, Codec)
+	internal.PartialTester(t, Codec)
 }
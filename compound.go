@@ -0,0 +1,138 @@
+package storm
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/asdine/storm/v3/codec"
+)
+
+// compoundIndex is an index on the values of several fields, declared using
+// the index=Name and unique=Name tags.
+type compoundIndex struct {
+	Name string
+
+	// Kind of index, unique or index
+	Kind string
+
+	// Indexed fields, in declaration order
+	Fields []*fieldConfig
+}
+
+// value returns the encoded tuple of the fields of the index.
+// It returns nil if one of the fields is zero, records are not indexed in that case.
+func (ci *compoundIndex) value(c codec.MarshalUnmarshaler) ([]byte, error) {
+	var buf bytes.Buffer
+
+	for _, f := range ci.Fields {
+		if f.IsZero {
+			return nil, nil
+		}
+
+		raw, err := toIndexBytes(f.Value.Interface(), c)
+		if err != nil {
+			return nil, err
+		}
+
+		writeTupleElem(&buf, raw)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// compoundIndexByName returns the compound index of the given name declared by the type.
+// It returns nil if the type has a field with that name or declares no such index.
+func compoundIndexByName(typ reflect.Type, name string) *compoundIndex {
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if _, ok := typ.FieldByName(name); ok {
+		return nil
+	}
+
+	ref := reflect.New(typ)
+	cfg, err := extract(&ref)
+	if err != nil {
+		return nil
+	}
+
+	return cfg.CompoundIndexes[name]
+}
+
+// tuple encodes the given values the same way the fields of the index are encoded.
+// Values can be a slice or an array holding the values of the first fields of the index,
+// any other value is used as the value of the first field.
+func (ci *compoundIndex) tuple(value interface{}, c codec.MarshalUnmarshaler) ([]byte, error) {
+	values := tupleValues(value)
+	if len(values) == 0 || len(values) > len(ci.Fields) {
+		return nil, ErrIncompatibleValue
+	}
+
+	var buf bytes.Buffer
+	for i, v := range values {
+		raw, err := toFieldIndexBytes(v, ci.Fields[i], c)
+		if err != nil {
+			return nil, err
+		}
+
+		writeTupleElem(&buf, raw)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// prefix encodes the given string as the beginning of the first field of the index.
+func (ci *compoundIndex) prefix(prefix string) []byte {
+	var buf bytes.Buffer
+	escapeTupleElem(&buf, []byte(prefix))
+	return buf.Bytes()
+}
+
+// Each element of a tuple is escaped and followed by a terminator so that
+// tuples sort like their elements, from the first to the last.
+var (
+	tupleEscape     = []byte{0x00, 0xFF}
+	tupleTerminator = []byte{0x00, 0x01}
+)
+
+func writeTupleElem(buf *bytes.Buffer, raw []byte) {
+	escapeTupleElem(buf, raw)
+	buf.Write(tupleTerminator)
+}
+
+func escapeTupleElem(buf *bytes.Buffer, raw []byte) {
+	for _, b := range raw {
+		if b == 0x00 {
+			buf.Write(tupleEscape)
+		} else {
+			buf.WriteByte(b)
+		}
+	}
+}
+
+// tupleUpperBound returns the greatest key starting with the given tuple.
+func tupleUpperBound(tuple []byte) []byte {
+	bound := append([]byte(nil), tuple...)
+	bound[len(bound)-1]++
+	return bound
+}
+
+// tupleValues returns the list of values held by the given tuple.
+func tupleValues(value interface{}) []interface{} {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return nil
+	}
+
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return []interface{}{value}
+	}
+
+	values := make([]interface{}, v.Len())
+	for i := range values {
+		values[i] = v.Index(i).Interface()
+	}
+
+	return values
+}
@@ -0,0 +1,273 @@
+package storm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3/codec/json"
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+)
+
+type Account struct {
+	ID        int       `storm:"increment"`
+	TenantID  string    `storm:"index=TenantCreated,unique=TenantEmail,index=TenantName"`
+	Email     string    `storm:"unique=TenantEmail"`
+	Name      string    `storm:"index,index=TenantName"`
+	CreatedAt time.Time `storm:"index=TenantCreated"`
+}
+
+func TestExtractCompoundIndexes(t *testing.T) {
+	a := Account{ID: 1, TenantID: "acme", Email: "john@acme.com"}
+	r := reflect.ValueOf(&a)
+	infos, err := extract(&r)
+	require.NoError(t, err)
+	require.Len(t, infos.CompoundIndexes, 3)
+
+	ci := infos.CompoundIndexes["TenantCreated"]
+	require.Equal(t, tagIdx, ci.Kind)
+	require.Len(t, ci.Fields, 2)
+	require.Equal(t, "TenantID", ci.Fields[0].Name)
+	require.Equal(t, "CreatedAt", ci.Fields[1].Name)
+
+	require.Equal(t, tagUniqueIdx, infos.CompoundIndexes["TenantEmail"].Kind)
+
+	ci = infos.CompoundIndexes["TenantName"]
+	require.Len(t, ci.Fields, 2)
+	require.Equal(t, tagIdx, infos.Fields["Name"].Index)
+
+	// zero values are not indexed
+	value, err := infos.CompoundIndexes["TenantCreated"].value(json.Codec)
+	require.NoError(t, err)
+	require.Nil(t, value)
+
+	value, err = infos.CompoundIndexes["TenantEmail"].value(json.Codec)
+	require.NoError(t, err)
+	require.NotNil(t, value)
+
+	type BadName struct {
+		ID   int
+		Name string `storm:"index=Name"`
+	}
+
+	b := BadName{ID: 1}
+	r = reflect.ValueOf(&b)
+	_, err = extract(&r)
+	require.Equal(t, ErrIdxNameConflict, err)
+
+	type EmptyName struct {
+		ID   int
+		Name string `storm:"index="`
+	}
+
+	e := EmptyName{ID: 1}
+	r = reflect.ValueOf(&e)
+	_, err = extract(&r)
+	require.Equal(t, ErrUnknownTag, err)
+}
+
+func TestTupleOrder(t *testing.T) {
+	ci := &compoundIndex{
+		Fields: []*fieldConfig{{Name: "A"}, {Name: "B"}},
+	}
+	a := reflect.ValueOf("")
+	b := reflect.ValueOf(0)
+	ci.Fields[0].Value = &a
+	ci.Fields[1].Value = &b
+
+	tuples := [][]interface{}{
+		{"a", -1},
+		{"a", 10},
+		{"a\x00", -100},
+		{"a\x00b", 1},
+		{"ab", -10},
+		{"b", 0},
+	}
+
+	var prev []byte
+	for _, tuple := range tuples {
+		raw, err := ci.tuple(tuple, json.Codec)
+		require.NoError(t, err)
+		require.True(t, string(prev) < string(raw), "%v", tuple)
+		prev = raw
+	}
+
+	raw, err := ci.tuple("a", json.Codec)
+	require.NoError(t, err)
+	full, err := ci.tuple([]interface{}{"a", 10}, json.Codec)
+	require.NoError(t, err)
+	require.True(t, string(raw) < string(full))
+	require.True(t, string(full) < string(tupleUpperBound(raw)))
+
+	_, err = ci.tuple([]interface{}{"a", 1, 2}, json.Codec)
+	require.Equal(t, ErrIncompatibleValue, err)
+}
+
+func TestCompoundIndex(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	accounts := []Account{
+		{TenantID: "acme", Email: "john@acme.com", Name: "John", CreatedAt: now.Add(2 * time.Hour)},
+		{TenantID: "acme", Email: "jane@acme.com", Name: "Jane", CreatedAt: now},
+		{TenantID: "acme", Email: "jack@acme.com", Name: "Jack", CreatedAt: now.Add(time.Hour)},
+		{TenantID: "globex", Email: "john@acme.com", Name: "John", CreatedAt: now},
+		{TenantID: "acmeinc", Email: "john@acme.com", Name: "Jim", CreatedAt: now},
+	}
+
+	for i := range accounts {
+		require.NoError(t, db.Save(&accounts[i]))
+	}
+
+	// unique compound indexes are enforced
+	err := db.Save(&Account{TenantID: "acme", Email: "john@acme.com"})
+	require.Equal(t, ErrAlreadyExists, err)
+
+	var list []Account
+	err = db.Find("TenantCreated", []interface{}{"acme", now.Add(time.Hour)}, &list)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, "Jack", list[0].Name)
+
+	// partial tuples select every record starting with the given values
+	err = db.Find("TenantCreated", "acme", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	require.Equal(t, "Jane", list[0].Name)
+	require.Equal(t, "Jack", list[1].Name)
+	require.Equal(t, "John", list[2].Name)
+
+	err = db.Find("TenantCreated", "initech", &list)
+	require.Equal(t, ErrNotFound, err)
+
+	var account Account
+	err = db.One("TenantEmail", []string{"globex", "john@acme.com"}, &account)
+	require.NoError(t, err)
+	require.Equal(t, 4, account.ID)
+
+	err = db.One("TenantEmail", []string{"globex", "jane@acme.com"}, &account)
+	require.Equal(t, ErrNotFound, err)
+
+	err = db.Range("TenantCreated", []interface{}{"acme", now}, []interface{}{"acme", now.Add(time.Hour)}, &list)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "Jane", list[0].Name)
+	require.Equal(t, "Jack", list[1].Name)
+
+	err = db.Range("TenantCreated", "acme", "globex", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 5)
+
+	err = db.Range("TenantCreated", []interface{}{"acme", now.Add(time.Minute)}, "acme", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	err = db.Prefix("TenantName", "acme", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+
+	err = db.Prefix("TenantName", "glo", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	// updates move the record in the index
+	err = db.UpdateField(&Account{ID: 2}, "TenantID", "globex")
+	require.NoError(t, err)
+
+	err = db.Find("TenantCreated", "acme", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	err = db.Find("TenantCreated", "globex", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	err = db.Update(&Account{ID: 2, Email: "jane@globex.com"})
+	require.NoError(t, err)
+
+	err = db.One("TenantEmail", []string{"globex", "jane@globex.com"}, &account)
+	require.NoError(t, err)
+	require.Equal(t, 2, account.ID)
+
+	err = db.DeleteStruct(&account)
+	require.NoError(t, err)
+
+	err = db.One("TenantEmail", []string{"globex", "jane@globex.com"}, &account)
+	require.Equal(t, ErrNotFound, err)
+
+	err = db.Select(q.Eq("TenantID", "acmeinc")).Delete(new(Account))
+	require.NoError(t, err)
+
+	err = db.Find("TenantName", "acmeinc", &list)
+	require.Equal(t, ErrNotFound, err)
+}
+
+func TestCompoundIndexReIndex(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	{
+		type Account struct {
+			ID       int    `storm:"increment"`
+			TenantID string `storm:"index"`
+			Name     string
+		}
+
+		require.NoError(t, db.Save(&Account{TenantID: "acme", Name: "John"}))
+		require.NoError(t, db.Save(&Account{TenantID: "acme", Name: "Jane"}))
+	}
+
+	type Account struct {
+		ID       int    `storm:"increment"`
+		TenantID string `storm:"index,index=TenantName"`
+		Name     string `storm:"index=TenantName"`
+	}
+
+	require.NoError(t, db.ReIndex(&Account{}))
+
+	var list []Account
+	err := db.Find("TenantName", []string{"acme", "Jane"}, &list)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, 2, list[0].ID)
+}
+
+func TestPlanCompoundIndex(t *testing.T) {
+	typ := reflect.TypeOf(Account{})
+
+	plan := planIndex(q.And(q.Eq("Email", "john@acme.com"), q.Eq("Name", "John"), q.Eq("TenantID", "acme")), typ, json.Codec)
+	require.NotNil(t, plan)
+	require.Equal(t, "TenantEmail", plan.field)
+	require.Equal(t, tagUniqueIdx, plan.kind)
+	require.Len(t, plan.values, 1)
+	require.Equal(t, []q.Matcher{q.Eq("Name", "John")}, q.Conjuncts(plan.residual))
+
+	// all the fields of the index are required
+	plan = planIndex(q.And(q.Eq("Email", "john@acme.com"), q.Eq("Name", "John")), typ, json.Codec)
+	require.NotNil(t, plan)
+	require.Equal(t, "Name", plan.field)
+
+	// zero values are not indexed
+	plan = planIndex(q.And(q.Eq("Email", ""), q.Eq("TenantID", "acme")), typ, json.Codec)
+	require.Nil(t, plan)
+
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Account{TenantID: "acme", Email: "john@acme.com", Name: "John"}))
+	require.NoError(t, db.Save(&Account{TenantID: "globex", Email: "john@acme.com", Name: "John"}))
+
+	query := db.Select(q.Eq("TenantID", "globex"), q.Eq("Email", "john@acme.com"))
+
+	qp, err := query.Explain(new(Account))
+	require.NoError(t, err)
+	require.Equal(t, "TenantEmail", qp.Index)
+	require.Equal(t, 1, qp.ReadRows)
+
+	var list []Account
+	require.NoError(t, query.Find(&list))
+	require.Len(t, list, 1)
+	require.Equal(t, 2, list[0].ID)
+}
@@ -0,0 +1,238 @@
+package storm
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/asdine/storm/v3/codec"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// codec the bucket is being converted to
+	metaConvertCodec = "convert_codec"
+
+	// last record key read by the conversion
+	metaConvertNext = "convert_next"
+
+	// records whose key changed when converted
+	metaConvertedKeys = "convert_keys"
+)
+
+// ConvertCodec re-encodes the records of the bucket of kind, encoded with the from codec,
+// using the codec of the given node, and rebuilds the indexes of the bucket.
+//
+// Records are converted in batches of batchSize records, each in its own write transaction,
+// or in the transaction of the node. A batchSize lower than 1 converts all the records at once.
+// The codec stored in the bucket is replaced in the transaction converting the last batch.
+// Until then, the bucket can't be written to, and reading it fails or returns partial results.
+// If the conversion is interrupted, calling ConvertCodec again resumes it.
+func ConvertCodec(n Node, kind interface{}, from codec.MarshalUnmarshaler, batchSize int) error {
+	ref := reflect.ValueOf(kind)
+	if !ref.IsValid() || ref.Kind() != reflect.Ptr || ref.Elem().Kind() != reflect.Struct {
+		return ErrStructPtrNeeded
+	}
+
+	cfg, err := extract(&ref)
+	if err != nil {
+		return err
+	}
+
+	nn := nodeOf(n)
+	for {
+		var done bool
+		err = nn.readWriteTx(func(tx *bolt.Tx) error {
+			var err error
+			done, err = nn.convertCodec(tx, cfg, from, batchSize)
+			return err
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// convertCodec converts the next batch of records and reports whether the conversion is complete.
+func (n *node) convertCodec(tx *bolt.Tx, cfg *structConfig, from codec.MarshalUnmarshaler, batchSize int) (bool, error) {
+	bucket := n.GetBucket(tx, cfg.Name)
+	if bucket == nil {
+		return true, nil
+	}
+
+	m := bucket.Bucket([]byte(metadataBucket))
+	if m == nil {
+		return true, nil
+	}
+
+	target := n.codec.Name()
+	current := string(m.Get([]byte(metaCodec)))
+	converting := m.Get([]byte(metaConvertCodec))
+
+	switch {
+	case converting == nil && current == target:
+		return true, nil
+	case current != from.Name():
+		return false, ErrDifferentCodec
+	case converting != nil && string(converting) != target:
+		return false, ErrDifferentCodec
+	case converting == nil:
+		err := n.startConversion(bucket, m, target)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	converted, err := m.CreateBucketIfNotExists([]byte(metaConvertedKeys))
+	if err != nil {
+		return false, err
+	}
+
+	// the batch is read before being converted, bucket cursors are invalidated by writes
+	var keys, records [][]byte
+	var last []byte
+	c := bucket.Cursor()
+	k, v := c.First()
+	if next := m.Get([]byte(metaConvertNext)); next != nil {
+		k, v = c.Seek(next)
+		if bytes.Equal(k, next) {
+			k, v = c.Next()
+		}
+	}
+	for ; k != nil && (batchSize < 1 || len(keys) < batchSize); k, v = c.Next() {
+		last = append([]byte(nil), k...)
+		if v == nil || converted.Get(k) != nil {
+			continue
+		}
+
+		keys = append(keys, last)
+		records = append(records, append([]byte(nil), v...))
+	}
+	done := k == nil
+
+	for i := range keys {
+		if err := n.ctxErr(); err != nil {
+			return false, err
+		}
+
+		err = n.convertRecord(bucket, converted, cfg, from, keys[i], records[i])
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if !done {
+		return false, m.Put([]byte(metaConvertNext), last)
+	}
+
+	for _, key := range []string{metaConvertCodec, metaConvertNext} {
+		err = m.Delete([]byte(key))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = m.DeleteBucket([]byte(metaConvertedKeys))
+	if err != nil {
+		return false, err
+	}
+
+	err = m.Put([]byte(metaIndexEncoding), []byte(indexEncoding))
+	if err != nil {
+		return false, err
+	}
+
+	return true, m.Put([]byte(metaCodec), []byte(target))
+}
+
+// startConversion drops the indexes of the bucket, they are rebuilt as the records are converted,
+// and marks the bucket as being converted to the target codec.
+func (n *node) startConversion(bucket, m *bolt.Bucket, target string) error {
+	var indexes [][]byte
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v == nil && bytes.HasPrefix(k, []byte(indexPrefix)) {
+			indexes = append(indexes, append([]byte(nil), k...))
+		}
+	}
+
+	for _, name := range indexes {
+		err := bucket.DeleteBucket(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.Put([]byte(metaConvertCodec), []byte(target))
+}
+
+// convertRecord decodes the record stored under the given key with the from codec,
+// indexes it and saves it with the codec of the node.
+func (n *node) convertRecord(bucket, converted *bolt.Bucket, cfg *structConfig, from codec.MarshalUnmarshaler, key, raw []byte) error {
+	record := reflect.New(cfg.Type)
+	path := append(n.rootBucket[:len(n.rootBucket):len(n.rootBucket)], cfg.Name)
+	err := bindCodec(from, path, key).Unmarshal(raw, record.Interface())
+	if err != nil {
+		return err
+	}
+
+	rcfg, err := extract(&record)
+	if err != nil {
+		return err
+	}
+
+	// the key of the record changes if its ID is encoded with the codec
+	id, err := toBytes(rcfg.ID.Value.Interface(), n.codec)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(id, key) {
+		if bucket.Get(id) != nil {
+			return ErrAlreadyExists
+		}
+
+		err = bucket.Delete(key)
+		if err != nil {
+			return err
+		}
+
+		err = converted.Put(id, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	for fieldName, fieldCfg := range rcfg.Fields {
+		if fieldCfg.Index == "" {
+			continue
+		}
+
+		err = n.reindexRecord(bucket, rcfg, fieldCfg.Index, fieldName, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, ci := range rcfg.CompoundIndexes {
+		err = n.reindexRecord(bucket, rcfg, ci.Kind, ci.Name, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	raw, err = n.codecAt(cfg.Name, id).Marshal(record.Interface())
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(id, raw)
+}
+
+func (n *node) reindexRecord(bucket *bolt.Bucket, cfg *structConfig, indexKind, fieldName string, id []byte) error {
+	idx, err := getIndex(bucket, indexKind, fieldName)
+	if err != nil {
+		return err
+	}
+
+	return n.indexRecord(idx, cfg, fieldName, id)
+}
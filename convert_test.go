@@ -0,0 +1,113 @@
+package storm
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3/codec/gob"
+	"github.com/asdine/storm/v3/codec/json"
+	"github.com/asdine/storm/v3/codec/msgpack"
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+type Incident struct {
+	ID       time.Time
+	Name     string   `storm:"unique"`
+	Public   bool     `storm:"index"`
+	Tags     []string `storm:"index,multi"`
+	TenantID string   `storm:"index=TenantName"`
+	Label    string   `storm:"index=TenantName"`
+}
+
+func TestConvertCodec(t *testing.T) {
+	db, cleanup := createDB(t, Codec(json.Codec))
+	defer cleanup()
+
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		err := db.Save(&Incident{
+			ID:       now.Add(time.Duration(i) * time.Hour),
+			Name:     fmt.Sprintf("event%d", i),
+			Public:   i%2 == 0,
+			Tags:     []string{"tag", fmt.Sprintf("tag%d", i)},
+			TenantID: "acme",
+			Label:    fmt.Sprintf("label%d", i),
+		})
+		require.NoError(t, err)
+	}
+
+	n := db.WithCodec(msgpack.Codec)
+
+	// convert the first batch only
+	err := db.Bolt.Update(func(tx *bolt.Tx) error {
+		done, err := n.(*node).convertCodec(tx, mustExtract(t, new(Incident)), json.Codec, 4)
+		require.False(t, done)
+		return err
+	})
+	require.NoError(t, err)
+
+	// the bucket can't be written to until the conversion is complete
+	err = db.Save(&Incident{ID: now.Add(-time.Hour), Name: "event"})
+	require.Equal(t, ErrCodecConversion, err)
+	err = n.Save(&Incident{ID: now.Add(-time.Hour), Name: "event"})
+	require.Equal(t, ErrCodecConversion, err)
+
+	// the conversion is resumed
+	err = ConvertCodec(n, new(Incident), json.Codec, 3)
+	require.NoError(t, err)
+
+	var events []Incident
+	require.NoError(t, n.All(&events))
+	require.Len(t, events, 10)
+	require.True(t, now.Equal(events[0].ID))
+
+	var event Incident
+	require.NoError(t, n.One("ID", now.Add(2*time.Hour), &event))
+	require.Equal(t, "event2", event.Name)
+
+	require.NoError(t, n.One("Name", "event3", &event))
+	require.True(t, now.Add(3*time.Hour).Equal(event.ID))
+
+	require.NoError(t, n.Find("Public", true, &events))
+	require.Len(t, events, 5)
+
+	require.NoError(t, n.Select(q.Contains("Tags", "tag7")).Find(&events))
+	require.Len(t, events, 1)
+
+	require.NoError(t, n.Find("TenantName", []string{"acme", "label9"}, &events))
+	require.Len(t, events, 1)
+
+	// unique indexes are still enforced
+	err = n.Save(&Incident{ID: now.Add(-time.Hour), Name: "event1"})
+	require.Equal(t, ErrAlreadyExists, err)
+
+	require.NoError(t, n.Save(&Incident{ID: now.Add(-time.Hour), Name: "event"}))
+
+	err = db.Save(&Incident{ID: now.Add(-2 * time.Hour), Name: "other"})
+	require.Equal(t, ErrDifferentCodec, err)
+
+	// converting again does nothing
+	err = ConvertCodec(n, new(Incident), json.Codec, 3)
+	require.NoError(t, err)
+
+	err = ConvertCodec(db.WithCodec(gob.Codec), new(Incident), json.Codec, 3)
+	require.Equal(t, ErrDifferentCodec, err)
+
+	err = ConvertCodec(n, new(User), json.Codec, 3)
+	require.NoError(t, err)
+
+	// the DB can be used directly
+	err = ConvertCodec(db, new(Incident), json.Codec, 3)
+	require.Equal(t, ErrDifferentCodec, err)
+}
+
+func mustExtract(t *testing.T, data interface{}) *structConfig {
+	ref := reflect.ValueOf(data)
+	cfg, err := extract(&ref)
+	require.NoError(t, err)
+	return cfg
+}
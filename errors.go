@@ -48,4 +48,72 @@ var (
 
 	// ErrDifferentCodec is returned when using a codec different than the first codec used with the bucket.
 	ErrDifferentCodec = errors.New("the selected codec is incompatible with this bucket")
+
+	// ErrCodecConversion is returned when writing to a bucket while its records are converted to another codec.
+	ErrCodecConversion = errors.New("the records of this bucket are being converted to another codec")
+
+	// ErrCannotReEncrypt is returned when re-encrypting records with a codec that doesn't implement codec.ReEncrypter.
+	ErrCannotReEncrypt = errors.New("the codec can't re-encrypt records")
+
+	// ErrCannotRebind is returned when moving records bound to their location by a codec that doesn't implement codec.Rebinder.
+	ErrCannotRebind = errors.New("the codec can't move records bound to their location")
+
+	// ErrIdxNameConflict is returned when a compound index has the same name as a field of the struct.
+	ErrIdxNameConflict = errors.New("compound index name conflicts with a field name")
+
+	// ErrBadMultiIndex is returned when the multi tag is not used on a non unique index of a slice, an array or a map.
+	ErrBadMultiIndex = errors.New("multi indexes must be non unique indexes of slices, arrays or maps")
+
+	// ErrBadTimestamp is returned when the created or updated tag is used on a field that is not a time.Time or an integer.
+	ErrBadTimestamp = errors.New("timestamp fields must be of type time.Time, *time.Time or an integer")
+
+	// ErrBadVersion is returned when the version tag is used on a field that is not an integer.
+	ErrBadVersion = errors.New("version fields must be integers")
+
+	// ErrVersionConflict is returned when saving a record whose version is not the saved one.
+	ErrVersionConflict = errors.New("the record was modified since it was read")
+
+	// ErrBadDeleted is returned when the deleted tag is used on a field that is not a bool, a time.Time or a *time.Time.
+	ErrBadDeleted = errors.New("deleted fields must be of type bool, time.Time or *time.Time")
+
+	// ErrBadExpiry is returned when the expires or ttl tag is used on a field that is not a time.Time.
+	ErrBadExpiry = errors.New("expiry fields must be of type time.Time")
+
+	// ErrNoDeleted is returned when restoring or purging records that have no field tagged with deleted.
+	ErrNoDeleted = errors.New("missing struct tag deleted")
+
+	// ErrBadFullText is returned when the fulltext tag is used on a field that is not a string, on the ID or on a reference.
+	ErrBadFullText = errors.New("fulltext fields must be strings that are neither the ID nor a reference")
+
+	// ErrBadTrigram is returned when the trigram tag is used on a field that is not a string, on the ID or on a reference.
+	ErrBadTrigram = errors.New("trigram fields must be strings that are neither the ID nor a reference")
+
+	// ErrRefNotFound is returned when saving a record referencing a record that doesn't exist.
+	ErrRefNotFound = errors.New("referenced record not found")
+
+	// ErrReferenced is returned when deleting a record referenced by other records with the restrict action.
+	ErrReferenced = errors.New("the record is referenced by other records")
+
+	// ErrUnknownRef is returned when deleting a record referenced with setnull, or with cascade by a type with a deleted field,
+	// by a type that was not saved or initialized since the database was opened.
+	ErrUnknownRef = errors.New("the types referencing this record must be initialized with Init before deleting it")
+
+	// ErrBadPreload is returned when preloading a field that is neither a pointer to a record referenced by a field
+	// of the same struct nor a slice of records referencing the struct.
+	ErrBadPreload = errors.New("preloaded fields must be pointers to referenced records or slices of referencing records")
+
+	// ErrLegacyIndexes is returned when saving a record in a bucket whose indexes were built by an older version of Storm.
+	ErrLegacyIndexes = errors.New("the indexes of the bucket must be rebuilt with ReIndex or the RebuildIndexes migration")
+
+	// ErrInvalidMigration is returned when a migration has no ID, no function or the same ID as another migration.
+	ErrInvalidMigration = errors.New("migrations must have a unique ID and a function")
+
+	// ErrBadTransform is returned when a transform function is not of the form func(*Old) (*New, error).
+	ErrBadTransform = errors.New("transform functions must be of the form func(*Old) (*New, error)")
+
+	// ErrInvalidToken is returned when a continuation token can't be decoded.
+	ErrInvalidToken = errors.New("invalid continuation token")
+
+	// ErrTokenWithOrderBy is returned when a continuation token is used by a query sorted with OrderBy.
+	ErrTokenWithOrderBy = errors.New("continuation tokens can't be used with OrderBy")
 )
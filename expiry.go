@@ -0,0 +1,162 @@
+package storm
+
+import (
+	"bytes"
+	"reflect"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// expiryField returns the name of the field of the given type tagged with expires or ttl, if any.
+func expiryField(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return ""
+	}
+
+	return taggedField(typ, tagExpires, tagTTL)
+}
+
+// isExpired reports whether the given record has expired at the given time.
+// It always returns false if field is empty or if the expiry time is zero.
+func isExpired(record reflect.Value, field string, now time.Time) bool {
+	if field == "" {
+		return false
+	}
+
+	for record.Kind() == reflect.Ptr {
+		record = record.Elem()
+	}
+
+	t := record.FieldByName(field).Interface().(time.Time)
+	return !t.IsZero() && !t.After(now)
+}
+
+// PurgeExpired deletes the records whose expiry time has passed. The buckets of the types
+// with a field tagged with expires or ttl are found with their metadata, and purged each
+// in its own transaction. The delete hooks are not called.
+// It returns the first error encountered, after purging the other buckets.
+func (s *DB) PurgeExpired() error {
+	var paths [][]string
+	err := s.Bolt.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			paths = expiringBuckets(b, []string{string(name)}, paths)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, path := range paths {
+		n := *nodeOf(s.Node)
+		n.rootBucket = path[:len(path)-1]
+
+		err := n.purgeExpired(path[len(path)-1])
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// expiringBuckets adds to the list the paths of the given bucket and of its children
+// if their metadata holds an expiry field.
+func expiringBuckets(b *bolt.Bucket, path []string, list [][]string) [][]string {
+	if m := b.Bucket([]byte(metadataBucket)); m != nil && len(m.Get([]byte(metaExpires))) > 0 {
+		list = append(list, path)
+	}
+
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil || bytes.Equal(k, []byte(metadataBucket)) || bytes.HasPrefix(k, []byte(indexPrefix)) {
+			continue
+		}
+
+		list = expiringBuckets(b.Bucket(k), append(path[:len(path):len(path)], string(k)), list)
+	}
+
+	return list
+}
+
+// purgeExpired deletes the expired records of the given bucket, found in the index of its expiry field.
+func (n *node) purgeExpired(bucketName string) error {
+	max, err := toIndexBytes(n.s.clock(), n.codec)
+	if err != nil {
+		return err
+	}
+
+	return n.readWriteTx(func(tx *bolt.Tx) error {
+		bucket := n.GetBucket(tx, bucketName)
+		if bucket == nil {
+			return nil
+		}
+
+		m := bucket.Bucket([]byte(metadataBucket))
+		field := string(m.Get([]byte(metaExpires)))
+		if field == "" {
+			return nil
+		}
+
+		var kind string
+		if indexes := m.Bucket([]byte(metaIndexes)); indexes != nil {
+			kind = string(indexes.Get([]byte(field)))
+		}
+
+		idx, err := getIndex(bucket, kind, field)
+		if err != nil {
+			return err
+		}
+
+		list, err := idx.Range(nil, max, nil)
+		if err != nil {
+			return err
+		}
+
+		// the IDs are only valid until the bucket is modified
+		ids := make([][]byte, len(list))
+		for i := range list {
+			ids[i] = append([]byte(nil), list[i]...)
+		}
+
+		for _, id := range ids {
+			if err := n.ctxErr(); err != nil {
+				return err
+			}
+
+			// records deleted in cascade with a previous one are skipped
+			err := n.deleteRecord(tx, bucketName, id)
+			if err != nil && err != ErrNotFound {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// sweep purges the expired records at the given interval until the database is closed.
+func (s *DB) sweep(interval time.Duration, onError func(error)) {
+	defer close(s.sweepDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopSweep:
+			return
+		case <-ticker.C:
+			err := s.PurgeExpired()
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package storm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3/codec/gob"
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+type Session struct {
+	ID        int       `storm:"increment"`
+	User      string    `storm:"index"`
+	ExpiresAt time.Time `storm:"expires"`
+}
+
+type OneTimeToken struct {
+	ID      string
+	Expires time.Time `storm:"ttl,unique"`
+}
+
+// sessionStored reports whether the session of the given ID is stored, expired or not.
+func sessionStored(t *testing.T, db *DB, id int) bool {
+	key, err := toBytes(id, db.Codec())
+	require.NoError(t, err)
+
+	var stored bool
+	require.NoError(t, db.Bolt.View(func(tx *bolt.Tx) error {
+		stored = tx.Bucket([]byte("Session")).Get(key) != nil
+		return nil
+	}))
+	return stored
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, cleanup := createDB(t, Clock(func() time.Time {
+		return now
+	}))
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Session{User: "john", ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, db.Save(&Session{User: "john", ExpiresAt: now.Add(2 * time.Hour)}))
+	require.NoError(t, db.Save(&Session{User: "jack"}))
+
+	var sessions []Session
+	require.NoError(t, db.Find("User", "john", &sessions))
+	require.Len(t, sessions, 2)
+
+	// records expire once their expiry time is reached, zero times never expire
+	now = now.Add(time.Hour)
+
+	var session Session
+	require.Equal(t, ErrNotFound, db.One("ID", 1, &session))
+	require.Zero(t, session)
+	require.NoError(t, db.One("ID", 2, &session))
+
+	// the first session of the user has expired
+	session = Session{}
+	require.NoError(t, db.One("User", "john", &session))
+	require.Equal(t, 2, session.ID)
+
+	require.NoError(t, db.Find("User", "john", &sessions))
+	require.Len(t, sessions, 1)
+	require.Equal(t, 2, sessions[0].ID)
+
+	require.NoError(t, db.All(&sessions, Limit(1)))
+	require.Len(t, sessions, 1)
+	require.Equal(t, 2, sessions[0].ID)
+
+	require.NoError(t, db.Select(q.Eq("User", "john")).Find(&sessions))
+	require.Len(t, sessions, 1)
+
+	count, err := db.Count(new(Session))
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	// expired records are stored until they are purged
+	require.True(t, sessionStored(t, db, 1))
+
+	require.NoError(t, db.Save(&OneTimeToken{ID: "a", Expires: now.Add(-time.Minute)}))
+	require.NoError(t, db.Save(&OneTimeToken{ID: "b", Expires: now.Add(time.Minute)}))
+
+	require.NoError(t, db.PurgeExpired())
+	require.False(t, sessionStored(t, db, 1))
+	require.True(t, sessionStored(t, db, 2))
+	require.True(t, sessionStored(t, db, 3))
+
+	// the indexes are cleaned up
+	require.NoError(t, db.Save(&OneTimeToken{ID: "c", Expires: now.Add(-time.Minute)}))
+	require.NoError(t, db.PurgeExpired())
+
+	var tokens []OneTimeToken
+	require.NoError(t, db.All(&tokens))
+	require.Len(t, tokens, 1)
+	require.Equal(t, "b", tokens[0].ID)
+
+	type Invalid struct {
+		ID      int
+		Expires int64 `storm:"expires"`
+	}
+	require.Equal(t, ErrBadExpiry, db.Save(&Invalid{ID: 1}))
+}
+
+func TestExpiryReusedSlots(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, cleanup := createDB(t, Codec(gob.Codec), Clock(func() time.Time {
+		return now
+	}))
+	defer cleanup()
+
+	// gob doesn't encode zero times, the next record must not inherit the expiry time
+	require.NoError(t, db.Save(&Session{User: "john", ExpiresAt: now.Add(-time.Minute)}))
+	require.NoError(t, db.Save(&Session{User: "john"}))
+
+	var sessions []Session
+	require.NoError(t, db.Find("User", "john", &sessions))
+	require.Len(t, sessions, 1)
+	require.Equal(t, 2, sessions[0].ID)
+	require.True(t, sessions[0].ExpiresAt.IsZero())
+}
+
+func TestPurgeExpiredAfterReopen(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := Clock(func() time.Time {
+		return now
+	})
+	db, cleanup := createDB(t, clock)
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Session{User: "john", ExpiresAt: now.Add(-time.Minute)}))
+	require.NoError(t, db.Save(&Session{User: "jack", ExpiresAt: now.Add(time.Minute)}))
+	require.NoError(t, db.From("a", "b").Save(&Session{User: "john", ExpiresAt: now.Add(-time.Minute)}))
+
+	// the expiring buckets are found without using their types
+	path := db.Bolt.Path()
+	require.NoError(t, db.Close())
+	db, err := Open(path, clock)
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.PurgeExpired())
+	require.False(t, sessionStored(t, db, 1))
+	require.True(t, sessionStored(t, db, 2))
+
+	var sessions []Session
+	require.NoError(t, db.From("a", "b").All(&sessions))
+	require.Empty(t, sessions)
+
+	// the indexes are cleaned up
+	now = now.Add(-time.Hour)
+	require.NoError(t, db.Find("User", "jack", &sessions))
+	require.Len(t, sessions, 1)
+	require.Equal(t, ErrNotFound, db.Find("User", "john", &sessions))
+	require.Equal(t, ErrNotFound, db.From("a", "b").Find("User", "john", &sessions))
+}
+
+func TestSweepExpired(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, cleanup := createDB(t, Clock(func() time.Time {
+		return now
+	}), SweepExpired(time.Millisecond, nil))
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Session{User: "john", ExpiresAt: now.Add(-time.Minute)}))
+	require.NoError(t, db.Save(&Session{User: "jack", ExpiresAt: now.Add(time.Minute)}))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for sessionStored(t, db, 1) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	require.False(t, sessionStored(t, db, 1))
+	require.True(t, sessionStored(t, db, 2))
+}
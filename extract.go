@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/asdine/storm/v3/index"
+	"github.com/asdine/storm/v3/internal"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -16,7 +17,18 @@ const (
 	tagIdx       = "index"
 	tagUniqueIdx = "unique"
 	tagInline    = "inline"
+	tagNested    = "nested"
+	tagMulti     = "multi"
 	tagIncrement = "increment"
+	tagCreated   = "created"
+	tagUpdated   = "updated"
+	tagVersion   = "version"
+	tagDeleted   = "deleted"
+	tagRef       = "ref"
+	tagFullText  = "fulltext"
+	tagTrigram   = "trigram"
+	tagExpires   = "expires"
+	tagTTL       = "ttl"
 	indexPrefix  = "__storm_index_"
 )
 
@@ -30,13 +42,43 @@ type fieldConfig struct {
 	IsInteger      bool
 	Value          *reflect.Value
 	ForceUpdate    bool
+	Timestamp      string
+	IsVersion      bool
+	IsDeleted      bool
+	IsExpiry       bool
+	Ref            string
+	OnDelete       string
+	Analyzer       string
 }
 
 // structConfig is a structure gathering all the relevant informations about a model
 type structConfig struct {
-	Name   string
-	Fields map[string]*fieldConfig
-	ID     *fieldConfig
+	Name            string
+	Type            reflect.Type
+	Fields          map[string]*fieldConfig
+	ID              *fieldConfig
+	CompoundIndexes map[string]*compoundIndex
+}
+
+// addToCompoundIndex adds a field to the compound index of the given name,
+// after the fields already added.
+func (m *structConfig) addToCompoundIndex(name, kind string, f *fieldConfig) {
+	if m.CompoundIndexes == nil {
+		m.CompoundIndexes = make(map[string]*compoundIndex)
+	}
+
+	ci, ok := m.CompoundIndexes[name]
+	if !ok {
+		ci = &compoundIndex{Name: name, Kind: kind}
+		m.CompoundIndexes[name] = ci
+	}
+
+	// a compound index is unique if one of its fields says so
+	if kind == tagUniqueIdx {
+		ci.Kind = tagUniqueIdx
+	}
+
+	ci.Fields = append(ci.Fields, f)
 }
 
 func extract(s *reflect.Value, mi ...*structConfig) (*structConfig, error) {
@@ -65,19 +107,13 @@ func extract(s *reflect.Value, mi ...*structConfig) (*structConfig, error) {
 		m.Name = typ.Name()
 	}
 
-	numFields := s.NumField()
-	for i := 0; i < numFields; i++ {
-		field := typ.Field(i)
-		value := s.Field(i)
-
-		if field.PkgPath != "" {
-			continue
-		}
+	if m.Type == nil {
+		m.Type = typ
+	}
 
-		err := extractField(&value, &field, m, child)
-		if err != nil {
-			return nil, err
-		}
+	err := extractFields(s, m, "", child)
+	if err != nil {
+		return nil, err
 	}
 
 	if child {
@@ -92,17 +128,52 @@ func extract(s *reflect.Value, mi ...*structConfig) (*structConfig, error) {
 		return nil, ErrNoName
 	}
 
+	// compound indexes share the namespace of the indexed fields
+	for name := range m.CompoundIndexes {
+		if _, ok := typ.FieldByName(name); ok {
+			return nil, ErrIdxNameConflict
+		}
+	}
+
 	return m, nil
 }
 
-func extractField(value *reflect.Value, field *reflect.StructField, m *structConfig, isChild bool) error {
+// extractFields extracts the exported fields of the given struct.
+// The fields of a nested struct are named after their path, prefixed by the given one.
+func extractFields(s *reflect.Value, m *structConfig, prefix string, isChild bool) error {
+	typ := s.Type()
+
+	numFields := s.NumField()
+	for i := 0; i < numFields; i++ {
+		field := typ.Field(i)
+		value := s.Field(i)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		err := extractField(&value, &field, m, prefix, isChild)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractField(value *reflect.Value, field *reflect.StructField, m *structConfig, prefix string, isChild bool) error {
 	var f *fieldConfig
 	var err error
+	var compounds [][2]string
+	var multi bool
+
+	// the ID of the record can't be part of a nested struct
+	nested := prefix != ""
 
 	tag := field.Tag.Get("storm")
 	if tag != "" {
 		f = &fieldConfig{
-			Name:           field.Name,
+			Name:           prefix + field.Name,
 			IsZero:         isZero(value),
 			IsInteger:      isInteger(value),
 			Value:          value,
@@ -114,27 +185,93 @@ func extractField(value *reflect.Value, field *reflect.StructField, m *structCon
 		for _, tag := range tags {
 			switch tag {
 			case "id":
-				f.IsID = true
-				f.Index = tagUniqueIdx
-			case tagUniqueIdx, tagIdx:
+				if !nested {
+					f.IsID = true
+					f.Index = tagUniqueIdx
+				}
+			case tagUniqueIdx, tagIdx, tagFullText, tagTrigram:
 				f.Index = tag
+			case tagMulti:
+				multi = true
+			case tagCreated, tagUpdated:
+				if !isTimestamp(value.Type()) {
+					return ErrBadTimestamp
+				}
+				if !nested {
+					f.Timestamp = tag
+				}
+			case tagVersion:
+				if !f.IsInteger {
+					return ErrBadVersion
+				}
+				f.IsVersion = !nested
+			case tagDeleted:
+				if !isDeletedType(value.Type()) {
+					return ErrBadDeleted
+				}
+				f.IsDeleted = !nested
+			case tagExpires, tagTTL:
+				if value.Type() != timeType {
+					return ErrBadExpiry
+				}
+				f.IsExpiry = !nested
 			case tagInline:
 				if value.Kind() == reflect.Ptr {
 					e := value.Elem()
 					value = &e
 				}
 				if value.Kind() == reflect.Struct {
-					a := value.Addr()
-					_, err := extract(&a, m)
+					err := extractFields(value, m, prefix, true)
 					if err != nil {
 						return err
 					}
 				}
 				// we don't need to save this field
 				return nil
+			case tagNested:
+				if value.Kind() == reflect.Ptr {
+					// the fields of a nil struct are indexed as zero values
+					var e reflect.Value
+					if value.IsNil() {
+						e = reflect.Zero(value.Type().Elem())
+					} else {
+						e = value.Elem()
+					}
+					value = &e
+				}
+				if value.Kind() == reflect.Struct {
+					err := extractFields(value, m, f.Name+".", true)
+					if err != nil {
+						return err
+					}
+				}
+				// the nested fields are saved instead of this one
+				return nil
 			default:
-				if strings.HasPrefix(tag, tagIncrement) {
-					f.Increment = true
+				if parts := strings.SplitN(tag, "=", 2); len(parts) == 2 && (parts[0] == tagIdx || parts[0] == tagUniqueIdx) {
+					if parts[1] == "" {
+						return ErrUnknownTag
+					}
+					compounds = append(compounds, [2]string{parts[1], parts[0]})
+				} else if len(parts) == 2 && parts[0] == tagFullText {
+					if _, ok := analyzers[parts[1]]; !ok {
+						return ErrUnknownTag
+					}
+					f.Index = tagFullText
+					f.Analyzer = parts[1]
+				} else if len(parts) == 2 && parts[0] == tagRef {
+					ref := strings.SplitN(parts[1], ":", 2)
+					if ref[0] == "" || (len(ref) == 2 && !isOnDeleteAction(ref[1])) {
+						return ErrUnknownTag
+					}
+					if !nested {
+						f.Ref = ref[0]
+						if len(ref) == 2 {
+							f.OnDelete = ref[1]
+						}
+					}
+				} else if strings.HasPrefix(tag, tagIncrement) {
+					f.Increment = !nested
 					parts := strings.Split(tag, "=")
 					if parts[0] != tagIncrement {
 						return ErrUnknownTag
@@ -151,8 +288,34 @@ func extractField(value *reflect.Value, field *reflect.StructField, m *structCon
 			}
 		}
 
+		if f.Index == tagFullText && (f.IsID || f.Ref != "" || value.Kind() != reflect.String) {
+			return ErrBadFullText
+		}
+
+		if f.Index == tagTrigram && (f.IsID || f.Ref != "" || value.Kind() != reflect.String) {
+			return ErrBadTrigram
+		}
+
+		// references are indexed to find the records referencing a given one,
+		// expiry times to find the expired records
+		if (f.Ref != "" || f.IsExpiry) && f.Index == "" {
+			f.Index = tagIdx
+		}
+
+		// each element of a multi index is indexed separately
+		if multi {
+			if f.Index != tagIdx || f.IsID || !isMultiValued(value.Type()) {
+				return ErrBadMultiIndex
+			}
+			f.Index = tagMulti
+		}
+
 		if _, ok := m.Fields[f.Name]; !ok || !isChild {
 			m.Fields[f.Name] = f
+
+			for _, c := range compounds {
+				m.addToCompoundIndex(c[0], c[1], f)
+			}
 		}
 	}
 
@@ -161,7 +324,7 @@ func extractField(value *reflect.Value, field *reflect.StructField, m *structCon
 	}
 
 	// the field is named ID and no ID field has been detected before
-	if m.ID == nil && field.Name == "ID" {
+	if m.ID == nil && !nested && field.Name == "ID" {
 		if f == nil {
 			f = &fieldConfig{
 				Index:          tagUniqueIdx,
@@ -184,13 +347,26 @@ func extractSingleField(ref *reflect.Value, fieldName string) (*structConfig, er
 	var cfg structConfig
 	cfg.Fields = make(map[string]*fieldConfig)
 
-	f, ok := ref.Type().FieldByName(fieldName)
+	f, ok := internal.StructFieldByPath(ref.Type(), fieldName)
 	if !ok || f.PkgPath != "" {
 		return nil, fmt.Errorf("field %s not found", fieldName)
 	}
 
+	if strings.Contains(fieldName, ".") {
+		// nested fields are indexed only if their parents are tagged as nested
+		full, err := extract(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if field, ok := full.Fields[fieldName]; ok {
+			cfg.Fields[fieldName] = field
+		}
+		return &cfg, nil
+	}
+
 	v := ref.FieldByName(fieldName)
-	err := extractField(&v, &f, &cfg, false)
+	err := extractField(&v, &f, &cfg, "", false)
 	if err != nil {
 		return nil, err
 	}
@@ -207,6 +383,12 @@ func getIndex(bucket *bolt.Bucket, idxKind string, fieldName string) (index.Inde
 		idx, err = index.NewUniqueIndex(bucket, []byte(indexPrefix+fieldName))
 	case tagIdx:
 		idx, err = index.NewListIndex(bucket, []byte(indexPrefix+fieldName))
+	case tagMulti:
+		idx, err = index.NewMultiListIndex(bucket, []byte(indexPrefix+fieldName))
+	case tagFullText:
+		idx, err = index.NewFullTextIndex(bucket, []byte(indexPrefix+fieldName))
+	case tagTrigram:
+		idx, err = index.NewTrigramIndex(bucket, []byte(indexPrefix+fieldName))
 	default:
 		err = ErrIdxNotFound
 	}
@@ -214,6 +396,39 @@ func getIndex(bucket *bolt.Bucket, idxKind string, fieldName string) (index.Inde
 	return idx, err
 }
 
+// isMultiValued reports whether each element of the values of the given type can be indexed separately.
+func isMultiValued(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array:
+		return typ.Elem().Kind() != reflect.Uint8
+	case reflect.Map:
+		return true
+	}
+
+	return false
+}
+
+// hasLookupIndex reports whether the records can be looked up by the value of the field in its index.
+// Full-text and trigram indexes reference the parts of the values instead.
+func (f *fieldConfig) hasLookupIndex() bool {
+	return f.Index != "" && f.Index != tagFullText && f.Index != tagTrigram
+}
+
+// indexType returns the type of the values stored in the index of the field,
+// the type of its elements for multi indexes.
+func (f *fieldConfig) indexType() reflect.Type {
+	typ := f.Value.Type()
+	if f.Index != tagMulti {
+		return typ
+	}
+
+	if typ.Kind() == reflect.Map {
+		return typ.Key()
+	}
+
+	return typ.Elem()
+}
+
 func isZero(v *reflect.Value) bool {
 	zero := reflect.Zero(v.Type()).Interface()
 	current := v.Interface()
@@ -73,6 +73,63 @@ func TestExtractInlineWithIndex(t *testing.T) {
 	require.Len(t, allByType(infos, "unique"), 3)
 }
 
+func TestExtractNested(t *testing.T) {
+	s := Customer{ID: 1, Address: Address{ID: 10, City: "Paris"}}
+	r := reflect.ValueOf(&s)
+	infos, err := extract(&r)
+	require.NoError(t, err)
+	require.Equal(t, "ID", infos.ID.Name)
+	require.Len(t, allByType(infos, "index"), 3)
+	require.Len(t, allByType(infos, "unique"), 2)
+
+	require.Equal(t, "index", infos.Fields["Address.City"].Index)
+	require.Equal(t, "Paris", infos.Fields["Address.City"].Value.Interface())
+	require.Equal(t, "unique", infos.Fields["Billing.ZipCode"].Index)
+	require.True(t, infos.Fields["Billing.ZipCode"].IsZero)
+
+	// the ID of a nested struct is not the ID of the record
+	require.Equal(t, "", infos.Fields["Address.ID"].Index)
+	require.False(t, infos.Fields["Address.ID"].IsID)
+	require.False(t, infos.Fields["Address.ID"].Increment)
+
+	// fields of structs that are not tagged are not indexed
+	require.NotContains(t, infos.Fields, "Other.City")
+	require.NotContains(t, infos.Fields, "Address")
+}
+
+func TestExtractMultiIndex(t *testing.T) {
+	s := Post{Tags: []string{"go"}}
+	r := reflect.ValueOf(&s)
+	infos, err := extract(&r)
+	require.NoError(t, err)
+	require.Equal(t, tagMulti, infos.Fields["Tags"].Index)
+	require.Equal(t, reflect.TypeOf(""), infos.Fields["Tags"].indexType())
+	require.Equal(t, tagMulti, infos.Fields["Voters"].Index)
+	require.Equal(t, reflect.TypeOf(0), infos.Fields["Voters"].indexType())
+	require.Equal(t, tagIdx, infos.Fields["Words"].Index)
+	require.Equal(t, reflect.TypeOf([]string{}), infos.Fields["Words"].indexType())
+
+	type Unique struct {
+		ID   int
+		Tags []string `storm:"unique,multi"`
+	}
+
+	u := Unique{ID: 1}
+	r = reflect.ValueOf(&u)
+	_, err = extract(&r)
+	require.Equal(t, ErrBadMultiIndex, err)
+
+	type NotSlice struct {
+		ID   int
+		Name string `storm:"index,multi"`
+	}
+
+	n := NotSlice{ID: 1}
+	r = reflect.ValueOf(&n)
+	_, err = extract(&r)
+	require.Equal(t, ErrBadMultiIndex, err)
+}
+
 func TestExtractMultipleTags(t *testing.T) {
 	type User struct {
 		ID              uint64 `storm:"id,increment"`
@@ -24,7 +24,7 @@ type Finder interface {
 	// If there are no records it returns no error and the 'to' parameter is set to an empty slice.
 	All(to interface{}, options ...func(*index.Options)) error
 
-	// Select a list of records that match a list of matchers. Doesn't use indexes.
+	// Select a list of records that match a list of matchers. Uses indexes when possible.
 	Select(matchers ...q.Matcher) Query
 
 	// Range returns one or more records by the specified index within the specified range
@@ -35,6 +35,18 @@ type Finder interface {
 
 	// Count counts all the records of a bucket
 	Count(data interface{}) (int, error)
+
+	// Like returns the records whose given string field matches the given pattern, ignoring case, as done by q.Like.
+	Like(fieldName string, pattern string, to interface{}, options ...func(*index.Options)) error
+
+	// Similar returns the records whose given field, indexed with trigram, is similar to the given value, the most similar first.
+	Similar(fieldName string, value string, to interface{}, options ...func(*index.Options)) error
+
+	// Search returns the records whose full-text indexed fields match the given query, the most relevant first.
+	Search(to interface{}, query string, options ...func(*index.Options)) error
+
+	// Token returns a continuation token to fetch the records following the given one
+	Token(fieldName string, record interface{}) (string, error)
 }
 
 // One returns one record by the specified index
@@ -54,23 +66,30 @@ func (n *node) One(fieldName string, value interface{}, to interface{}) error {
 	}
 
 	ref := reflect.Indirect(sink.ref)
+	if ci := compoundIndexByName(ref.Type(), fieldName); ci != nil {
+		val, err := ci.tuple(value, n.codec)
+		if err != nil {
+			return err
+		}
+
+		return n.readTx(func(tx *bolt.Tx) error {
+			return n.oneByTuple(tx, bucketName, ci, to, val)
+		})
+	}
+
 	cfg, err := extractSingleField(&ref, fieldName)
 	if err != nil {
 		return err
 	}
 
 	field, ok := cfg.Fields[fieldName]
-	if !ok || (!field.IsID && field.Index == "") {
+	if !ok || (!field.IsID && !field.hasLookupIndex()) {
 		query := newQuery(n, q.StrictEq(fieldName, value))
 		query.Limit(1)
 
-		if n.tx != nil {
-			err = query.query(n.tx, sink)
-		} else {
-			err = n.s.Bolt.View(func(tx *bolt.Tx) error {
-				return query.query(tx, sink)
-			})
-		}
+		err = n.readTx(func(tx *bolt.Tx) error {
+			return query.query(tx, sink)
+		})
 
 		if err != nil {
 			return err
@@ -79,13 +98,30 @@ func (n *node) One(fieldName string, value interface{}, to interface{}) error {
 		return sink.flush()
 	}
 
-	val, err := toBytes(value, n.codec)
+	if field.IsID {
+		val, err := toBytes(value, n.codec)
+		if err != nil {
+			return err
+		}
+
+		return n.readTx(func(tx *bolt.Tx) error {
+			return n.one(tx, bucketName, fieldName, cfg, to, val, true)
+		})
+	}
+
+	val, err := toFieldIndexBytes(value, field, n.codec)
 	if err != nil {
 		return err
 	}
 
 	return n.readTx(func(tx *bolt.Tx) error {
-		return n.one(tx, bucketName, fieldName, cfg, to, val, field.IsID)
+		if n.hasLegacyIndexes(tx, bucketName) {
+			opts := index.NewOptions()
+			opts.Limit = 1
+			return n.scan(tx, q.StrictEq(fieldName, value), sink, opts)
+		}
+
+		return n.one(tx, bucketName, fieldName, cfg, to, val, false)
 	})
 }
 
@@ -95,7 +131,7 @@ func (n *node) one(tx *bolt.Tx, bucketName, fieldName string, cfg *structConfig,
 		return ErrNotFound
 	}
 
-	var id []byte
+	ids := [][]byte{val}
 	if !skipIndex {
 		idx, err := getIndex(bucket, cfg.Fields[fieldName].Index, fieldName)
 		if err != nil {
@@ -105,21 +141,66 @@ func (n *node) one(tx *bolt.Tx, bucketName, fieldName string, cfg *structConfig,
 			return err
 		}
 
-		id = idx.Get(val)
-	} else {
-		id = val
+		ids, err = idx.All(val, nil)
+		if err != nil {
+			return err
+		}
 	}
 
-	if id == nil {
-		return ErrNotFound
+	return n.oneOf(tx, bucket, bucketName, ids, to)
+}
+
+// oneOf decodes into to the first record of the given IDs that is neither deleted nor expired.
+func (n *node) oneOf(tx *bolt.Tx, bucket *bolt.Bucket, bucketName string, ids [][]byte, to interface{}) error {
+	ref := reflect.ValueOf(to)
+	typ := ref.Elem().Type()
+	deleted, expires, now := n.filteredDeleted(typ), expiryField(typ), n.s.clock()
+
+	for _, id := range ids {
+		raw := bucket.Get(id)
+		if raw == nil {
+			return ErrNotFound
+		}
+
+		record := reflect.New(typ)
+		err := n.codecAt(bucketName, id).Unmarshal(raw, record.Interface())
+		if err != nil {
+			return err
+		}
+
+		if isDeleted(record, deleted) || isExpired(record, expires, now) {
+			continue
+		}
+
+		ref.Elem().Set(record.Elem())
+		return n.afterLoad(tx, to)
 	}
 
-	raw := bucket.Get(id)
-	if raw == nil {
+	return ErrNotFound
+}
+
+func (n *node) oneByTuple(tx *bolt.Tx, bucketName string, ci *compoundIndex, to interface{}, val []byte) error {
+	bucket := n.GetBucket(tx, bucketName)
+	if bucket == nil {
 		return ErrNotFound
 	}
 
-	return n.codec.Unmarshal(raw, to)
+	idx, err := getIndex(bucket, ci.Kind, ci.Name)
+	if err != nil {
+		if err == index.ErrNotFound {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	// tuples all have the same number of fields, a full tuple
+	// only prefixes the keys of the records holding it
+	ids, err := idx.Prefix(val, nil)
+	if err != nil {
+		return err
+	}
+
+	return n.oneOf(tx, bucket, bucketName, ids, to)
 }
 
 // Find returns one or more records by the specified index
@@ -133,21 +214,42 @@ func (n *node) Find(fieldName string, value interface{}, to interface{}, options
 		return ErrNoName
 	}
 
-	ref := reflect.Indirect(reflect.New(sink.elemType))
-	cfg, err := extractSingleField(&ref, fieldName)
+	opts := index.NewOptions()
+	for _, fn := range options {
+		fn(opts)
+	}
+
+	err = checkAfter(opts)
 	if err != nil {
 		return err
 	}
 
-	opts := index.NewOptions()
-	for _, fn := range options {
-		fn(opts)
+	if ci := compoundIndexByName(sink.elemType, fieldName); ci != nil {
+		val, err := ci.tuple(value, n.codec)
+		if err != nil {
+			return err
+		}
+
+		return n.readTx(func(tx *bolt.Tx) error {
+			if n.GetBucket(tx, bucketName) == nil {
+				return ErrNotFound
+			}
+
+			return n.prefix(tx, bucketName, fieldName, ci.Kind, sink, val, opts)
+		})
+	}
+
+	ref := reflect.Indirect(reflect.New(sink.elemType))
+	cfg, err := extractSingleField(&ref, fieldName)
+	if err != nil {
+		return err
 	}
 
 	field, ok := cfg.Fields[fieldName]
-	if !ok || (!field.IsID && (field.Index == "" || value == nil)) {
+	if !ok || (!field.IsID && (!field.hasLookupIndex() || value == nil)) {
 		query := newQuery(n, q.Eq(fieldName, value))
 		query.Skip(opts.Skip).Limit(opts.Limit)
+		query.after = opts.After
 
 		if opts.Reverse {
 			query.Reverse()
@@ -164,27 +266,32 @@ func (n *node) Find(fieldName string, value interface{}, to interface{}, options
 		return sink.flush()
 	}
 
-	val, err := toBytes(value, n.codec)
+	val, err := toFieldIndexBytes(value, field, n.codec)
 	if err != nil {
 		return err
 	}
 
 	return n.readTx(func(tx *bolt.Tx) error {
-		return n.find(tx, bucketName, fieldName, cfg, sink, val, opts)
+		if n.hasLegacyIndexes(tx, bucketName) {
+			return n.scan(tx, q.Eq(fieldName, value), sink, opts)
+		}
+
+		return n.find(tx, bucketName, fieldName, field.Index, sink, val, opts)
 	})
 }
 
-func (n *node) find(tx *bolt.Tx, bucketName, fieldName string, cfg *structConfig, sink *listSink, val []byte, opts *index.Options) error {
+func (n *node) find(tx *bolt.Tx, bucketName, fieldName, kind string, sink *listSink, val []byte, opts *index.Options) error {
 	bucket := n.GetBucket(tx, bucketName)
 	if bucket == nil {
 		return ErrNotFound
 	}
-	idx, err := getIndex(bucket, cfg.Fields[fieldName].Index, fieldName)
+	idx, err := getIndex(bucket, kind, fieldName)
 	if err != nil {
 		return err
 	}
 
-	list, err := idx.All(val, opts)
+	sorter := newSorter(n, sink)
+	list, err := idx.All(val, sorter.indexOptions(opts))
 	if err != nil {
 		if err == index.ErrNotFound {
 			return ErrNotFound
@@ -194,16 +301,24 @@ func (n *node) find(tx *bolt.Tx, bucketName, fieldName string, cfg *structConfig
 
 	sink.results = reflect.MakeSlice(reflect.Indirect(sink.ref).Type(), len(list), len(list))
 
-	sorter := newSorter(n, sink)
 	for i := range list {
+		if err := n.ctxErr(); err != nil {
+			return err
+		}
+
 		raw := bucket.Get(list[i])
 		if raw == nil {
 			return ErrNotFound
 		}
 
-		if _, err := sorter.filter(nil, bucket, list[i], raw); err != nil {
+		stop, err := sorter.filter(nil, bucket, list[i], raw)
+		if err != nil {
 			return err
 		}
+
+		if stop {
+			break
+		}
 	}
 
 	return sorter.flush()
@@ -243,6 +358,11 @@ func (n *node) AllByIndex(fieldName string, to interface{}, options ...func(*ind
 		fn(opts)
 	}
 
+	err = checkAfter(opts)
+	if err != nil {
+		return err
+	}
+
 	return n.readTx(func(tx *bolt.Tx) error {
 		return n.allByIndex(tx, fieldName, cfg, &ref, opts)
 	})
@@ -264,7 +384,13 @@ func (n *node) allByIndex(tx *bolt.Tx, fieldName string, cfg *structConfig, ref
 		return err
 	}
 
-	list, err := idx.AllRecords(opts)
+	sink, err := newListSink(n, ref.Interface())
+	if err != nil {
+		return err
+	}
+
+	sorter := newSorter(n, sink)
+	list, err := idx.AllRecords(sorter.indexOptions(opts))
 	if err != nil {
 		if err == index.ErrNotFound {
 			return ErrNotFound
@@ -272,22 +398,35 @@ func (n *node) allByIndex(tx *bolt.Tx, fieldName string, cfg *structConfig, ref
 		return err
 	}
 
-	results := reflect.MakeSlice(reflect.Indirect(*ref).Type(), len(list), len(list))
+	sink.results = reflect.MakeSlice(reflect.Indirect(*ref).Type(), len(list), len(list))
 
 	for i := range list {
+		if err := n.ctxErr(); err != nil {
+			return err
+		}
+
 		raw := bucket.Get(list[i])
 		if raw == nil {
 			return ErrNotFound
 		}
 
-		err = n.codec.Unmarshal(raw, results.Index(i).Addr().Interface())
+		stop, err := sorter.filter(nil, bucket, list[i], raw)
 		if err != nil {
 			return err
 		}
+
+		if stop {
+			break
+		}
 	}
 
-	reflect.Indirect(*ref).Set(results)
-	return nil
+	err = sorter.flush()
+	if err == ErrNotFound {
+		reflect.Indirect(*ref).Set(reflect.MakeSlice(reflect.Indirect(*ref).Type(), 0, 0))
+		return nil
+	}
+
+	return err
 }
 
 // All gets all the records of a bucket.
@@ -298,12 +437,19 @@ func (n *node) All(to interface{}, options ...func(*index.Options)) error {
 		fn(opts)
 	}
 
-	query := newQuery(n, nil).Limit(opts.Limit).Skip(opts.Skip)
+	err := checkAfter(opts)
+	if err != nil {
+		return err
+	}
+
+	query := newQuery(n, nil)
+	query.Limit(opts.Limit).Skip(opts.Skip)
+	query.after = opts.After
 	if opts.Reverse {
 		query.Reverse()
 	}
 
-	err := query.Find(to)
+	err = query.Find(to)
 	if err != nil && err != ErrNotFound {
 		return err
 	}
@@ -328,21 +474,43 @@ func (n *node) Range(fieldName string, min, max, to interface{}, options ...func
 		return ErrNoName
 	}
 
-	ref := reflect.Indirect(reflect.New(sink.elemType))
-	cfg, err := extractSingleField(&ref, fieldName)
+	opts := index.NewOptions()
+	for _, fn := range options {
+		fn(opts)
+	}
+
+	err = checkAfter(opts)
 	if err != nil {
 		return err
 	}
 
-	opts := index.NewOptions()
-	for _, fn := range options {
-		fn(opts)
+	if ci := compoundIndexByName(sink.elemType, fieldName); ci != nil {
+		mn, err := ci.tuple(min, n.codec)
+		if err != nil {
+			return err
+		}
+
+		mx, err := ci.tuple(max, n.codec)
+		if err != nil {
+			return err
+		}
+
+		return n.readTx(func(tx *bolt.Tx) error {
+			return n.rnge(tx, bucketName, fieldName, ci.Kind, sink, mn, tupleUpperBound(mx), opts)
+		})
+	}
+
+	ref := reflect.Indirect(reflect.New(sink.elemType))
+	cfg, err := extractSingleField(&ref, fieldName)
+	if err != nil {
+		return err
 	}
 
 	field, ok := cfg.Fields[fieldName]
-	if !ok || (!field.IsID && field.Index == "") {
+	if !ok || (!field.IsID && !field.hasLookupIndex()) {
 		query := newQuery(n, q.And(q.Gte(fieldName, min), q.Lte(fieldName, max)))
 		query.Skip(opts.Skip).Limit(opts.Limit)
+		query.after = opts.After
 
 		if opts.Reverse {
 			query.Reverse()
@@ -359,49 +527,62 @@ func (n *node) Range(fieldName string, min, max, to interface{}, options ...func
 		return sink.flush()
 	}
 
-	mn, err := toBytes(min, n.codec)
+	mn, err := toFieldIndexBytes(min, field, n.codec)
 	if err != nil {
 		return err
 	}
 
-	mx, err := toBytes(max, n.codec)
+	mx, err := toFieldIndexBytes(max, field, n.codec)
 	if err != nil {
 		return err
 	}
 
 	return n.readTx(func(tx *bolt.Tx) error {
-		return n.rnge(tx, bucketName, fieldName, cfg, sink, mn, mx, opts)
+		if n.hasLegacyIndexes(tx, bucketName) {
+			return n.scan(tx, q.And(q.Gte(fieldName, min), q.Lte(fieldName, max)), sink, opts)
+		}
+
+		return n.rnge(tx, bucketName, fieldName, field.Index, sink, mn, mx, opts)
 	})
 }
 
-func (n *node) rnge(tx *bolt.Tx, bucketName, fieldName string, cfg *structConfig, sink *listSink, min, max []byte, opts *index.Options) error {
+func (n *node) rnge(tx *bolt.Tx, bucketName, fieldName, kind string, sink *listSink, min, max []byte, opts *index.Options) error {
 	bucket := n.GetBucket(tx, bucketName)
 	if bucket == nil {
 		reflect.Indirect(sink.ref).SetLen(0)
 		return nil
 	}
 
-	idx, err := getIndex(bucket, cfg.Fields[fieldName].Index, fieldName)
+	idx, err := getIndex(bucket, kind, fieldName)
 	if err != nil {
 		return err
 	}
 
-	list, err := idx.Range(min, max, opts)
+	sorter := newSorter(n, sink)
+	list, err := idx.Range(min, max, sorter.indexOptions(opts))
 	if err != nil {
 		return err
 	}
 
 	sink.results = reflect.MakeSlice(reflect.Indirect(sink.ref).Type(), len(list), len(list))
-	sorter := newSorter(n, sink)
 	for i := range list {
+		if err := n.ctxErr(); err != nil {
+			return err
+		}
+
 		raw := bucket.Get(list[i])
 		if raw == nil {
 			return ErrNotFound
 		}
 
-		if _, err := sorter.filter(nil, bucket, list[i], raw); err != nil {
+		stop, err := sorter.filter(nil, bucket, list[i], raw)
+		if err != nil {
 			return err
 		}
+
+		if stop {
+			break
+		}
 	}
 
 	return sorter.flush()
@@ -419,21 +600,33 @@ func (n *node) Prefix(fieldName string, prefix string, to interface{}, options .
 		return ErrNoName
 	}
 
-	ref := reflect.Indirect(reflect.New(sink.elemType))
-	cfg, err := extractSingleField(&ref, fieldName)
+	opts := index.NewOptions()
+	for _, fn := range options {
+		fn(opts)
+	}
+
+	err = checkAfter(opts)
 	if err != nil {
 		return err
 	}
 
-	opts := index.NewOptions()
-	for _, fn := range options {
-		fn(opts)
+	if ci := compoundIndexByName(sink.elemType, fieldName); ci != nil {
+		return n.readTx(func(tx *bolt.Tx) error {
+			return n.prefix(tx, bucketName, fieldName, ci.Kind, sink, ci.prefix(prefix), opts)
+		})
+	}
+
+	ref := reflect.Indirect(reflect.New(sink.elemType))
+	cfg, err := extractSingleField(&ref, fieldName)
+	if err != nil {
+		return err
 	}
 
 	field, ok := cfg.Fields[fieldName]
-	if !ok || (!field.IsID && field.Index == "") {
+	if !ok || (!field.IsID && !field.hasLookupIndex()) {
 		query := newQuery(n, q.Re(fieldName, fmt.Sprintf("^%s", prefix)))
 		query.Skip(opts.Skip).Limit(opts.Limit)
+		query.after = opts.After
 
 		if opts.Reverse {
 			query.Reverse()
@@ -450,49 +643,81 @@ func (n *node) Prefix(fieldName string, prefix string, to interface{}, options .
 		return sink.flush()
 	}
 
-	prfx, err := toBytes(prefix, n.codec)
+	prfx, err := toIndexBytes(prefix, n.codec)
 	if err != nil {
 		return err
 	}
 
 	return n.readTx(func(tx *bolt.Tx) error {
-		return n.prefix(tx, bucketName, fieldName, cfg, sink, prfx, opts)
+		if n.hasLegacyIndexes(tx, bucketName) {
+			return n.scan(tx, q.Re(fieldName, fmt.Sprintf("^%s", prefix)), sink, opts)
+		}
+
+		return n.prefix(tx, bucketName, fieldName, field.Index, sink, prfx, opts)
 	})
 }
 
-func (n *node) prefix(tx *bolt.Tx, bucketName, fieldName string, cfg *structConfig, sink *listSink, prefix []byte, opts *index.Options) error {
+func (n *node) prefix(tx *bolt.Tx, bucketName, fieldName, kind string, sink *listSink, prefix []byte, opts *index.Options) error {
 	bucket := n.GetBucket(tx, bucketName)
 	if bucket == nil {
 		reflect.Indirect(sink.ref).SetLen(0)
 		return nil
 	}
 
-	idx, err := getIndex(bucket, cfg.Fields[fieldName].Index, fieldName)
+	idx, err := getIndex(bucket, kind, fieldName)
 	if err != nil {
 		return err
 	}
 
-	list, err := idx.Prefix(prefix, opts)
+	sorter := newSorter(n, sink)
+	list, err := idx.Prefix(prefix, sorter.indexOptions(opts))
 	if err != nil {
 		return err
 	}
 
 	sink.results = reflect.MakeSlice(reflect.Indirect(sink.ref).Type(), len(list), len(list))
-	sorter := newSorter(n, sink)
 	for i := range list {
+		if err := n.ctxErr(); err != nil {
+			return err
+		}
+
 		raw := bucket.Get(list[i])
 		if raw == nil {
 			return ErrNotFound
 		}
 
-		if _, err := sorter.filter(nil, bucket, list[i], raw); err != nil {
+		stop, err := sorter.filter(nil, bucket, list[i], raw)
+		if err != nil {
 			return err
 		}
+
+		if stop {
+			break
+		}
 	}
 
 	return sorter.flush()
 }
 
+// hasLegacyIndexes reports whether the indexes of the given bucket must be rebuilt before being used.
+func (n *node) hasLegacyIndexes(tx *bolt.Tx, bucketName string) bool {
+	bucket := n.GetBucket(tx, bucketName)
+	return bucket != nil && hasLegacyIndexes(bucket)
+}
+
+// scan runs a query in the given transaction instead of using an index.
+func (n *node) scan(tx *bolt.Tx, matcher q.Matcher, sink sink, opts *index.Options) error {
+	query := newQuery(n, matcher)
+	query.Skip(opts.Skip).Limit(opts.Limit)
+	query.after = opts.After
+
+	if opts.Reverse {
+		query.Reverse()
+	}
+
+	return query.query(tx, sink)
+}
+
 // Count counts all the records of a bucket
 func (n *node) Count(data interface{}) (int, error) {
 	return n.Select().Count(data)
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/asdine/storm/v3/q"
 	bolt "go.etcd.io/bbolt"
 	"github.com/stretchr/testify/require"
 )
@@ -702,3 +703,218 @@ func TestPrefixWithID(t *testing.T) {
 	require.NoError(t, db.Prefix("ID", "1", &users))
 	require.Len(t, users, 2)
 }
+
+func TestRangeSortableValues(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	type Account struct {
+		ID        int       `storm:"id"`
+		Balance   int       `storm:"index"`
+		Rate      float64   `storm:"index"`
+		CreatedAt time.Time `storm:"index"`
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 20; i++ {
+		err := db.Save(&Account{
+			ID:        i,
+			Balance:   (i - 10) * 50,
+			Rate:      float64(i-10) / 4,
+			CreatedAt: start.Add(time.Duration(i) * time.Hour),
+		})
+		require.NoError(t, err)
+	}
+
+	var accounts []Account
+	err := db.Range("Balance", -100, 100, &accounts)
+	require.NoError(t, err)
+	require.Len(t, accounts, 4)
+	require.Equal(t, -100, accounts[0].Balance)
+	require.Equal(t, 100, accounts[3].Balance)
+
+	err = db.Range("Balance", int8(-100), int64(-50), &accounts, Reverse())
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	require.Equal(t, -50, accounts[0].Balance)
+
+	err = db.Range("Rate", -1.5, 0.75, &accounts)
+	require.NoError(t, err)
+	require.Len(t, accounts, 9)
+	require.Equal(t, -1.5, accounts[0].Rate)
+	require.Equal(t, 0.75, accounts[8].Rate)
+
+	err = db.Range("CreatedAt", start.Add(5*time.Hour), start.Add(7*time.Hour).In(time.FixedZone("X", 3600)), &accounts)
+	require.NoError(t, err)
+	require.Len(t, accounts, 3)
+	require.Equal(t, 5, accounts[0].ID)
+
+	err = db.Find("Balance", int16(-450), &accounts)
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	require.Equal(t, 1, accounts[0].ID)
+
+	err = db.Select(q.Gt("Rate", -2), q.Lt("Rate", -1)).Find(&accounts)
+	require.NoError(t, err)
+	require.Len(t, accounts, 3)
+
+	plan, err := db.Select(q.Gte("CreatedAt", start.Add(18*time.Hour))).Explain(&Account{})
+	require.NoError(t, err)
+	require.Equal(t, "CreatedAt", plan.Index)
+	require.Equal(t, 3, plan.ReadRows)
+}
+
+func TestNestedIndex(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	customers := []Customer{
+		{Name: "John", Address: Address{City: "Paris", ZipCode: "75001"}},
+		{Name: "Jane", Address: Address{City: "Lyon", ZipCode: "69001"}, Billing: &Address{City: "Paris", ZipCode: "75002"}},
+		{Name: "Jack", Address: Address{City: "Paris", ZipCode: "75003"}},
+	}
+
+	for i := range customers {
+		require.NoError(t, db.Save(&customers[i]))
+	}
+
+	err := db.Save(&Customer{Name: "Jim", Address: Address{City: "Nice", ZipCode: "75001"}})
+	require.Equal(t, ErrAlreadyExists, err)
+
+	var list []Customer
+	err = db.Find("Address.City", "Paris", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "John", list[0].Name)
+	require.Equal(t, "Jack", list[1].Name)
+
+	var customer Customer
+	err = db.One("Billing.ZipCode", "75002", &customer)
+	require.NoError(t, err)
+	require.Equal(t, "Jane", customer.Name)
+
+	err = db.Range("Address.ZipCode", "75000", "75999", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	err = db.Prefix("Address.City", "Ly", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	err = db.AllByIndex("Address.City", &list)
+	require.NoError(t, err)
+	require.Equal(t, "Jane", list[0].Name)
+
+	// fields that are not indexed are scanned
+	err = db.One("Address.Street", "", &customer)
+	require.NoError(t, err)
+
+	err = db.Find("Address.Country", "France", &list)
+	require.Error(t, err)
+
+	query := db.Select(q.Eq("Address.City", "Paris"), q.Eq("Billing.City", ""))
+	plan, err := query.Explain(new(Customer))
+	require.NoError(t, err)
+	require.Equal(t, "Address.City", plan.Index)
+
+	err = query.OrderBy("Address.ZipCode").Reverse().Find(&list)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "Jack", list[0].Name)
+	require.Equal(t, "John", list[1].Name)
+
+	// moving a record updates the index
+	err = db.UpdateField(&Customer{ID: 1}, "Address", Address{City: "Lyon", ZipCode: "69002"})
+	require.NoError(t, err)
+
+	err = db.Find("Address.City", "Lyon", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	err = db.Update(&Customer{ID: 1, Address: Address{City: "Nice"}})
+	require.NoError(t, err)
+
+	err = db.One("Address.ZipCode", "69002", &customer)
+	require.Equal(t, ErrNotFound, err)
+
+	err = db.One("Address.City", "Nice", &customer)
+	require.NoError(t, err)
+	require.Equal(t, "John", customer.Name)
+}
+
+func TestMultiIndex(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	posts := []Post{
+		{Title: "Storm", Tags: []string{"go", "db"}, Voters: map[int]bool{1: true, 2: true}},
+		{Title: "Bolt", Tags: []string{"db", "", "db"}, Voters: map[int]bool{2: true}},
+		{Title: "Gophers", Tags: []string{"go"}},
+		{Title: "Empty"},
+	}
+
+	for i := range posts {
+		require.NoError(t, db.Save(&posts[i]))
+	}
+
+	var list []Post
+	err := db.Find("Tags", "go", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "Storm", list[0].Title)
+	require.Equal(t, "Gophers", list[1].Title)
+
+	err = db.Find("Voters", 2, &list)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	var post Post
+	err = db.One("Tags", "db", &post)
+	require.NoError(t, err)
+	require.Equal(t, "Storm", post.Title)
+
+	// records are returned once
+	err = db.AllByIndex("Tags", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+
+	err = db.Range("Tags", "a", "z", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+
+	err = db.Prefix("Tags", "g", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	// all the values are updated
+	err = db.Update(&Post{ID: 1, Tags: []string{"storm"}})
+	require.NoError(t, err)
+
+	err = db.Find("Tags", "go", &list)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, "Gophers", list[0].Title)
+
+	err = db.One("Tags", "storm", &post)
+	require.NoError(t, err)
+	require.Equal(t, 1, post.ID)
+
+	err = db.UpdateField(&Post{ID: 3}, "Tags", []string(nil))
+	require.NoError(t, err)
+
+	err = db.Find("Tags", "go", &list)
+	require.Equal(t, ErrNotFound, err)
+
+	err = db.DeleteStruct(&Post{ID: 2})
+	require.NoError(t, err)
+
+	err = db.Find("Tags", "db", &list)
+	require.Equal(t, ErrNotFound, err)
+
+	err = db.Find("Voters", 2, &list)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	_, err = db.Token("Tags", &post)
+	require.Equal(t, ErrIncompatibleValue, err)
+}
@@ -0,0 +1,415 @@
+package storm
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/asdine/storm/v3/index"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// searchOr separates the alternatives of a search query.
+const searchOr = "OR"
+
+// An analyzer removes the stop words of a language and reduces its words to their stem.
+type analyzer struct {
+	stopWords map[string]bool
+	stem      func(string) string
+}
+
+// analyzers by language, the empty language only splits and lowercases the words.
+var analyzers = map[string]*analyzer{
+	"": {},
+	"english": {
+		stopWords: englishStopWords,
+		stem:      stemEnglish,
+	},
+}
+
+var englishStopWords = wordSet(`a an and are as at be but by for from has have he her his i if in into is it its
+	no not of on or our she so such that the their them then there these they this to was we were which will with you your`)
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// analyze splits the text into lowercase terms, removing the stop words and stemming
+// the terms of the given language.
+func analyze(text, language string) []string {
+	a := analyzers[language]
+
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isSeparator) {
+		if a.stopWords[word] {
+			continue
+		}
+
+		if a.stem != nil {
+			word = a.stem(word)
+		}
+
+		terms = append(terms, word)
+	}
+
+	return terms
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// stemEnglish removes the most common inflectional suffixes of an english word.
+func stemEnglish(word string) string {
+	switch {
+	case len(word) <= 3:
+		return word
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "xes"), strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ing") && len(word) > 5:
+		return undouble(word[:len(word)-3])
+	case strings.HasSuffix(word, "ed") && len(word) > 4:
+		return undouble(word[:len(word)-2])
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return word[:len(word)-1]
+	}
+
+	return word
+}
+
+// undouble removes the last letter of a word ending with a double consonant, as in "running".
+func undouble(word string) string {
+	n := len(word)
+	if n < 2 || word[n-1] != word[n-2] || strings.IndexByte("aeioulsz", word[n-1]) >= 0 {
+		return word
+	}
+
+	return word[:n-1]
+}
+
+// A searchClause matches the records matching all of its phrases.
+// The terms of each phrase must appear consecutively in one of the fields.
+type searchClause []string
+
+// parseSearch parses a query made of words and quoted phrases.
+// The words and phrases must all match, unless they are separated by OR.
+func parseSearch(query string) []searchClause {
+	var clauses []searchClause
+	var clause searchClause
+
+	for i, part := range strings.Split(query, `"`) {
+		// the parts of odd index are quoted
+		if i%2 == 1 {
+			clause = append(clause, part)
+			continue
+		}
+
+		for _, word := range strings.Fields(part) {
+			if word != searchOr {
+				clause = append(clause, word)
+				continue
+			}
+
+			if len(clause) > 0 {
+				clauses = append(clauses, clause)
+			}
+			clause = nil
+		}
+	}
+
+	if len(clause) > 0 {
+		clauses = append(clauses, clause)
+	}
+
+	return clauses
+}
+
+// A searchField is a field indexed in a full-text index.
+type searchField struct {
+	language string
+	idx      *index.FullTextIndex
+	count    int
+	avgLen   float64
+	postings map[string][]index.Posting
+}
+
+// Search returns the records whose fields tagged with fulltext match the given query,
+// the most relevant first. The query is made of words and of "quoted phrases" that must all
+// appear in the record, unless they are separated by OR. Words are analyzed like the fields,
+// and the records are ranked with BM25.
+// The Skip, Limit and Reverse options apply to the ranked records.
+func (n *node) Search(to interface{}, query string, options ...func(*index.Options)) error {
+	sink, err := newListSink(n, to)
+	if err != nil {
+		return err
+	}
+
+	bucketName := sink.bucketName()
+	if bucketName == "" {
+		return ErrNoName
+	}
+
+	opts := index.NewOptions()
+	for _, fn := range options {
+		fn(opts)
+	}
+
+	ref := reflect.New(sink.elemType)
+	cfg, err := extract(&ref)
+	if err != nil {
+		return err
+	}
+
+	var fields []*fieldConfig
+	for _, f := range cfg.Fields {
+		if f.Index == tagFullText {
+			fields = append(fields, f)
+		}
+	}
+
+	if len(fields) == 0 {
+		return ErrIdxNotFound
+	}
+
+	return n.readTx(func(tx *bolt.Tx) error {
+		bucket := n.GetBucket(tx, bucketName)
+		if bucket == nil {
+			return ErrNotFound
+		}
+
+		ids, err := n.search(bucket, fields, query)
+		if err != nil {
+			return err
+		}
+
+		return n.ranked(bucket, ids, sink, opts)
+	})
+}
+
+// ranked reads the records of the given IDs in the order of the list, or in reverse order,
+// and applies the skip and limit options to the records that are not filtered out.
+func (n *node) ranked(bucket *bolt.Bucket, ids [][]byte, sink *listSink, opts *index.Options) error {
+	if opts.Reverse {
+		for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+	}
+
+	sorter := newSorter(n, sink)
+	sorter.skip, sorter.limit = opts.Skip, opts.Limit
+
+	for _, id := range ids {
+		if err := n.ctxErr(); err != nil {
+			return err
+		}
+
+		raw := bucket.Get(id)
+		if raw == nil {
+			return ErrNotFound
+		}
+
+		stop, err := sorter.filter(nil, bucket, id, raw)
+		if err != nil {
+			return err
+		}
+
+		if stop {
+			break
+		}
+	}
+
+	return sorter.flush()
+}
+
+// search returns the IDs of the records matching the query, sorted by descending score.
+func (n *node) search(bucket *bolt.Bucket, fields []*fieldConfig, query string) ([][]byte, error) {
+	var list []*searchField
+	for _, f := range fields {
+		idx, err := getIndex(bucket, tagFullText, f.Name)
+		if err == index.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sf := searchField{
+			language: f.Analyzer,
+			idx:      idx.(*index.FullTextIndex),
+			postings: make(map[string][]index.Posting),
+		}
+
+		count, length := sf.idx.Stats()
+		if count == 0 {
+			continue
+		}
+		sf.count, sf.avgLen = count, float64(length)/float64(count)
+		list = append(list, &sf)
+	}
+
+	scores := make(map[string]float64)
+	for _, clause := range parseSearch(query) {
+		matched, err := matchClause(list, clause)
+		if err != nil {
+			return nil, err
+		}
+
+		for id, score := range matched {
+			scores[id] += score
+		}
+	}
+
+	ids := make([][]byte, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, []byte(id))
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		si, sj := scores[string(ids[i])], scores[string(ids[j])]
+		if si != sj {
+			return si > sj
+		}
+		return bytes.Compare(ids[i], ids[j]) < 0
+	})
+
+	return ids, nil
+}
+
+// matchClause returns the score of the records matching all the phrases of the clause,
+// each in any of the fields. Each phrase is analyzed in the language of the field,
+// the phrases made of stop words only are ignored.
+func matchClause(fields []*searchField, clause searchClause) (map[string]float64, error) {
+	var matched map[string]float64
+	for _, phrase := range clause {
+		var analyzed bool
+		scores := make(map[string]float64)
+		for _, sf := range fields {
+			terms := analyze(phrase, sf.language)
+			if len(terms) == 0 {
+				continue
+			}
+			analyzed = true
+
+			err := sf.match(terms, scores)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !analyzed {
+			continue
+		}
+
+		if matched == nil {
+			matched = scores
+			continue
+		}
+
+		for id, score := range matched {
+			if s, ok := scores[id]; ok {
+				matched[id] = score + s
+			} else {
+				delete(matched, id)
+			}
+		}
+	}
+
+	return matched, nil
+}
+
+// match adds to the given scores the score of the phrase in the records of the field containing it.
+func (sf *searchField) match(phrase []string, scores map[string]float64) error {
+	terms := make([][]index.Posting, len(phrase))
+	for i, term := range phrase {
+		postings, ok := sf.postings[term]
+		if !ok {
+			var err error
+			postings, err = sf.idx.Postings([]byte(term))
+			if err != nil {
+				return err
+			}
+			sf.postings[term] = postings
+		}
+
+		if len(postings) == 0 {
+			return nil
+		}
+		terms[i] = postings
+	}
+
+	// the documents containing the first term are checked for the others
+	for _, first := range terms[0] {
+		score := sf.score(first, len(terms[0]))
+		positions := first.Positions
+
+		for i := 1; i < len(terms) && len(positions) > 0; i++ {
+			p := findPosting(terms[i], first.ID)
+			if p == nil {
+				positions = nil
+				break
+			}
+
+			positions = followingPositions(positions, p.Positions, i)
+			score += sf.score(*p, len(terms[i]))
+		}
+
+		if len(positions) > 0 {
+			scores[string(first.ID)] += score
+		}
+	}
+
+	return nil
+}
+
+// score returns the BM25 score of a term in the document of the given posting.
+func (sf *searchField) score(p index.Posting, docCount int) float64 {
+	idf := math.Log(1 + (float64(sf.count-docCount)+0.5)/(float64(docCount)+0.5))
+	tf := float64(len(p.Positions))
+	norm := 1 - bm25B + bm25B*float64(sf.idx.DocLength(p.ID))/sf.avgLen
+	return idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+}
+
+// findPosting returns the posting of the given ID in a list sorted by ID, or nil.
+func findPosting(postings []index.Posting, id []byte) *index.Posting {
+	i := sort.Search(len(postings), func(i int) bool {
+		return bytes.Compare(postings[i].ID, id) >= 0
+	})
+
+	if i < len(postings) && bytes.Equal(postings[i].ID, id) {
+		return &postings[i]
+	}
+
+	return nil
+}
+
+// followingPositions returns the positions of the first term of a phrase that are followed
+// by the term of the given offset.
+func followingPositions(positions, next []int, offset int) []int {
+	var list []int
+	for _, pos := range positions {
+		i := sort.SearchInts(next, pos+offset)
+		if i < len(next) && next[i] == pos+offset {
+			list = append(list, pos)
+		}
+	}
+
+	return list
+}
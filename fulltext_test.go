@@ -0,0 +1,98 @@
+package storm
+
+import (
+	"testing"
+
+	"github.com/asdine/storm/v3/index"
+	"github.com/stretchr/testify/require"
+)
+
+type Product struct {
+	ID          int    `storm:"increment"`
+	Name        string `storm:"fulltext"`
+	Description string `storm:"fulltext=english"`
+	Price       int
+	Archived    bool `storm:"deleted"`
+}
+
+func searchNames(t *testing.T, n Node, query string, options ...func(*index.Options)) []string {
+	var products []Product
+	err := n.Search(&products, query, options...)
+	if err == ErrNotFound {
+		return nil
+	}
+	require.NoError(t, err)
+
+	var names []string
+	for _, p := range products {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestSearch(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Product{Name: "Running shoes", Description: "Light shoes for running on roads"}))
+	require.NoError(t, db.Save(&Product{Name: "Trail shoes", Description: "Shoes with a strong grip for running on trails"}))
+	require.NoError(t, db.Save(&Product{Name: "Rain jacket", Description: "A light jacket keeping you dry"}))
+	require.NoError(t, db.Save(&Product{Name: "Café crème", Description: "Coffee with cream"}))
+
+	// words are lowercased and stemmed in the fields analyzed in english
+	require.Equal(t, []string{"Running shoes", "Trail shoes"}, searchNames(t, db, "SHOES"))
+	require.Equal(t, []string{"Running shoes", "Trail shoes"}, searchNames(t, db, "runs"))
+	require.Equal(t, []string{"Trail shoes"}, searchNames(t, db, "trail"))
+	require.Equal(t, []string{"Café crème"}, searchNames(t, db, "café"))
+	require.Nil(t, searchNames(t, db, "the"))
+	require.Nil(t, searchNames(t, db, "boots"))
+
+	// all the words must match, in any field
+	require.Equal(t, []string{"Running shoes"}, searchNames(t, db, "light running"))
+	require.Equal(t, []string{"Rain jacket"}, searchNames(t, db, "rain light"))
+
+	// unless they are separated by OR
+	require.Equal(t, []string{"Rain jacket", "Trail shoes"}, searchNames(t, db, "jacket OR grip"))
+
+	// phrases must match consecutive words of a field, stop words are ignored
+	require.Equal(t, []string{"Running shoes"}, searchNames(t, db, `"running on roads"`))
+	require.Nil(t, searchNames(t, db, `"roads running"`))
+	require.Equal(t, []string{"Trail shoes"}, searchNames(t, db, `"strong grip" shoes`))
+
+	// the most relevant records come first, the shortest matching fields are the most relevant
+	require.Equal(t, []string{"Running shoes", "Rain jacket", "Trail shoes"}, searchNames(t, db, "running OR light"))
+	require.Equal(t, []string{"Rain jacket", "Running shoes"}, searchNames(t, db, "running OR light", Reverse(), Skip(1)))
+	require.Equal(t, []string{"Rain jacket"}, searchNames(t, db, "running OR light", Skip(1), Limit(1)))
+
+	// updated and deleted records are removed from the index
+	require.NoError(t, db.UpdateField(&Product{ID: 1}, "Description", "Cushioned"))
+	require.Equal(t, []string{"Trail shoes"}, searchNames(t, db, "run"))
+
+	require.NoError(t, db.DeleteStruct(&Product{ID: 2}))
+	require.Nil(t, searchNames(t, db, "run"))
+	require.Equal(t, []string{"Trail shoes"}, searchNames(t, db.IncludeDeleted(), "run"))
+
+	require.NoError(t, db.Purge(new(Product), 0))
+	require.NoError(t, db.Update(&Product{ID: 3, Description: "Waterproof"}))
+	require.Equal(t, []string{"Rain jacket"}, searchNames(t, db, "rain waterproof"))
+
+	// full-text indexes can't be used to look records up
+	var product Product
+	require.NoError(t, db.One("Name", "Rain jacket", &product))
+	require.Equal(t, 3, product.ID)
+
+	var users []User
+	require.Equal(t, ErrIdxNotFound, db.Search(&users, "john"))
+
+	type Invalid struct {
+		ID    int
+		Count int `storm:"fulltext"`
+	}
+	require.Equal(t, ErrBadFullText, db.Save(&Invalid{ID: 1}))
+
+	type Unknown struct {
+		ID   int
+		Name string `storm:"fulltext=klingon"`
+	}
+	require.Equal(t, ErrUnknownTag, db.Save(&Unknown{ID: 1}))
+}
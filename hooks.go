@@ -0,0 +1,120 @@
+package storm
+
+import (
+	"reflect"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Records can implement the following interfaces to be notified of their lifecycle.
+// The hooks receive a node bound to the transaction of the operation, which is rolled back
+// if they return an error. They can use it to read or write other records atomically.
+
+// BeforeSaver is implemented by records validating or modifying themselves before being saved
+// by Save, Update or UpdateField. When updating, the hook is called on the updated record.
+type BeforeSaver interface {
+	BeforeSave(Node) error
+}
+
+// AfterSaver is implemented by records notified after being saved by Save, Update or UpdateField.
+type AfterSaver interface {
+	AfterSave(Node) error
+}
+
+// BeforeDeleter is implemented by records notified before being deleted by DeleteStruct
+// or by the Delete method of a query.
+type BeforeDeleter interface {
+	BeforeDelete(Node) error
+}
+
+// AfterDeleter is implemented by records notified after being deleted by DeleteStruct
+// or by the Delete method of a query.
+type AfterDeleter interface {
+	AfterDelete(Node) error
+}
+
+// AfterLoader is implemented by records notified after being decoded by a finder or a query.
+// The hook is only called on the records returned to the caller, once they are skipped and limited.
+// It isn't called on the records of a query using Project, counted, aggregated or deleted.
+type AfterLoader interface {
+	AfterLoad(Node) error
+}
+
+// saveWithHooks saves the record, calling its BeforeSave and AfterSave hooks.
+// Its version is checked before BeforeSave, its version and timestamps are set after.
+func (n *node) saveWithHooks(tx *bolt.Tx, cfg *structConfig, data interface{}, update bool) error {
+	version, err := n.savedVersion(tx, cfg)
+	if err != nil {
+		return err
+	}
+
+	if h, ok := data.(BeforeSaver); ok {
+		err := h.BeforeSave(n.WithTransaction(tx))
+		if err != nil {
+			return err
+		}
+
+		// the hook may have modified any field, they are all indexed again
+		ref := reflect.ValueOf(data)
+		cfg, err = extract(&ref)
+		if err != nil {
+			return err
+		}
+
+		if update {
+			for _, f := range cfg.Fields {
+				f.ForceUpdate = true
+			}
+		}
+	}
+
+	setVersion(cfg, data, version+1)
+
+	err = n.setTimestamps(tx, cfg, data, update)
+	if err != nil {
+		return err
+	}
+
+	err = n.checkRefs(tx, cfg)
+	if err != nil {
+		return err
+	}
+
+	err = n.save(tx, cfg, data, update)
+	if err != nil {
+		return err
+	}
+
+	if h, ok := data.(AfterSaver); ok {
+		return h.AfterSave(n.WithTransaction(tx))
+	}
+
+	return nil
+}
+
+// beforeDelete calls the BeforeDelete hook of the record, if any.
+func (n *node) beforeDelete(tx *bolt.Tx, data interface{}) error {
+	if h, ok := data.(BeforeDeleter); ok {
+		return h.BeforeDelete(n.WithTransaction(tx))
+	}
+
+	return nil
+}
+
+// afterDelete calls the AfterDelete hook of the record, if any.
+func (n *node) afterDelete(tx *bolt.Tx, data interface{}) error {
+	if h, ok := data.(AfterDeleter); ok {
+		return h.AfterDelete(n.WithTransaction(tx))
+	}
+
+	return nil
+}
+
+// afterLoad calls the AfterLoad hook of the record, if any.
+func (n *node) afterLoad(tx *bolt.Tx, data interface{}) error {
+	if h, ok := data.(AfterLoader); ok {
+		return h.AfterLoad(n.WithTransaction(tx))
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package storm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+)
+
+var errEmptyName = errors.New("empty name")
+
+type HookedUser struct {
+	ID       int    `storm:"increment"`
+	Name     string `storm:"index"`
+	Slug     string `storm:"unique"`
+	Locked   bool
+	Revision int
+	Display  string `json:"-"`
+}
+
+func (u *HookedUser) BeforeSave(n Node) error {
+	if u.Name == "" {
+		return errEmptyName
+	}
+
+	u.Slug = strings.ToLower(u.Name)
+	u.Revision++
+	return nil
+}
+
+func (u *HookedUser) AfterSave(n Node) error {
+	return n.Set("audit", u.ID, u.Revision)
+}
+
+func (u *HookedUser) BeforeDelete(n Node) error {
+	if u.Locked {
+		return errors.New("locked")
+	}
+	return nil
+}
+
+func (u *HookedUser) AfterDelete(n Node) error {
+	return n.Delete("audit", u.ID)
+}
+
+func (u *HookedUser) AfterLoad(n Node) error {
+	u.Display = "User " + u.Name
+	return nil
+}
+
+// loadCounter counts the calls to its AfterLoad hook.
+type loadCounter struct {
+	ID   int `storm:"increment"`
+	Name string
+}
+
+var loadCount int
+
+func (c *loadCounter) AfterLoad(n Node) error {
+	loadCount++
+	return nil
+}
+
+func TestHooksAfterLoad(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, db.Save(&loadCounter{Name: string(rune('a' + i))}))
+	}
+
+	var list []loadCounter
+	loadCount = 0
+	require.NoError(t, db.Select().Limit(1).Find(&list))
+	require.Equal(t, 1, loadCount)
+
+	loadCount = 0
+	require.NoError(t, db.Select().Skip(8).Find(&list))
+	require.Len(t, list, 2)
+	require.Equal(t, 2, loadCount)
+
+	loadCount = 0
+	require.NoError(t, db.Select().OrderBy("Name").Reverse().Skip(2).Limit(3).Find(&list))
+	require.Equal(t, "h", list[0].Name)
+	require.Equal(t, 3, loadCount)
+
+	loadCount = 0
+	var first loadCounter
+	require.NoError(t, db.Select().OrderBy("Name").First(&first))
+	require.Equal(t, 1, loadCount)
+
+	loadCount = 0
+	count, err := db.Select().Count(new(loadCounter))
+	require.NoError(t, err)
+	require.Equal(t, 10, count)
+	_, err = db.Select().Distinct(new(loadCounter), "Name")
+	require.NoError(t, err)
+	require.NoError(t, db.Select(q.Eq("Name", "a")).Delete(new(loadCounter)))
+	require.Zero(t, loadCount)
+
+	require.NoError(t, db.Select().Limit(4).Each(new(loadCounter), func(interface{}) error {
+		return nil
+	}))
+	require.Equal(t, 4, loadCount)
+}
+
+func TestHooks(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	u := HookedUser{Name: "John"}
+	require.NoError(t, db.Save(&u))
+	require.Equal(t, "john", u.Slug)
+	require.Equal(t, 1, u.Revision)
+
+	// fields modified by the hook are indexed
+	var user HookedUser
+	require.NoError(t, db.One("Slug", "john", &user))
+	require.Equal(t, "User John", user.Display)
+
+	var revision int
+	require.NoError(t, db.Get("audit", 1, &revision))
+	require.Equal(t, 1, revision)
+
+	// the transaction is rolled back if a hook fails
+	err := db.Save(&HookedUser{})
+	require.Equal(t, errEmptyName, err)
+
+	count, err := db.Count(new(HookedUser))
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// hooks are called on the updated record
+	require.NoError(t, db.UpdateField(&HookedUser{ID: 1}, "Name", "Jack"))
+	require.NoError(t, db.One("Slug", "jack", &user))
+	require.Equal(t, 2, user.Revision)
+	require.Equal(t, ErrNotFound, db.One("Slug", "john", &user))
+
+	require.NoError(t, db.Update(&HookedUser{ID: 1, Locked: true}))
+	require.NoError(t, db.Get("audit", 1, &revision))
+	require.Equal(t, 3, revision)
+
+	var users []HookedUser
+	require.NoError(t, db.Find("Name", "Jack", &users))
+	require.Equal(t, "User Jack", users[0].Display)
+	require.NoError(t, db.AllByIndex("Name", &users))
+	require.Equal(t, "User Jack", users[0].Display)
+	require.NoError(t, db.Select(q.Eq("Name", "Jack")).Find(&users))
+	require.Equal(t, "User Jack", users[0].Display)
+
+	var names []map[string]interface{}
+	require.NoError(t, db.Select().Project(new(HookedUser), "Display").Find(&names))
+	require.Equal(t, "", names[0]["Display"])
+
+	require.NoError(t, db.One("ID", 1, &user))
+	require.EqualError(t, db.DeleteStruct(&user), "locked")
+	require.EqualError(t, db.Select().Delete(new(HookedUser)), "locked")
+
+	require.NoError(t, db.Save(&HookedUser{Name: "Jim"}))
+	require.NoError(t, db.Get("audit", 2, &revision))
+
+	require.NoError(t, db.Select(q.Eq("Name", "Jim")).Delete(new(HookedUser)))
+	require.Equal(t, ErrNotFound, db.Get("audit", 2, &revision))
+
+	user.Locked = false
+	require.NoError(t, db.DeleteStruct(&user))
+	require.Equal(t, ErrNotFound, db.Get("audit", 1, &revision))
+}
@@ -0,0 +1,314 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	fullTextTerms  = []byte("storm__terms")
+	fullTextDocs   = []byte("storm__docs")
+	fullTextCount  = []byte("storm__count")
+	fullTextLength = []byte("storm__length")
+)
+
+// NewFullTextIndex loads a FullTextIndex
+func NewFullTextIndex(parent *bolt.Bucket, indexName []byte) (*FullTextIndex, error) {
+	var err error
+	b := parent.Bucket(indexName)
+	if b == nil {
+		if !parent.Writable() {
+			return nil, ErrNotFound
+		}
+		b, err = parent.CreateBucket(indexName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	idx := FullTextIndex{IndexBucket: b}
+	for _, sub := range []struct {
+		bucket **bolt.Bucket
+		name   []byte
+	}{{&idx.Terms, fullTextTerms}, {&idx.Docs, fullTextDocs}} {
+		*sub.bucket = b.Bucket(sub.name)
+		if *sub.bucket != nil {
+			continue
+		}
+
+		if !b.Writable() {
+			return nil, ErrNotFound
+		}
+
+		*sub.bucket, err = b.CreateBucket(sub.name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &idx, nil
+}
+
+// FullTextIndex is an inverted index referencing the IDs of the documents containing each term,
+// with the positions of the term in the document.
+// The values added to the index are documents made of terms separated by spaces, as returned by an analyzer.
+// The other methods take a single term as value.
+type FullTextIndex struct {
+	IndexBucket *bolt.Bucket
+
+	// Positions of each term in each document, indexed by term and ID
+	Terms *bolt.Bucket
+
+	// Length and terms of each document, indexed by ID
+	Docs *bolt.Bucket
+}
+
+// A Posting is an occurrence of a term in a document.
+type Posting struct {
+	ID []byte
+
+	// Positions of the term in the document, in ascending order
+	Positions []int
+}
+
+// Add indexes the given document, replacing the one previously indexed with the same ID.
+// Empty documents are not indexed.
+func (idx *FullTextIndex) Add(value []byte, targetID []byte) error {
+	if len(targetID) == 0 {
+		return ErrNilParam
+	}
+
+	err := idx.RemoveID(targetID)
+	if err != nil {
+		return err
+	}
+
+	terms := strings.Fields(string(value))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	positions := make(map[string][]byte)
+	var distinct [][]byte
+	for i, term := range terms {
+		if _, ok := positions[term]; !ok {
+			distinct = append(distinct, []byte(term))
+		}
+		positions[term] = appendUvarint(positions[term], uint64(i))
+	}
+
+	for term, raw := range positions {
+		err = idx.Terms.Put(termKey([]byte(term), targetID), raw)
+		if err != nil {
+			return err
+		}
+	}
+
+	doc := appendUvarint(nil, uint64(len(terms)))
+	err = idx.Docs.Put(targetID, append(doc, encodeKeys(distinct)...))
+	if err != nil {
+		return err
+	}
+
+	return idx.updateStats(1, len(terms))
+}
+
+// Remove the documents containing the given term
+func (idx *FullTextIndex) Remove(value []byte) error {
+	postings, err := idx.Postings(value)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range postings {
+		err = idx.RemoveID(p.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveID removes the document of the given ID
+func (idx *FullTextIndex) RemoveID(id []byte) error {
+	doc := idx.Docs.Get(id)
+	if doc == nil {
+		return nil
+	}
+
+	length, n := binary.Uvarint(doc)
+	for _, term := range decodeKeys(doc[n:]) {
+		err := idx.Terms.Delete(termKey(term, id))
+		if err != nil {
+			return err
+		}
+	}
+
+	err := idx.Docs.Delete(id)
+	if err != nil {
+		return err
+	}
+
+	return idx.updateStats(-1, -int(length))
+}
+
+// Get the ID of the first document containing the given term
+func (idx *FullTextIndex) Get(value []byte) []byte {
+	prefix := termKey(value, nil)
+	k, _ := idx.Terms.Cursor().Seek(prefix)
+	if !bytes.HasPrefix(k, prefix) {
+		return nil
+	}
+
+	return append([]byte(nil), k[len(prefix):]...)
+}
+
+// All returns the IDs of the documents containing the given term
+func (idx *FullTextIndex) All(value []byte, opts *Options) ([][]byte, error) {
+	postings, err := idx.Postings(value)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([][]byte, len(postings))
+	for i := range postings {
+		list[i] = postings[i].ID
+	}
+
+	return paginate(list, opts), nil
+}
+
+// AllRecords returns the IDs of all the documents
+func (idx *FullTextIndex) AllRecords(opts *Options) ([][]byte, error) {
+	var list [][]byte
+	c := idx.Docs.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		list = append(list, k)
+	}
+
+	return paginate(list, opts), nil
+}
+
+// Range returns the IDs of the documents containing a term within the given range
+func (idx *FullTextIndex) Range(min []byte, max []byte, opts *Options) ([][]byte, error) {
+	return idx.terms(min, func(term []byte) bool {
+		return bytes.Compare(term, max) <= 0
+	}, opts), nil
+}
+
+// Prefix returns the IDs of the documents containing a term starting with the given prefix
+func (idx *FullTextIndex) Prefix(prefix []byte, opts *Options) ([][]byte, error) {
+	return idx.terms(prefix, func(term []byte) bool {
+		return bytes.HasPrefix(term, prefix)
+	}, opts), nil
+}
+
+// terms returns the IDs of the documents containing the terms following the given one,
+// as long as they are accepted by the given function. Each ID is returned once.
+func (idx *FullTextIndex) terms(from []byte, accept func([]byte) bool, opts *Options) [][]byte {
+	var list [][]byte
+	seen := make(idSet)
+
+	c := idx.Terms.Cursor()
+	for k, _ := c.Seek(from); k != nil; k, _ = c.Next() {
+		sep := bytes.IndexByte(k, 0)
+		if !accept(k[:sep]) {
+			break
+		}
+
+		if id := k[sep+1:]; !seen.has(id) {
+			list = append(list, id)
+		}
+	}
+
+	return paginate(list, opts)
+}
+
+// Postings returns the occurrences of the given term, sorted by ID
+func (idx *FullTextIndex) Postings(term []byte) ([]Posting, error) {
+	var list []Posting
+
+	prefix := termKey(term, nil)
+	c := idx.Terms.Cursor()
+	for k, v := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		p := Posting{ID: k[len(prefix):]}
+		for len(v) > 0 {
+			pos, n := binary.Uvarint(v)
+			if n <= 0 {
+				return nil, ErrNotFound
+			}
+			p.Positions = append(p.Positions, int(pos))
+			v = v[n:]
+		}
+
+		list = append(list, p)
+	}
+
+	return list, nil
+}
+
+// DocLength returns the number of terms of the document of the given ID
+func (idx *FullTextIndex) DocLength(id []byte) int {
+	doc := idx.Docs.Get(id)
+	if doc == nil {
+		return 0
+	}
+
+	length, _ := binary.Uvarint(doc)
+	return int(length)
+}
+
+// Stats returns the number of documents and their total number of terms
+func (idx *FullTextIndex) Stats() (count int, length int) {
+	c, _ := binary.Uvarint(idx.IndexBucket.Get(fullTextCount))
+	l, _ := binary.Uvarint(idx.IndexBucket.Get(fullTextLength))
+	return int(c), int(l)
+}
+
+func (idx *FullTextIndex) updateStats(count, length int) error {
+	c, l := idx.Stats()
+
+	err := idx.IndexBucket.Put(fullTextCount, appendUvarint(nil, uint64(c+count)))
+	if err != nil {
+		return err
+	}
+
+	return idx.IndexBucket.Put(fullTextLength, appendUvarint(nil, uint64(l+length)))
+}
+
+// termKey returns the key of the positions of a term in a document.
+// Terms don't contain null bytes, the keys of a term are sorted by ID.
+func termKey(term, id []byte) []byte {
+	key := make([]byte, 0, len(term)+1+len(id))
+	key = append(key, term...)
+	key = append(key, 0)
+	return append(key, id...)
+}
+
+// paginate applies the given options to a list of IDs.
+func paginate(list [][]byte, opts *Options) [][]byte {
+	if opts == nil {
+		return list
+	}
+
+	if opts.Reverse {
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+
+	if opts.Skip >= len(list) {
+		return nil
+	}
+	list = list[opts.Skip:]
+
+	if opts.Limit >= 0 && opts.Limit < len(list) {
+		list = list[:opts.Limit]
+	}
+
+	return list
+}
@@ -0,0 +1,87 @@
+package index_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/index"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestFullTextIndex(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "storm")
+	defer os.RemoveAll(dir)
+	db, _ := storm.Open(filepath.Join(dir, "storm.db"))
+	defer db.Close()
+
+	err := db.Bolt.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("test"))
+		require.NoError(t, err)
+
+		idx, err := index.NewFullTextIndex(b, []byte("ftindex1"))
+		require.NoError(t, err)
+
+		require.NoError(t, idx.Add([]byte("red wine red"), []byte("id1")))
+		require.NoError(t, idx.Add([]byte("white wine"), []byte("id2")))
+		require.NoError(t, idx.Add([]byte(""), []byte("id3")))
+		require.Equal(t, index.ErrNilParam, idx.Add([]byte("wine"), nil))
+
+		count, length := idx.Stats()
+		require.Equal(t, 2, count)
+		require.Equal(t, 5, length)
+		require.Equal(t, 3, idx.DocLength([]byte("id1")))
+
+		postings, err := idx.Postings([]byte("red"))
+		require.NoError(t, err)
+		require.Equal(t, []index.Posting{{ID: []byte("id1"), Positions: []int{0, 2}}}, postings)
+
+		ids, err := idx.All([]byte("wine"), nil)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id1"), []byte("id2")}, ids)
+		require.Equal(t, []byte("id1"), idx.Get([]byte("wine")))
+		require.Nil(t, idx.Get([]byte("beer")))
+
+		opts := index.NewOptions()
+		opts.Reverse = true
+		opts.Limit = 1
+		ids, err = idx.All([]byte("wine"), opts)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id2")}, ids)
+
+		ids, err = idx.Prefix([]byte("w"), nil)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id2"), []byte("id1")}, ids)
+
+		ids, err = idx.Range([]byte("a"), []byte("s"), nil)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id1")}, ids)
+
+		// documents are replaced
+		require.NoError(t, idx.Add([]byte("rose"), []byte("id1")))
+		ids, err = idx.All([]byte("red"), nil)
+		require.NoError(t, err)
+		require.Empty(t, ids)
+		count, length = idx.Stats()
+		require.Equal(t, 2, count)
+		require.Equal(t, 3, length)
+
+		require.NoError(t, idx.Remove([]byte("wine")))
+		ids, err = idx.AllRecords(nil)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id1")}, ids)
+
+		require.NoError(t, idx.RemoveID([]byte("id1")))
+		require.NoError(t, idx.RemoveID([]byte("id1")))
+		count, length = idx.Stats()
+		require.Zero(t, count)
+		require.Zero(t, length)
+
+		return nil
+	})
+
+	require.NoError(t, err)
+}
@@ -2,6 +2,7 @@ package index
 
 import (
 	"bytes"
+	"encoding/binary"
 
 	"github.com/asdine/storm/v3/internal"
 	bolt "go.etcd.io/bbolt"
@@ -33,11 +34,27 @@ func NewListIndex(parent *bolt.Bucket, indexName []byte) (*ListIndex, error) {
 	}, nil
 }
 
+// NewMultiListIndex loads a ListIndex that references several values for each ID.
+func NewMultiListIndex(parent *bolt.Bucket, indexName []byte) (*ListIndex, error) {
+	idx, err := NewListIndex(parent, indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.Multi = true
+	return idx, nil
+}
+
 // ListIndex is an index that references values and the corresponding IDs.
 type ListIndex struct {
 	Parent      *bolt.Bucket
 	IndexBucket *bolt.Bucket
 	IDs         *UniqueIndex
+
+	// Multi is true if an ID can be referenced by several values.
+	// Adding a value to a multi index keeps the values previously added for the same ID,
+	// and the IDs matching several values are only returned once.
+	Multi bool
 }
 
 // Add a value to the list index
@@ -49,6 +66,10 @@ func (idx *ListIndex) Add(newValue []byte, targetID []byte) error {
 		return ErrNilParam
 	}
 
+	if idx.Multi {
+		return idx.addMulti(newValue, targetID)
+	}
+
 	key := idx.IDs.Get(targetID)
 	if key != nil {
 		err := idx.IndexBucket.Delete(key)
@@ -77,28 +98,81 @@ func (idx *ListIndex) Add(newValue []byte, targetID []byte) error {
 	return idx.IndexBucket.Put(key, targetID)
 }
 
+// addMulti adds a value to the values referencing the given ID.
+func (idx *ListIndex) addMulti(newValue []byte, targetID []byte) error {
+	key := append(generatePrefix(newValue), targetID...)
+
+	keys := decodeKeys(idx.IDs.Get(targetID))
+	for _, k := range keys {
+		if bytes.Equal(k, key) {
+			return nil
+		}
+	}
+
+	err := idx.setKeys(targetID, append(keys, key))
+	if err != nil {
+		return err
+	}
+
+	return idx.IndexBucket.Put(key, targetID)
+}
+
+// setKeys records the keys of the values referencing the given ID in a multi index.
+func (idx *ListIndex) setKeys(targetID []byte, keys [][]byte) error {
+	err := idx.IDs.Remove(targetID)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	return idx.IDs.Add(targetID, encodeKeys(keys))
+}
+
 // Remove a value from the unique index
 func (idx *ListIndex) Remove(value []byte) error {
 	var err error
-	var keys [][]byte
+	var keys, ids [][]byte
 
 	c := idx.IndexBucket.Cursor()
 	prefix := generatePrefix(value)
 
-	for k, _ := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+	for k, id := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, id = c.Next() {
 		keys = append(keys, k)
+		ids = append(ids, append([]byte(nil), id...))
 	}
 
-	for _, k := range keys {
+	for i, k := range keys {
 		err = idx.IndexBucket.Delete(k)
 		if err != nil {
 			return err
 		}
+
+		if idx.Multi {
+			err = idx.removeKey(ids[i], k)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if idx.Multi {
+		return nil
 	}
 
 	return idx.IDs.RemoveID(value)
 }
 
+// removeKey removes a key from the keys referencing the given ID in a multi index.
+func (idx *ListIndex) removeKey(targetID []byte, key []byte) error {
+	keys := decodeKeys(idx.IDs.Get(targetID))
+	for i, k := range keys {
+		if bytes.Equal(k, key) {
+			return idx.setKeys(targetID, append(keys[:i], keys[i+1:]...))
+		}
+	}
+
+	return nil
+}
+
 // RemoveID removes an ID from the list index
 func (idx *ListIndex) RemoveID(targetID []byte) error {
 	value := idx.IDs.Get(targetID)
@@ -106,6 +180,17 @@ func (idx *ListIndex) RemoveID(targetID []byte) error {
 		return nil
 	}
 
+	if idx.Multi {
+		for _, k := range decodeKeys(value) {
+			err := idx.IndexBucket.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+
+		return idx.IDs.Remove(targetID)
+	}
+
 	err := idx.IndexBucket.Delete(value)
 	if err != nil {
 		return err
@@ -149,6 +234,8 @@ func (idx *ListIndex) All(value []byte, opts *Options) ([][]byte, error) {
 		list = make([][]byte, 0, count)
 	}
 
+	k, id = internal.SeekAfter(c, cur.Reverse, idx.after(opts), k, id)
+
 	for ; bytes.HasPrefix(k, prefix); k, id = cur.Next() {
 		if opts != nil && opts.Skip > 0 {
 			opts.Skip--
@@ -173,10 +260,11 @@ func (idx *ListIndex) All(value []byte, opts *Options) ([][]byte, error) {
 func (idx *ListIndex) AllRecords(opts *Options) ([][]byte, error) {
 	var list [][]byte
 
-	c := internal.Cursor{C: idx.IndexBucket.Cursor(), Reverse: opts != nil && opts.Reverse}
+	c := internal.Cursor{C: idx.IndexBucket.Cursor(), Reverse: opts != nil && opts.Reverse, After: idx.after(opts)}
+	seen := idx.newSeen()
 
 	for k, id := c.First(); k != nil; k, id = c.Next() {
-		if id == nil || bytes.Equal(k, []byte("storm__ids")) {
+		if id == nil || bytes.Equal(k, []byte("storm__ids")) || seen.has(id) {
 			continue
 		}
 
@@ -212,10 +300,12 @@ func (idx *ListIndex) Range(min []byte, max []byte, opts *Options) ([][]byte, er
 			pos := bytes.LastIndex(val, []byte("__"))
 			return bytes.Compare(val[:pos], limit)
 		},
+		After: idx.after(opts),
 	}
+	seen := idx.newSeen()
 
 	for k, id := c.First(); c.Continue(k); k, id = c.Next() {
-		if id == nil || bytes.Equal(k, []byte("storm__ids")) {
+		if id == nil || bytes.Equal(k, []byte("storm__ids")) || seen.has(id) {
 			continue
 		}
 
@@ -246,10 +336,12 @@ func (idx *ListIndex) Prefix(prefix []byte, opts *Options) ([][]byte, error) {
 		C:       idx.IndexBucket.Cursor(),
 		Reverse: opts != nil && opts.Reverse,
 		Prefix:  prefix,
+		After:   idx.after(opts),
 	}
+	seen := idx.newSeen()
 
 	for k, id := c.First(); k != nil && c.Continue(k); k, id = c.Next() {
-		if id == nil || bytes.Equal(k, []byte("storm__ids")) {
+		if id == nil || bytes.Equal(k, []byte("storm__ids")) || seen.has(id) {
 			continue
 		}
 
@@ -271,6 +363,72 @@ func (idx *ListIndex) Prefix(prefix []byte, opts *Options) ([][]byte, error) {
 	return list, nil
 }
 
+// after returns the key of the position set in the options, if any.
+func (idx *ListIndex) after(opts *Options) []byte {
+	if opts == nil || opts.After == nil {
+		return nil
+	}
+
+	return append(generatePrefix(opts.After.Value), opts.After.ID...)
+}
+
+// newSeen returns the set of IDs already returned by a multi index, or nil for other indexes.
+func (idx *ListIndex) newSeen() idSet {
+	if !idx.Multi {
+		return nil
+	}
+
+	return make(idSet)
+}
+
+type idSet map[string]struct{}
+
+// has reports whether the given ID is in the set and adds it if not.
+func (s idSet) has(id []byte) bool {
+	if s == nil {
+		return false
+	}
+
+	if _, ok := s[string(id)]; ok {
+		return true
+	}
+
+	s[string(id)] = struct{}{}
+	return false
+}
+
+// encodeKeys concatenates the given keys, each prefixed by its length.
+func encodeKeys(keys [][]byte) []byte {
+	var buf []byte
+	for _, k := range keys {
+		buf = appendUvarint(buf, uint64(len(k)))
+		buf = append(buf, k...)
+	}
+
+	return buf
+}
+
+func decodeKeys(raw []byte) [][]byte {
+	var keys [][]byte
+	for len(raw) > 0 {
+		l, n := binary.Uvarint(raw)
+		if n <= 0 || uint64(len(raw)-n) < l {
+			break
+		}
+
+		keys = append(keys, append([]byte(nil), raw[n:n+int(l)]...))
+		raw = raw[n+int(l):]
+	}
+
+	return keys
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
 func generatePrefix(value []byte) []byte {
 	prefix := make([]byte, len(value)+2)
 	var i int
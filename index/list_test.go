@@ -404,6 +404,27 @@ func TestListIndexRange(t *testing.T) {
 		require.NoError(t, err)
 		assertEncodedIntListEqual(t, []int{7, 6}, list)
 
+		// open bounds
+		min, _ = gob.Codec.Marshal(7)
+		list, err = idx.Range(min, nil, nil)
+		require.NoError(t, err)
+		assertEncodedIntListEqual(t, []int{7, 9}, list)
+
+		max, _ = gob.Codec.Marshal(2)
+		list, err = idx.Range(nil, max, nil)
+		require.NoError(t, err)
+		assertEncodedIntListEqual(t, []int{0, 1, 2}, list)
+
+		opts = index.NewOptions()
+		opts.Reverse = true
+		list, err = idx.Range(nil, max, opts)
+		require.NoError(t, err)
+		assertEncodedIntListEqual(t, []int{2, 1, 0}, list)
+
+		list, err = idx.Range(min, nil, opts)
+		require.NoError(t, err)
+		assertEncodedIntListEqual(t, []int{9, 7}, list)
+
 		return nil
 	})
 }
@@ -495,3 +516,120 @@ func countItems(t *testing.T, bucket *bolt.Bucket) int {
 
 	return count
 }
+
+func TestListIndexAfter(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "storm")
+	defer os.RemoveAll(dir)
+	db, _ := storm.Open(filepath.Join(dir, "storm.db"))
+	defer db.Close()
+
+	db.Bolt.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("test"))
+		require.NoError(t, err)
+
+		idx, err := index.NewListIndex(b, []byte("lindex1"))
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			val := []byte(fmt.Sprintf("%d", i%3))
+			err = idx.Add(val, []byte(fmt.Sprintf("id%d", i)))
+			require.NoError(t, err)
+		}
+
+		opts := index.NewOptions()
+		opts.After = &index.Position{Value: []byte("1"), ID: []byte("id4")}
+
+		ids, err := idx.All([]byte("1"), opts)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id7")}, ids)
+
+		ids, err = idx.AllRecords(opts)
+		require.NoError(t, err)
+		require.Len(t, ids, 4)
+		require.Equal(t, []byte("id7"), ids[0])
+		require.Equal(t, []byte("id2"), ids[1])
+
+		ids, err = idx.Range([]byte("0"), []byte("1"), opts)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id7")}, ids)
+
+		ids, err = idx.Prefix([]byte("2"), opts)
+		require.NoError(t, err)
+		require.Len(t, ids, 3)
+
+		opts.Reverse = true
+		ids, err = idx.All([]byte("1"), opts)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id1")}, ids)
+
+		ids, err = idx.Range([]byte("1"), []byte("2"), opts)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id1")}, ids)
+
+		ids, err = idx.AllRecords(opts)
+		require.NoError(t, err)
+		require.Len(t, ids, 5)
+		return nil
+	})
+}
+
+func TestMultiListIndex(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "bolt")
+	defer os.RemoveAll(dir)
+	db, _ := storm.Open(filepath.Join(dir, "storm.db"))
+	defer db.Close()
+
+	db.Bolt.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("test"))
+		require.NoError(t, err)
+
+		idx, err := index.NewMultiListIndex(b, []byte("mindex1"))
+		require.NoError(t, err)
+		require.True(t, idx.Multi)
+
+		for _, v := range []string{"go", "db", "go"} {
+			err = idx.Add([]byte(v), []byte("id1"))
+			require.NoError(t, err)
+		}
+		err = idx.Add([]byte("go"), []byte("id2"))
+		require.NoError(t, err)
+		err = idx.Add([]byte("bolt"), []byte("id2"))
+		require.NoError(t, err)
+		require.Equal(t, 4, countItems(t, idx.IndexBucket))
+
+		list, err := idx.All([]byte("go"), nil)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id1"), []byte("id2")}, list)
+
+		// IDs are returned once, at their first value
+		list, err = idx.AllRecords(nil)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id2"), []byte("id1")}, list)
+
+		list, err = idx.Range([]byte("c"), []byte("z"), &index.Options{Limit: -1, Skip: 1})
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id2")}, list)
+
+		list, err = idx.Prefix([]byte(""), nil)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+
+		err = idx.Remove([]byte("go"))
+		require.NoError(t, err)
+		require.Equal(t, 2, countItems(t, idx.IndexBucket))
+
+		list, err = idx.All([]byte("bolt"), nil)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id2")}, list)
+
+		err = idx.RemoveID([]byte("id1"))
+		require.NoError(t, err)
+		require.Equal(t, 1, countItems(t, idx.IndexBucket))
+
+		err = idx.RemoveID([]byte("id2"))
+		require.NoError(t, err)
+		require.Equal(t, 0, countItems(t, idx.IndexBucket))
+		require.Nil(t, idx.IDs.Get([]byte("id2")))
+		return nil
+	})
+}
@@ -12,4 +12,16 @@ type Options struct {
 	Limit   int
 	Skip    int
 	Reverse bool
+
+	// After skips every record up to the given position, included.
+	After *Position
+}
+
+// A Position locates a record in an index.
+type Position struct {
+	// Indexed value of the record
+	Value []byte
+
+	// ID of the record
+	ID []byte
 }
@@ -0,0 +1,316 @@
+package index
+
+import (
+	"bytes"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	trigramGrams  = []byte("storm__grams")
+	trigramValues = []byte("storm__values")
+)
+
+// NewTrigramIndex loads a TrigramIndex
+func NewTrigramIndex(parent *bolt.Bucket, indexName []byte) (*TrigramIndex, error) {
+	var err error
+	b := parent.Bucket(indexName)
+	if b == nil {
+		if !parent.Writable() {
+			return nil, ErrNotFound
+		}
+		b, err = parent.CreateBucket(indexName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	idx := TrigramIndex{IndexBucket: b}
+	for _, sub := range []struct {
+		bucket **bolt.Bucket
+		name   []byte
+	}{{&idx.Grams, trigramGrams}, {&idx.Values, trigramValues}} {
+		*sub.bucket = b.Bucket(sub.name)
+		if *sub.bucket != nil {
+			continue
+		}
+
+		if !b.Writable() {
+			return nil, ErrNotFound
+		}
+
+		*sub.bucket, err = b.CreateBucket(sub.name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &idx, nil
+}
+
+// TrigramIndex references the IDs of the values containing each sequence of three characters,
+// to find the values containing a substring or similar to a given value.
+// Values are compared as is, they must be normalized by the caller, e.g. lowercased.
+type TrigramIndex struct {
+	IndexBucket *bolt.Bucket
+
+	// IDs of the values containing each trigram, indexed by trigram and ID
+	Grams *bolt.Bucket
+
+	// Values indexed by ID
+	Values *bolt.Bucket
+}
+
+// A Similarity is the similarity of a value with the one of the given ID, from 0 to 1.
+type Similarity struct {
+	ID    []byte
+	Score float64
+}
+
+// Add the value of the given ID, replacing the one previously added.
+func (idx *TrigramIndex) Add(value []byte, targetID []byte) error {
+	if len(value) == 0 || len(targetID) == 0 {
+		return ErrNilParam
+	}
+
+	err := idx.RemoveID(targetID)
+	if err != nil {
+		return err
+	}
+
+	for _, gram := range paddedTrigrams(value) {
+		err = idx.Grams.Put(gramKey(gram, targetID), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return idx.Values.Put(targetID, value)
+}
+
+// Remove the IDs of the given value
+func (idx *TrigramIndex) Remove(value []byte) error {
+	ids, err := idx.All(value, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err = idx.RemoveID(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveID removes the value of the given ID
+func (idx *TrigramIndex) RemoveID(id []byte) error {
+	value := idx.Values.Get(id)
+	if value == nil {
+		return nil
+	}
+
+	for _, gram := range paddedTrigrams(value) {
+		err := idx.Grams.Delete(gramKey(gram, id))
+		if err != nil {
+			return err
+		}
+	}
+
+	return idx.Values.Delete(id)
+}
+
+// Get the first ID of the given value
+func (idx *TrigramIndex) Get(value []byte) []byte {
+	ids, _ := idx.All(value, &Options{Limit: 1})
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return ids[0]
+}
+
+// All returns the IDs of the given value
+func (idx *TrigramIndex) All(value []byte, opts *Options) ([][]byte, error) {
+	return idx.matching(paddedTrigrams(value), func(v []byte) bool {
+		return bytes.Equal(v, value)
+	}, opts), nil
+}
+
+// AllRecords returns the IDs of all the values
+func (idx *TrigramIndex) AllRecords(opts *Options) ([][]byte, error) {
+	return idx.matching(nil, nil, opts), nil
+}
+
+// Range returns the IDs of the values within the given range, sorted by value
+func (idx *TrigramIndex) Range(min []byte, max []byte, opts *Options) ([][]byte, error) {
+	return idx.sorted(idx.matching(nil, func(v []byte) bool {
+		return bytes.Compare(v, min) >= 0 && bytes.Compare(v, max) <= 0
+	}, nil), opts), nil
+}
+
+// Prefix returns the IDs of the values starting with the given prefix, sorted by value
+func (idx *TrigramIndex) Prefix(prefix []byte, opts *Options) ([][]byte, error) {
+	var grams [][]byte
+	if len(prefix) > 0 {
+		// the values start with the trigrams of the prefix preceded by the padding
+		grams = trigrams(append([]byte("  "), prefix...))
+	}
+
+	return idx.sorted(idx.matching(grams, func(v []byte) bool {
+		return bytes.HasPrefix(v, prefix)
+	}, nil), opts), nil
+}
+
+// Candidates returns the IDs of the values containing all the trigrams of the given substrings, sorted by ID.
+// It is a superset of the values containing the substrings. Substrings shorter than three characters are ignored.
+func (idx *TrigramIndex) Candidates(substrings ...[]byte) [][]byte {
+	var grams [][]byte
+	for _, s := range substrings {
+		grams = append(grams, trigrams(s)...)
+	}
+
+	return idx.matching(grams, nil, nil)
+}
+
+// Similar returns the IDs of the values sharing at least the given ratio of their trigrams with the given value,
+// the most similar first. The similarity of two values is the number of trigrams they share
+// divided by the number of distinct trigrams they contain.
+func (idx *TrigramIndex) Similar(value []byte, threshold float64) []Similarity {
+	grams := paddedTrigrams(value)
+
+	shared := make(map[string]int)
+	for _, gram := range grams {
+		for _, id := range idx.gramIDs(gram) {
+			shared[string(id)]++
+		}
+	}
+
+	var list []Similarity
+	for id, n := range shared {
+		total := len(grams) + len(paddedTrigrams(idx.Values.Get([]byte(id)))) - n
+		score := float64(n) / float64(total)
+		if score >= threshold {
+			list = append(list, Similarity{ID: []byte(id), Score: score})
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score > list[j].Score
+		}
+		return bytes.Compare(list[i].ID, list[j].ID) < 0
+	})
+
+	return list
+}
+
+// matching returns the IDs of the values containing all the given trigrams and accepted
+// by the given function, sorted by ID. Nil trigrams or function accept all the values.
+func (idx *TrigramIndex) matching(grams [][]byte, accept func([]byte) bool, opts *Options) [][]byte {
+	var list [][]byte
+	if len(grams) == 0 {
+		c := idx.Values.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			list = append(list, k)
+		}
+	} else {
+		list = idx.gramIDs(grams[0])
+		for _, gram := range grams[1:] {
+			if len(list) == 0 {
+				break
+			}
+			list = intersect(list, idx.gramIDs(gram))
+		}
+	}
+
+	if accept != nil {
+		ids := list[:0]
+		for _, id := range list {
+			if accept(idx.Values.Get(id)) {
+				ids = append(ids, id)
+			}
+		}
+		list = ids
+	}
+
+	return paginate(list, opts)
+}
+
+// sorted sorts the given IDs by value, then by ID, and applies the options.
+func (idx *TrigramIndex) sorted(ids [][]byte, opts *Options) [][]byte {
+	sort.SliceStable(ids, func(i, j int) bool {
+		return bytes.Compare(idx.Values.Get(ids[i]), idx.Values.Get(ids[j])) < 0
+	})
+
+	return paginate(ids, opts)
+}
+
+// gramIDs returns the IDs of the values containing the given trigram, sorted by ID.
+func (idx *TrigramIndex) gramIDs(gram []byte) [][]byte {
+	var list [][]byte
+
+	prefix := gramKey(gram, nil)
+	c := idx.Grams.Cursor()
+	for k, _ := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		list = append(list, k[len(prefix):])
+	}
+
+	return list
+}
+
+// gramKey returns the key referencing an ID by one of its trigrams.
+// Trigrams are prefixed by their length, their keys are sorted by ID.
+func gramKey(gram, id []byte) []byte {
+	key := make([]byte, 0, 1+len(gram)+len(id))
+	key = append(key, byte(len(gram)))
+	key = append(key, gram...)
+	return append(key, id...)
+}
+
+// intersect returns the IDs found in both of the given sorted lists.
+func intersect(a, b [][]byte) [][]byte {
+	var list [][]byte
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch bytes.Compare(a[i], b[j]) {
+		case -1:
+			i++
+		case 1:
+			j++
+		default:
+			list = append(list, a[i])
+			i, j = i+1, j+1
+		}
+	}
+
+	return list
+}
+
+// trigrams returns the distinct sequences of three characters of the given value.
+func trigrams(value []byte) [][]byte {
+	runes := bytes.Runes(value)
+
+	var list [][]byte
+	seen := make(map[string]bool)
+	for i := 0; i+3 <= len(runes); i++ {
+		gram := string(runes[i : i+3])
+		if !seen[gram] {
+			seen[gram] = true
+			list = append(list, []byte(gram))
+		}
+	}
+
+	return list
+}
+
+// paddedTrigrams returns the trigrams of the given value preceded by two spaces and followed by one,
+// the values of less than three characters have trigrams and the first ones mark the start of the value.
+func paddedTrigrams(value []byte) [][]byte {
+	padded := make([]byte, 0, len(value)+3)
+	padded = append(padded, "  "...)
+	padded = append(padded, value...)
+	return trigrams(append(padded, ' '))
+}
@@ -0,0 +1,85 @@
+package index_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/index"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestTrigramIndex(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "storm")
+	defer os.RemoveAll(dir)
+	db, _ := storm.Open(filepath.Join(dir, "storm.db"))
+	defer db.Close()
+
+	err := db.Bolt.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("test"))
+		require.NoError(t, err)
+
+		idx, err := index.NewTrigramIndex(b, []byte("tindex1"))
+		require.NoError(t, err)
+
+		require.NoError(t, idx.Add([]byte("smartphone"), []byte("id1")))
+		require.NoError(t, idx.Add([]byte("phone case"), []byte("id2")))
+		require.NoError(t, idx.Add([]byte("headphones"), []byte("id3")))
+		require.NoError(t, idx.Add([]byte("tv"), []byte("id4")))
+		require.Equal(t, index.ErrNilParam, idx.Add(nil, []byte("id5")))
+		require.Equal(t, index.ErrNilParam, idx.Add([]byte("tv"), nil))
+
+		require.Equal(t, [][]byte{[]byte("id1"), []byte("id2"), []byte("id3")}, idx.Candidates([]byte("phone")))
+		require.Equal(t, [][]byte{[]byte("id3")}, idx.Candidates([]byte("phone"), []byte("head")))
+		require.Empty(t, idx.Candidates([]byte("tablet")))
+
+		// substrings of less than three characters are ignored
+		require.Len(t, idx.Candidates([]byte("tv")), 4)
+
+		ids, err := idx.All([]byte("tv"), nil)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id4")}, ids)
+		require.Equal(t, []byte("id2"), idx.Get([]byte("phone case")))
+		require.Nil(t, idx.Get([]byte("phone")))
+
+		ids, err = idx.Prefix([]byte("ph"), nil)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id2")}, ids)
+
+		ids, err = idx.Range([]byte("h"), []byte("t"), nil)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id3"), []byte("id2"), []byte("id1")}, ids)
+
+		opts := index.NewOptions()
+		opts.Skip = 1
+		opts.Reverse = true
+		ids, err = idx.AllRecords(opts)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id3"), []byte("id2"), []byte("id1")}, ids)
+
+		similar := idx.Similar([]byte("smartfone"), 0.3)
+		require.Len(t, similar, 1)
+		require.Equal(t, []byte("id1"), similar[0].ID)
+		require.InDelta(t, 0.5, similar[0].Score, 0.01)
+		require.Empty(t, idx.Similar([]byte("smartfone"), 0.6))
+
+		// values are replaced
+		require.NoError(t, idx.Add([]byte("tablet"), []byte("id1")))
+		require.Equal(t, [][]byte{[]byte("id1")}, idx.Candidates([]byte("tablet")))
+		require.Equal(t, [][]byte{[]byte("id2"), []byte("id3")}, idx.Candidates([]byte("phone")))
+
+		require.NoError(t, idx.Remove([]byte("tablet")))
+		require.NoError(t, idx.RemoveID([]byte("id2")))
+		require.NoError(t, idx.RemoveID([]byte("id2")))
+		ids, err = idx.AllRecords(nil)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("id3"), []byte("id4")}, ids)
+
+		return nil
+	})
+
+	require.NoError(t, err)
+}
@@ -77,6 +77,13 @@ func (idx *UniqueIndex) Get(value []byte) []byte {
 
 // All returns all the ids corresponding to the given value
 func (idx *UniqueIndex) All(value []byte, opts *Options) ([][]byte, error) {
+	if after := idx.after(opts); after != nil {
+		cmp := bytes.Compare(value, after)
+		if (!opts.Reverse && cmp <= 0) || (opts.Reverse && cmp >= 0) {
+			return nil, nil
+		}
+	}
+
 	id := idx.IndexBucket.Get(value)
 	if id != nil {
 		return [][]byte{id}, nil
@@ -89,7 +96,7 @@ func (idx *UniqueIndex) All(value []byte, opts *Options) ([][]byte, error) {
 func (idx *UniqueIndex) AllRecords(opts *Options) ([][]byte, error) {
 	var list [][]byte
 
-	c := internal.Cursor{C: idx.IndexBucket.Cursor(), Reverse: opts != nil && opts.Reverse}
+	c := internal.Cursor{C: idx.IndexBucket.Cursor(), Reverse: opts != nil && opts.Reverse, After: idx.after(opts)}
 
 	for val, ident := c.First(); val != nil; val, ident = c.Next() {
 		if opts != nil && opts.Skip > 0 {
@@ -122,6 +129,7 @@ func (idx *UniqueIndex) Range(min []byte, max []byte, opts *Options) ([][]byte,
 		CompareFn: func(val, limit []byte) int {
 			return bytes.Compare(val, limit)
 		},
+		After: idx.after(opts),
 	}
 
 	for val, ident := c.First(); val != nil && c.Continue(val); val, ident = c.Next() {
@@ -151,6 +159,7 @@ func (idx *UniqueIndex) Prefix(prefix []byte, opts *Options) ([][]byte, error) {
 		C:       idx.IndexBucket.Cursor(),
 		Reverse: opts != nil && opts.Reverse,
 		Prefix:  prefix,
+		After:   idx.after(opts),
 	}
 
 	for val, ident := c.First(); val != nil && c.Continue(val); val, ident = c.Next() {
@@ -181,3 +190,12 @@ func (idx *UniqueIndex) first() []byte {
 	}
 	return nil
 }
+
+// after returns the key of the position set in the options, if any.
+func (idx *UniqueIndex) after(opts *Options) []byte {
+	if opts == nil || opts.After == nil {
+		return nil
+	}
+
+	return opts.After.Value
+}
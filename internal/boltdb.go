@@ -10,15 +10,48 @@ import (
 type Cursor struct {
 	C       *bolt.Cursor
 	Reverse bool
+
+	// After skips all the keys up to the given one, included
+	After []byte
 }
 
 // First element
 func (c *Cursor) First() ([]byte, []byte) {
+	var k, v []byte
 	if c.Reverse {
-		return c.C.Last()
+		k, v = c.C.Last()
+	} else {
+		k, v = c.C.First()
+	}
+
+	return SeekAfter(c.C, c.Reverse, c.After, k, v)
+}
+
+// SeekAfter moves the cursor after the given key, in the order of the cursor,
+// unless the current key k is already after it.
+func SeekAfter(c *bolt.Cursor, reverse bool, after, k, v []byte) ([]byte, []byte) {
+	if after == nil || k == nil {
+		return k, v
+	}
+
+	cmp := bytes.Compare(k, after)
+	if (!reverse && cmp > 0) || (reverse && cmp < 0) {
+		return k, v
+	}
+
+	k, v = c.Seek(after)
+	if reverse {
+		if k == nil {
+			return c.Last()
+		}
+		return c.Prev()
+	}
+
+	if bytes.Equal(k, after) {
+		return c.Next()
 	}
 
-	return c.C.First()
+	return k, v
 }
 
 // Next element
@@ -30,18 +63,31 @@ func (c *Cursor) Next() ([]byte, []byte) {
 	return c.C.Next()
 }
 
-// RangeCursor that can be reversed
+// RangeCursor that can be reversed.
+// A nil Min or Max leaves the corresponding side of the range unbounded.
 type RangeCursor struct {
 	C         *bolt.Cursor
 	Reverse   bool
 	Min       []byte
 	Max       []byte
 	CompareFn func([]byte, []byte) int
+
+	// After skips all the keys up to the given one, included
+	After []byte
 }
 
 // First element
 func (c *RangeCursor) First() ([]byte, []byte) {
+	k, v := c.first()
+	return SeekAfter(c.C, c.Reverse, c.After, k, v)
+}
+
+func (c *RangeCursor) first() ([]byte, []byte) {
 	if c.Reverse {
+		if c.Max == nil {
+			return c.C.Last()
+		}
+
 		k, v := c.C.Seek(c.Max)
 
 		// If Seek doesn't find a key it goes to the next.
@@ -54,6 +100,10 @@ func (c *RangeCursor) First() ([]byte, []byte) {
 		return k, v
 	}
 
+	if c.Min == nil {
+		return c.C.First()
+	}
+
 	return c.C.Seek(c.Min)
 }
 
@@ -68,11 +118,15 @@ func (c *RangeCursor) Next() ([]byte, []byte) {
 
 // Continue tells if the loop needs to continue
 func (c *RangeCursor) Continue(val []byte) bool {
+	if val == nil {
+		return false
+	}
+
 	if c.Reverse {
-		return val != nil && c.CompareFn(val, c.Min) >= 0
+		return c.Min == nil || c.CompareFn(val, c.Min) >= 0
 	}
 
-	return val != nil && c.CompareFn(val, c.Max) <= 0
+	return c.Max == nil || c.CompareFn(val, c.Max) <= 0
 }
 
 // PrefixCursor that can be reversed
@@ -80,10 +134,18 @@ type PrefixCursor struct {
 	C       *bolt.Cursor
 	Reverse bool
 	Prefix  []byte
+
+	// After skips all the keys up to the given one, included
+	After []byte
 }
 
 // First element
 func (c *PrefixCursor) First() ([]byte, []byte) {
+	k, v := c.first()
+	return SeekAfter(c.C, c.Reverse, c.After, k, v)
+}
+
+func (c *PrefixCursor) first() ([]byte, []byte) {
 	var k, v []byte
 
 	for k, v = c.C.First(); k != nil && !bytes.HasPrefix(k, c.Prefix); k, v = c.C.Next() {
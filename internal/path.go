@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+)
+
+// FieldByPath returns the field of the given struct designated by a dotted path, e.g. "Address.City".
+// Nil pointers found on the path are read as zero values.
+// It returns an invalid value if there is no such field.
+func FieldByPath(v reflect.Value, path string) reflect.Value {
+	if !strings.Contains(path, ".") {
+		return v.FieldByName(path)
+	}
+
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v = reflect.Zero(v.Type().Elem())
+			} else {
+				v = v.Elem()
+			}
+		}
+
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+
+		v = v.FieldByName(name)
+		if !v.IsValid() {
+			return v
+		}
+	}
+
+	return v
+}
+
+// StructFieldByPath returns the struct field designated by a dotted path, e.g. "Address.City".
+// The returned field is exported only if every field of the path is exported.
+func StructFieldByPath(t reflect.Type, path string) (reflect.StructField, bool) {
+	if !strings.Contains(path, ".") {
+		return t.FieldByName(path)
+	}
+
+	var f reflect.StructField
+	var pkgPath string
+	for _, name := range strings.Split(path, ".") {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if t.Kind() != reflect.Struct {
+			return f, false
+		}
+
+		var ok bool
+		f, ok = t.FieldByName(name)
+		if !ok {
+			return f, false
+		}
+
+		if f.PkgPath != "" {
+			pkgPath = f.PkgPath
+		}
+		t = f.Type
+	}
+
+	f.PkgPath = pkgPath
+	return f, true
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type address struct {
+	City string
+	Geo  *geo
+}
+
+type geo struct {
+	Lat float64
+}
+
+type person struct {
+	Name    string
+	Address address
+	Work    *address
+	secret  address
+}
+
+func TestFieldByPath(t *testing.T) {
+	p := person{
+		Name:    "John",
+		Address: address{City: "Paris", Geo: &geo{Lat: 48.8}},
+	}
+	v := reflect.ValueOf(p)
+
+	require.Equal(t, "John", FieldByPath(v, "Name").Interface())
+	require.Equal(t, "Paris", FieldByPath(v, "Address.City").Interface())
+	require.Equal(t, 48.8, FieldByPath(v, "Address.Geo.Lat").Interface())
+
+	// nil pointers are read as zero values
+	require.Equal(t, "", FieldByPath(v, "Work.City").Interface())
+	require.Equal(t, 0.0, FieldByPath(v, "Work.Geo.Lat").Interface())
+
+	require.False(t, FieldByPath(v, "Address.Country").IsValid())
+	require.False(t, FieldByPath(v, "Name.Length").IsValid())
+	require.False(t, FieldByPath(v, "Age").IsValid())
+}
+
+func TestStructFieldByPath(t *testing.T) {
+	typ := reflect.TypeOf(person{})
+
+	f, ok := StructFieldByPath(typ, "Work.Geo.Lat")
+	require.True(t, ok)
+	require.Equal(t, "Lat", f.Name)
+	require.Equal(t, reflect.TypeOf(0.0), f.Type)
+	require.Empty(t, f.PkgPath)
+
+	f, ok = StructFieldByPath(typ, "secret.City")
+	require.True(t, ok)
+	require.NotEmpty(t, f.PkgPath)
+
+	_, ok = StructFieldByPath(typ, "Name.Length")
+	require.False(t, ok)
+
+	_, ok = StructFieldByPath(typ, "Address.Country")
+	require.False(t, ok)
+}
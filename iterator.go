@@ -0,0 +1,271 @@
+package storm
+
+import (
+	"reflect"
+
+	"github.com/asdine/storm/v3/internal"
+	"github.com/asdine/storm/v3/q"
+	bolt "go.etcd.io/bbolt"
+)
+
+// An Iterator reads the records matching a query one at a time.
+// Records are decoded when requested instead of being held in memory,
+// except when the query is ordered.
+//
+// The iterator reads the records in its own read transaction, or in the transaction
+// of the node, which is kept open until Close is called or Next returns false.
+// An iterator that is abandoned before the end must be closed, otherwise its transaction
+// is never released and BoltDB can't remap the file when the database grows.
+// Like with any BoltDB transaction, writing to the database while an iterator is open
+// may deadlock, and the iterated bucket must not be modified in the transaction of the node.
+type Iterator interface {
+	// Next decodes the next matching record into the given pointer to struct.
+	// It returns false when there are no more records or when an error occurred.
+	Next(to interface{}) bool
+
+	// Err returns the error that stopped the iteration, if any.
+	Err() error
+
+	// Close stops the iteration and releases the transaction.
+	Close() error
+}
+
+type iterator struct {
+	query  *query
+	sink   *iterSink
+	sorter *sorter
+	err    error
+	done   bool
+
+	// transaction of the iterator, rolled back on Close unless it belongs to the node
+	tx     *bolt.Tx
+	ownsTx bool
+
+	// the records are read either from the IDs selected by an index or with a cursor over the bucket
+	bucket  *bolt.Bucket
+	tree    q.Matcher
+	ids     [][]byte
+	cursor  *internal.Cursor
+	started bool
+
+	// set once all the records were passed to the sorter
+	flushed bool
+}
+
+func newIterator(q *query) *iterator {
+	return &iterator{
+		query: q,
+	}
+}
+
+func (it *iterator) Next(to interface{}) bool {
+	if it.done {
+		return false
+	}
+
+	ref := reflect.ValueOf(to)
+	if !ref.IsValid() || ref.Kind() != reflect.Ptr || ref.Elem().Kind() != reflect.Struct {
+		it.stop(ErrStructPtrNeeded)
+		return false
+	}
+
+	if it.sink == nil {
+		if err := it.start(ref.Elem().Type()); err != nil {
+			it.stop(err)
+			return false
+		}
+	} else if ref.Elem().Type() != it.sink.elemType {
+		it.stop(ErrIncompatibleValue)
+		return false
+	}
+
+	for len(it.sink.pending) == 0 {
+		if it.flushed {
+			it.Close()
+			return false
+		}
+
+		if err := it.advance(); err != nil {
+			it.stop(err)
+			return false
+		}
+	}
+
+	v := it.sink.pending[0]
+	it.sink.pending = it.sink.pending[1:]
+	ref.Elem().Set(v.Elem())
+	return true
+}
+
+// start opens the transaction and prepares the reading of the records, like query.collect.
+func (it *iterator) start(elemType reflect.Type) error {
+	q := it.query
+	if q.err != nil {
+		return q.err
+	}
+
+	if q.after != nil && len(q.orderBy) > 0 {
+		return ErrTokenWithOrderBy
+	}
+
+	if err := q.node.ctxErr(); err != nil {
+		return err
+	}
+
+	it.sink = &iterSink{
+		node:     q.node,
+		elemType: elemType,
+	}
+
+	if q.node.tx != nil {
+		it.tx = q.node.tx
+	} else {
+		tx, err := q.node.s.Bolt.Begin(false)
+		if err != nil {
+			return err
+		}
+		it.tx = tx
+		it.ownsTx = true
+	}
+
+	bucketName := q.bucket
+	if bucketName == "" {
+		bucketName = it.sink.bucketName()
+	}
+	it.bucket = q.node.GetBucket(it.tx, bucketName)
+
+	if q.limit == 0 || it.bucket == nil {
+		it.flushed = true
+		return nil
+	}
+
+	it.sorter = newSorter(q.node, it.sink)
+	it.sorter.orderBy = q.orderBy
+	it.sorter.reverse = q.reverse
+	it.sorter.skip = q.skip
+	it.sorter.limit = q.limit
+
+	plan, ids, err := q.lookupIndex(it.bucket, it.sink)
+	if err != nil {
+		return err
+	}
+	if plan != nil {
+		it.ids = q.idsAfter(ids)
+		it.tree = plan.residual
+		return nil
+	}
+
+	it.cursor = &internal.Cursor{C: it.bucket.Cursor(), Reverse: q.reverse}
+	if q.after != nil {
+		it.cursor.After = q.after.ID
+	}
+	it.tree = q.tree
+	return nil
+}
+
+// advance passes the next record of the bucket to the sorter,
+// and flushes the sorter once there are no more records to read.
+func (it *iterator) advance() error {
+	if err := it.query.node.ctxErr(); err != nil {
+		return err
+	}
+
+	var k, v []byte
+	if it.cursor != nil {
+		if it.started {
+			k, v = it.cursor.Next()
+		} else {
+			k, v = it.cursor.First()
+			it.started = true
+		}
+	} else if len(it.ids) > 0 {
+		k, v = it.ids[0], it.bucket.Get(it.ids[0])
+		it.ids = it.ids[1:]
+	}
+
+	if k == nil {
+		return it.flush()
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	stop, err := it.sorter.filter(it.tree, it.bucket, k, v)
+	if err != nil {
+		return err
+	}
+
+	if stop {
+		return it.flush()
+	}
+
+	return nil
+}
+
+func (it *iterator) flush() error {
+	it.flushed = true
+	return it.sorter.flush()
+}
+
+// stop closes the iterator and records the given error.
+func (it *iterator) stop(err error) {
+	it.Close()
+	if it.err == nil {
+		it.err = err
+	}
+}
+
+func (it *iterator) Err() error {
+	return it.err
+}
+
+func (it *iterator) Close() error {
+	if it.done {
+		return it.err
+	}
+	it.done = true
+
+	if it.ownsTx {
+		err := it.tx.Rollback()
+		if err != nil && it.err == nil {
+			it.err = err
+		}
+	}
+	it.tx = nil
+
+	return it.err
+}
+
+// iterSink holds the records passed by the sorter until they are read by the iterator.
+type iterSink struct {
+	node     Node
+	elemType reflect.Type
+	pending  []reflect.Value
+}
+
+func (i *iterSink) elem() reflect.Value {
+	return reflect.New(i.elemType)
+}
+
+func (i *iterSink) bucketName() string {
+	return i.elemType.Name()
+}
+
+func (i *iterSink) add(itm *item) error {
+	err := nodeOf(i.node).afterLoad(itm.bucket.Tx(), itm.value.Interface())
+	if err != nil {
+		return err
+	}
+
+	i.pending = append(i.pending, *itm.value)
+	return nil
+}
+
+func (i *iterSink) flush() error {
+	return nil
+}
+
+func (i *iterSink) readOnly() bool {
+	return true
+}
@@ -0,0 +1,39 @@
+//go:build go1.23
+// +build go1.23
+
+package storm
+
+import "iter"
+
+// Iterate returns an iterator over the records matching the query, decoded into structs of type T.
+// If an error occurs, it is yielded with a zero value and the iteration stops.
+// The underlying iterator is closed when the loop exits, including on break.
+//
+//	for user, err := range storm.Iterate[User](db.Select(q.Gte("Age", 18))) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Iterate[T any](query Query) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		it := query.Iter()
+		defer it.Close()
+
+		for {
+			var record T
+			if !it.Next(&record) {
+				break
+			}
+
+			if !yield(record, nil) {
+				return
+			}
+		}
+
+		if err := it.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
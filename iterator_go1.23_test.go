@@ -0,0 +1,35 @@
+//go:build go1.23
+// +build go1.23
+
+package storm
+
+import (
+	"testing"
+
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIterate(t *testing.T) {
+	db, cleanup := prepareScoreDB(t)
+	defer cleanup()
+
+	var values []int
+	for score, err := range Iterate[Score](db.Select(q.Lt("Value", 10))) {
+		require.NoError(t, err)
+		if score.Value == 5 {
+			break
+		}
+		values = append(values, score.Value)
+	}
+	require.Equal(t, []int{0, 1, 2, 3, 4}, values)
+
+	// the transaction of the iterator is released on break
+	require.NoError(t, db.Save(&Score{Value: 20}))
+
+	var errs []error
+	for _, err := range Iterate[int](db.Select()) {
+		errs = append(errs, err)
+	}
+	require.Equal(t, []error{ErrStructPtrNeeded}, errs)
+}
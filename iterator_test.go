@@ -0,0 +1,129 @@
+package storm
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIterator(t *testing.T) {
+	db, cleanup := prepareScoreDB(t)
+	defer cleanup()
+
+	it := db.Select(q.Gte("Value", 5)).Skip(2).Limit(10).Iter()
+
+	var values []int
+	var score Score
+	for it.Next(&score) {
+		values = append(values, score.Value)
+	}
+	require.NoError(t, it.Err())
+	require.NoError(t, it.Close())
+	require.Equal(t, []int{7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, values)
+	require.False(t, it.Next(&score))
+
+	it = db.Select().OrderBy("Value").Reverse().Limit(3).Iter()
+	values = nil
+	for it.Next(&score) {
+		values = append(values, score.Value)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []int{19, 18, 17}, values)
+
+	// no matching records
+	it = db.Select(q.Gt("Value", 100)).Iter()
+	require.False(t, it.Next(&score))
+	require.NoError(t, it.Err())
+
+	// the iterator can be used inside a transaction
+	tx, err := db.Begin(false)
+	require.NoError(t, err)
+	it = tx.Select().Iter()
+	require.True(t, it.Next(&score))
+	var other Score
+	require.NoError(t, tx.One("ID", 10, &other))
+	require.True(t, it.Next(&score))
+	require.Equal(t, 1, score.Value)
+	require.NoError(t, it.Close())
+	require.NoError(t, tx.Rollback())
+}
+
+func TestIteratorClose(t *testing.T) {
+	db, cleanup := prepareScoreDB(t)
+	defer cleanup()
+
+	var score Score
+	it := db.Select().Iter()
+	require.True(t, it.Next(&score))
+	require.True(t, it.Next(&score))
+	require.NoError(t, it.Close())
+	require.NoError(t, it.Close())
+	require.False(t, it.Next(&score))
+	require.NoError(t, it.Err())
+
+	// the read transaction is released
+	require.NoError(t, db.Save(&Score{Value: 20}))
+
+	// closing before reading anything
+	it = db.Select().Iter()
+	require.NoError(t, it.Close())
+	require.False(t, it.Next(&score))
+
+	// an iterator holds its transaction without running the query in the background
+	goroutines := runtime.NumGoroutine()
+	it = db.Select().Iter()
+	require.True(t, it.Next(&score))
+	require.Equal(t, 1, db.Bolt.Stats().OpenTxN)
+	require.Equal(t, goroutines, runtime.NumGoroutine())
+	require.NoError(t, it.Close())
+	require.Equal(t, 0, db.Bolt.Stats().OpenTxN)
+}
+
+func TestIteratorIndex(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	type Player struct {
+		ID    int    `storm:"increment"`
+		Group string `storm:"index"`
+	}
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, db.Save(&Player{Group: []string{"a", "b"}[i%2]}))
+	}
+
+	it := db.Select(q.Eq("Group", "b")).Skip(1).Limit(3).Iter()
+	var ids []int
+	var player Player
+	for it.Next(&player) {
+		ids = append(ids, player.ID)
+	}
+	require.NoError(t, it.Close())
+	require.Equal(t, []int{4, 6, 8}, ids)
+}
+
+func TestIteratorErrors(t *testing.T) {
+	db, cleanup := prepareScoreDB(t)
+	defer cleanup()
+
+	var score Score
+	it := db.Select().Iter()
+	require.False(t, it.Next(score))
+	require.Equal(t, ErrStructPtrNeeded, it.Err())
+
+	it = db.Select().Iter()
+	require.True(t, it.Next(&score))
+	require.False(t, it.Next(&User{}))
+	require.Equal(t, ErrIncompatibleValue, it.Err())
+	require.Equal(t, ErrIncompatibleValue, it.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it = db.WithContext(ctx).Select().Iter()
+	require.True(t, it.Next(&score))
+	cancel()
+	require.False(t, it.Next(&score))
+	require.Equal(t, context.Canceled, it.Err())
+}
@@ -85,6 +85,7 @@ func (n *node) setBytes(tx *bolt.Tx, bucketName string, id, data []byte) error {
 		return err
 	}
 
+	n.emit(tx, bucketName, putEvent(id, bucket.Get(id), data))
 	return bucket.Put(id, data)
 }
 
@@ -107,22 +108,26 @@ func (n *node) Get(bucketName string, key interface{}, to interface{}) error {
 			return err
 		}
 
-		return n.codec.Unmarshal(raw, to)
+		return n.codecAt(bucketName, id).Unmarshal(raw, to)
 	})
 }
 
 // Set a key/value pair into a bucket
 func (n *node) Set(bucketName string, key interface{}, value interface{}) error {
+	id, err := toBytes(key, n.codec)
+	if err != nil {
+		return err
+	}
+
 	var data []byte
-	var err error
 	if value != nil {
-		data, err = n.codec.Marshal(value)
+		data, err = n.codecAt(bucketName, id).Marshal(value)
 		if err != nil {
 			return err
 		}
 	}
 
-	return n.SetBytes(bucketName, key, data)
+	return n.SetBytes(bucketName, id, data)
 }
 
 // Delete deletes a key from a bucket
@@ -143,6 +148,10 @@ func (n *node) delete(tx *bolt.Tx, bucketName string, id []byte) error {
 		return ErrNotFound
 	}
 
+	if old := bucket.Get(id); old != nil {
+		n.emit(tx, bucketName, Event{Type: EventDelete, ID: id, Old: old})
+	}
+
 	return bucket.Delete(id)
 }
 
@@ -1,19 +1,40 @@
 package storm
 
 import (
+	"bytes"
+	"encoding/binary"
 	"reflect"
 
 	bolt "go.etcd.io/bbolt"
 )
 
 const (
-	metaCodec = "codec"
+	metaCodec         = "codec"
+	metaIndexEncoding = "index_encoding"
+
+	// kind of the IDs, if they can be decoded without the type of the records
+	metaIDKind = "id_kind"
+
+	// bucket holding the kind of each index of the records
+	metaIndexes = "indexes"
+
+	// field holding the expiry time of the records
+	metaExpires = "expires"
+
+	// field marking the records as deleted
+	metaDeleted = "deleted"
+
+	// version of the encoding of the index values
+	indexEncoding = "1"
 )
 
 func newMeta(b *bolt.Bucket, n Node) (*meta, error) {
 	m := b.Bucket([]byte(metadataBucket))
 	if m != nil {
 		name := m.Get([]byte(metaCodec))
+		if m.Get([]byte(metaConvertCodec)) != nil {
+			return nil, ErrCodecConversion
+		}
 		if string(name) != n.Codec().Name() {
 			return nil, ErrDifferentCodec
 		}
@@ -29,6 +50,7 @@ func newMeta(b *bolt.Bucket, n Node) (*meta, error) {
 	}
 
 	m.Put([]byte(metaCodec), []byte(n.Codec().Name()))
+	m.Put([]byte(metaIndexEncoding), []byte(indexEncoding))
 	return &meta{
 		node:   n,
 		bucket: m,
@@ -67,3 +89,163 @@ func (m *meta) increment(field *fieldConfig) error {
 	field.IsZero = false
 	return nil
 }
+
+// hasLegacyIndexes reports whether the indexes of the bucket were built with an older encoding
+// and must be rebuilt before being used.
+func (m *meta) hasLegacyIndexes() bool {
+	return string(m.bucket.Get([]byte(metaIndexEncoding))) != indexEncoding
+}
+
+// hasLegacyIndexes reports whether the indexes of the given bucket were built with an older encoding.
+func hasLegacyIndexes(b *bolt.Bucket) bool {
+	m := b.Bucket([]byte(metadataBucket))
+	return m != nil && string(m.Get([]byte(metaIndexEncoding))) != indexEncoding
+}
+
+// saveSchema stores the kinds of the ID and of the indexes of the records, used to delete them
+// without their type, and their expiry and deleted fields.
+func (m *meta) saveSchema(cfg *structConfig) error {
+	err := putMeta(m.bucket, metaIDKind, idKind(cfg.ID.Value.Type()))
+	if err != nil {
+		return err
+	}
+
+	err = putMeta(m.bucket, metaExpires, expiryField(cfg.Type))
+	if err != nil {
+		return err
+	}
+
+	err = putMeta(m.bucket, metaDeleted, deletedField(cfg.Type))
+	if err != nil {
+		return err
+	}
+
+	indexes, err := m.bucket.CreateBucketIfNotExists([]byte(metaIndexes))
+	if err != nil {
+		return err
+	}
+
+	for name, f := range cfg.Fields {
+		if f.Index == "" {
+			continue
+		}
+
+		err = putMeta(indexes, name, f.Index)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, ci := range cfg.CompoundIndexes {
+		err = putMeta(indexes, ci.Name, ci.Kind)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the indexes removed from the type are forgotten
+	var stale [][]byte
+	indexes.ForEach(func(name, kind []byte) error {
+		if f, ok := cfg.Fields[string(name)]; ok && f.Index != "" {
+			return nil
+		}
+		if _, ok := cfg.CompoundIndexes[string(name)]; ok {
+			return nil
+		}
+		stale = append(stale, append([]byte(nil), name...))
+		return nil
+	})
+
+	for _, name := range stale {
+		err = indexes.Delete(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putMeta sets the value of the given key, the bucket is only modified if the value changes.
+func putMeta(b *bolt.Bucket, key, value string) error {
+	if string(b.Get([]byte(key))) == value {
+		return nil
+	}
+
+	return b.Put([]byte(key), []byte(value))
+}
+
+// basicTypes are the types of the IDs that can be decoded without the type of the records,
+// by the name of their kind.
+var basicTypes = map[string]reflect.Type{
+	"string": reflect.TypeOf(""),
+	"bytes":  reflect.TypeOf([]byte(nil)),
+	"int":    reflect.TypeOf(int(0)),
+	"int8":   reflect.TypeOf(int8(0)),
+	"int16":  reflect.TypeOf(int16(0)),
+	"int32":  reflect.TypeOf(int32(0)),
+	"int64":  reflect.TypeOf(int64(0)),
+	"uint":   reflect.TypeOf(uint(0)),
+	"uint8":  reflect.TypeOf(uint8(0)),
+	"uint16": reflect.TypeOf(uint16(0)),
+	"uint32": reflect.TypeOf(uint32(0)),
+	"uint64": reflect.TypeOf(uint64(0)),
+}
+
+// kindName returns the name of the kind of the given type, or an empty string
+// if it isn't the kind of one of the basic types.
+func kindName(typ reflect.Type) string {
+	name := typ.Kind().String()
+	if typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8 {
+		name = "bytes"
+	}
+
+	if _, ok := basicTypes[name]; !ok {
+		return ""
+	}
+
+	return name
+}
+
+// idKind returns the name of the kind of the given ID type, or an empty string
+// if its values are encoded by the codec.
+func idKind(typ reflect.Type) string {
+	name := kindName(typ)
+	if basicTypes[name] != typ {
+		return ""
+	}
+
+	return name
+}
+
+// idFromBytes decodes an ID encoded by toBytes, given the name of its kind.
+func idFromBytes(id []byte, kind string) (interface{}, bool) {
+	typ, ok := basicTypes[kind]
+	if !ok {
+		return nil, false
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return string(id), true
+	case reflect.Slice:
+		return id, true
+	}
+
+	// int and uint are encoded on 64 bits
+	size := typ
+	switch typ.Kind() {
+	case reflect.Int:
+		size = basicTypes["int64"]
+	case reflect.Uint:
+		size = basicTypes["uint64"]
+	}
+
+	v := reflect.New(size)
+	err := binary.Read(bytes.NewReader(id), binary.BigEndian, v.Interface())
+	if err != nil {
+		return nil, false
+	}
+
+	return v.Elem().Convert(typ).Interface(), true
+}
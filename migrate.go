@@ -0,0 +1,459 @@
+package storm
+
+import (
+	"bytes"
+	"reflect"
+	"time"
+
+	"github.com/asdine/storm/v3/codec"
+	"github.com/asdine/storm/v3/index"
+	bolt "go.etcd.io/bbolt"
+)
+
+// key prefix of the applied migrations in the dbinfo bucket
+const migrationPrefix = "migration:"
+
+// A Migration changes the schema or the records of the database.
+// Each migration is identified by a unique ID and is applied only once.
+type Migration struct {
+	ID string
+
+	// Migrate applies the migration. It receives a node bound to the write transaction
+	// of the migration, the transaction is rolled back if it returns an error.
+	Migrate func(tx Node) error
+}
+
+// Migrate applies the given migrations in order, skipping those that were already applied.
+// Each migration runs in its own write transaction, in which its ID is recorded in the database.
+// Migrate stops at the first migration that fails and returns its error.
+func Migrate(db *DB, migrations ...Migration) error {
+	seen := make(map[string]bool)
+	for _, m := range migrations {
+		if m.ID == "" || m.Migrate == nil || seen[m.ID] {
+			return ErrInvalidMigration
+		}
+		seen[m.ID] = true
+	}
+
+	for _, m := range migrations {
+		m := m
+		err := db.Bolt.Update(func(tx *bolt.Tx) error {
+			n := db.WithTransaction(tx)
+
+			var appliedAt time.Time
+			err := n.Get(dbinfo, migrationPrefix+m.ID, &appliedAt)
+			if err == nil {
+				return nil
+			}
+			if err != ErrNotFound {
+				return err
+			}
+
+			err = m.Migrate(n)
+			if err != nil {
+				return err
+			}
+
+			return n.Set(dbinfo, migrationPrefix+m.ID, db.clock())
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenameBucket returns a migration function that moves the bucket named from,
+// its records, indexes and nested buckets, to a new bucket named to.
+// The records bound to their location by the codec are encoded again for the new bucket.
+func RenameBucket(from, to string) func(Node) error {
+	return func(tx Node) error {
+		n := nodeOf(tx)
+		return n.readWriteTx(func(tx *bolt.Tx) error {
+			src := n.GetBucket(tx, from)
+			if src == nil {
+				return ErrNotFound
+			}
+
+			if n.GetBucket(tx, to) != nil {
+				return ErrAlreadyExists
+			}
+
+			dst, err := n.CreateBucketIfNotExists(tx, to)
+			if err != nil {
+				return err
+			}
+
+			err = n.moveBucket(dst, src, n.path(to), n.path(from))
+			if err != nil {
+				return err
+			}
+
+			return n.drop(tx, from)
+		})
+	}
+}
+
+// moveBucket copies the keys and the nested buckets of src into dst, found at the given paths
+// from the root of the database. The values are encoded again for dst if they are bound to their location.
+// The values of the metadata and of the indexes are not encoded by the codec, their buckets are copied as is,
+// which is also the case if the paths are nil.
+func (n *node) moveBucket(dst, src *bolt.Bucket, dstPath, srcPath []string) error {
+	err := dst.SetSequence(src.Sequence())
+	if err != nil {
+		return err
+	}
+
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			if srcPath != nil {
+				var err error
+				v, err = n.rebind(v, srcPath, dstPath, k)
+				if err != nil {
+					return err
+				}
+			}
+
+			return dst.Put(append([]byte(nil), k...), append([]byte(nil), v...))
+		}
+
+		child, err := dst.CreateBucket(append([]byte(nil), k...))
+		if err != nil {
+			return err
+		}
+
+		if srcPath == nil || bytes.Equal(k, []byte(metadataBucket)) || bytes.HasPrefix(k, []byte(indexPrefix)) {
+			return n.moveBucket(child, src.Bucket(k), nil, nil)
+		}
+
+		return n.moveBucket(child, src.Bucket(k),
+			append(dstPath[:len(dstPath):len(dstPath)], string(k)),
+			append(srcPath[:len(srcPath):len(srcPath)], string(k)))
+	})
+}
+
+// rebind returns the given value stored under the given key of the bucket found at srcPath,
+// encoded for the same key of the bucket found at dstPath if the codec binds it to its location.
+func (n *node) rebind(v []byte, srcPath, dstPath []string, key []byte) ([]byte, error) {
+	if _, ok := n.codec.(codec.Binder); !ok {
+		return v, nil
+	}
+
+	r, ok := bindCodec(n.codec, srcPath, key).(codec.Rebinder)
+	if !ok {
+		return nil, ErrCannotRebind
+	}
+
+	raw, err := r.Rebind(v, location(dstPath, key))
+	if err != nil || raw == nil {
+		return v, err
+	}
+
+	return raw, nil
+}
+
+// Transform returns a migration function that rewrites every record of a bucket.
+// fn must be a function of the form func(*Old) (*New, error). It is called with each record
+// of the Old bucket and the returned records are saved in the New bucket, which can be the same.
+// Records for which fn returns nil are deleted. The Old bucket is dropped if it differs
+// from the New one, otherwise its indexes are rebuilt.
+// The records are saved as returned: hooks are not called and versions and timestamps are not set.
+func Transform(fn interface{}) func(Node) error {
+	return func(tx Node) error {
+		fnValue := reflect.ValueOf(fn)
+		if !isTransform(fnValue) {
+			return ErrBadTransform
+		}
+
+		n := nodeOf(tx)
+		return n.readWriteTx(func(tx *bolt.Tx) error {
+			return n.transform(tx, fnValue)
+		})
+	}
+}
+
+// isTransform reports whether fn is a function of the form func(*Old) (*New, error).
+func isTransform(fn reflect.Value) bool {
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return false
+	}
+
+	isStructPtr := func(t reflect.Type) bool {
+		return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct && t.Elem().Name() != ""
+	}
+
+	t := fn.Type()
+	return t.NumIn() == 1 && isStructPtr(t.In(0)) &&
+		t.NumOut() == 2 && isStructPtr(t.Out(0)) &&
+		t.Out(1) == reflect.TypeOf((*error)(nil)).Elem()
+}
+
+func (n *node) transform(tx *bolt.Tx, fn reflect.Value) error {
+	oldType := fn.Type().In(0).Elem()
+	newType := fn.Type().Out(0).Elem()
+
+	bucket := n.GetBucket(tx, oldType.Name())
+	if bucket == nil {
+		return nil
+	}
+
+	var ids, records, indexes [][]byte
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil {
+			ids = append(ids, append([]byte(nil), k...))
+			records = append(records, append([]byte(nil), v...))
+		} else if bytes.HasPrefix(k, []byte(indexPrefix)) {
+			indexes = append(indexes, append([]byte(nil), k...))
+		}
+	}
+
+	// records are saved again in the same bucket, the metadata is kept
+	// so that auto incremented fields keep their counters
+	sameBucket := oldType.Name() == newType.Name()
+	if sameBucket {
+		for _, id := range ids {
+			err := bucket.Delete(id)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, name := range indexes {
+			err := bucket.DeleteBucket(name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, raw := range records {
+		record := reflect.New(oldType)
+		err := n.codecAt(oldType.Name(), ids[i]).Unmarshal(raw, record.Interface())
+		if err != nil {
+			return err
+		}
+
+		out := fn.Call([]reflect.Value{record})
+		if err, _ := out[1].Interface().(error); err != nil {
+			return err
+		}
+
+		if out[0].IsNil() {
+			continue
+		}
+
+		cfg, err := extract(&out[0])
+		if err != nil {
+			return err
+		}
+
+		if cfg.ID.IsZero && (!cfg.ID.IsInteger || !cfg.ID.Increment) {
+			return ErrZeroID
+		}
+
+		err = n.save(tx, cfg, out[0].Interface(), false)
+		if err != nil {
+			return err
+		}
+	}
+
+	if sameBucket {
+		return nil
+	}
+
+	return n.drop(tx, oldType.Name())
+}
+
+// AddIndex returns a migration function that builds the index of the given field,
+// or the compound index of the given name, for all the records of the bucket of kind.
+// The index must be declared by the kind and is rebuilt if it already exists.
+func AddIndex(kind interface{}, fieldName string) func(Node) error {
+	return func(tx Node) error {
+		ref := reflect.ValueOf(kind)
+		if !ref.IsValid() || ref.Kind() != reflect.Ptr || ref.Elem().Kind() != reflect.Struct {
+			return ErrStructPtrNeeded
+		}
+
+		cfg, err := extract(&ref)
+		if err != nil {
+			return err
+		}
+
+		if f, ok := cfg.Fields[fieldName]; (!ok || f.Index == "") && cfg.CompoundIndexes[fieldName] == nil {
+			return ErrIdxNotFound
+		}
+
+		n := nodeOf(tx)
+		return n.readWriteTx(func(tx *bolt.Tx) error {
+			return n.addIndex(tx, cfg, fieldName)
+		})
+	}
+}
+
+func (n *node) addIndex(tx *bolt.Tx, cfg *structConfig, fieldName string) error {
+	bucket := n.GetBucket(tx, cfg.Name)
+	if bucket == nil {
+		return nil
+	}
+
+	meta, err := newMeta(bucket, n)
+	if err != nil {
+		return err
+	}
+
+	// all the indexes are rebuilt anyway
+	if meta.hasLegacyIndexes() {
+		return n.reIndex(tx, cfg)
+	}
+
+	err = bucket.DeleteBucket([]byte(indexPrefix + fieldName))
+	if err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+
+	var indexKind string
+	if ci := cfg.CompoundIndexes[fieldName]; ci != nil {
+		indexKind = ci.Kind
+	} else {
+		indexKind = cfg.Fields[fieldName].Index
+	}
+
+	idx, err := getIndex(bucket, indexKind, fieldName)
+	if err != nil {
+		return err
+	}
+
+	err = meta.saveSchema(cfg)
+	if err != nil {
+		return err
+	}
+
+	var ids [][]byte
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil {
+			ids = append(ids, append([]byte(nil), k...))
+		}
+	}
+
+	for _, id := range ids {
+		if err := n.ctxErr(); err != nil {
+			return err
+		}
+
+		record := reflect.New(cfg.Type)
+		err = n.codecAt(cfg.Name, id).Unmarshal(bucket.Get(id), record.Interface())
+		if err != nil {
+			return err
+		}
+
+		rcfg, err := extract(&record)
+		if err != nil {
+			return err
+		}
+
+		err = n.indexRecord(idx, rcfg, fieldName, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexRecord adds the record of the given config to the index of the given field or compound index.
+func (n *node) indexRecord(idx index.Index, cfg *structConfig, fieldName string, id []byte) error {
+	if ci := cfg.CompoundIndexes[fieldName]; ci != nil {
+		value, err := ci.value(n.codec)
+		if err != nil || value == nil {
+			return err
+		}
+
+		return updateIndex(idx, value, id)
+	}
+
+	f := cfg.Fields[fieldName]
+	if f.IsZero {
+		return nil
+	}
+
+	return n.indexField(idx, f, id)
+}
+
+// RebuildIndexes returns a migration function that rebuilds the indexes of the buckets of the given kinds
+// that were built by an older version of Storm, which encoded the index values differently.
+// The records can't be saved in these buckets until their indexes are rebuilt.
+// The buckets whose indexes are up to date are left unchanged.
+func RebuildIndexes(kinds ...interface{}) func(Node) error {
+	return func(tx Node) error {
+		n := nodeOf(tx)
+		for _, kind := range kinds {
+			ref := reflect.ValueOf(kind)
+			if !ref.IsValid() || ref.Kind() != reflect.Ptr || ref.Elem().Kind() != reflect.Struct {
+				return ErrStructPtrNeeded
+			}
+
+			cfg, err := extract(&ref)
+			if err != nil {
+				return err
+			}
+
+			err = n.readWriteTx(func(tx *bolt.Tx) error {
+				bucket := n.GetBucket(tx, cfg.Name)
+				if bucket == nil || !hasLegacyIndexes(bucket) {
+					return nil
+				}
+
+				return n.reIndex(tx, cfg)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// DropIndex returns a migration function that deletes the index of the given field,
+// or the compound index of the given name, from the bucket of kind and from its metadata.
+// It does nothing if the index doesn't exist.
+func DropIndex(kind interface{}, fieldName string) func(Node) error {
+	return func(tx Node) error {
+		ref := reflect.ValueOf(kind)
+		if !ref.IsValid() || ref.Kind() != reflect.Ptr || ref.Elem().Kind() != reflect.Struct {
+			return ErrStructPtrNeeded
+		}
+
+		cfg, err := extract(&ref)
+		if err != nil {
+			return err
+		}
+
+		n := nodeOf(tx)
+		return n.readWriteTx(func(tx *bolt.Tx) error {
+			bucket := n.GetBucket(tx, cfg.Name)
+			if bucket == nil {
+				return nil
+			}
+
+			// records deleted without their type are removed from the indexes of the metadata
+			if m := bucket.Bucket([]byte(metadataBucket)); m != nil {
+				if indexes := m.Bucket([]byte(metaIndexes)); indexes != nil {
+					err := indexes.Delete([]byte(fieldName))
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			err := bucket.DeleteBucket([]byte(indexPrefix + fieldName))
+			if err == bolt.ErrBucketNotFound {
+				return nil
+			}
+			return err
+		})
+	}
+}
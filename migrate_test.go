@@ -0,0 +1,314 @@
+package storm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3/codec/aes"
+	"github.com/asdine/storm/v3/codec/json"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMigrate(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, cleanup := createDB(t, Clock(func() time.Time {
+		return now
+	}))
+	defer cleanup()
+
+	var calls []string
+	migration := func(id string) Migration {
+		return Migration{
+			ID: id,
+			Migrate: func(tx Node) error {
+				calls = append(calls, id)
+				return tx.Set("b", id, id)
+			},
+		}
+	}
+
+	err := Migrate(db, migration("1"), migration("2"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "2"}, calls)
+
+	var appliedAt time.Time
+	err = db.Get(dbinfo, migrationPrefix+"2", &appliedAt)
+	require.NoError(t, err)
+	require.Equal(t, now, appliedAt)
+
+	// applied migrations are skipped
+	err = Migrate(db, migration("1"), migration("2"), migration("3"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "2", "3"}, calls)
+
+	// failing migrations are rolled back and stop the next ones
+	errFail := errors.New("fail")
+	err = Migrate(db, Migration{
+		ID: "4",
+		Migrate: func(tx Node) error {
+			require.NoError(t, tx.Set("b", "4", "4"))
+			return errFail
+		},
+	}, migration("5"))
+	require.Equal(t, errFail, err)
+	require.Equal(t, []string{"1", "2", "3"}, calls)
+
+	exists, err := db.KeyExists("b", "4")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	err = db.Get(dbinfo, migrationPrefix+"4", &appliedAt)
+	require.Equal(t, ErrNotFound, err)
+
+	err = Migrate(db, migration("6"), migration("6"))
+	require.Equal(t, ErrInvalidMigration, err)
+
+	err = Migrate(db, Migration{ID: "7"})
+	require.Equal(t, ErrInvalidMigration, err)
+
+	err = Migrate(db, Migration{Migrate: func(Node) error { return nil }})
+	require.Equal(t, ErrInvalidMigration, err)
+}
+
+func TestRenameBucket(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, db.Save(&SimpleUser{ID: i, Name: "John"}))
+	}
+	require.NoError(t, db.From("SimpleUser").Set("child", "key", "value"))
+
+	err := Migrate(db, Migration{ID: "rename", Migrate: RenameBucket("SimpleUser", "Member")})
+	require.NoError(t, err)
+
+	type Member SimpleUser
+
+	var members []Member
+	require.NoError(t, db.Find("Name", "John", &members))
+	require.Len(t, members, 3)
+
+	var value string
+	require.NoError(t, db.From("Member").Get("child", "key", &value))
+	require.Equal(t, "value", value)
+
+	exists, err := db.KeyExists("SimpleUser", 1)
+	require.Equal(t, ErrNotFound, err)
+	require.False(t, exists)
+
+	err = Migrate(db, Migration{ID: "missing", Migrate: RenameBucket("SimpleUser", "Member")})
+	require.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, db.Save(&SimpleUser{ID: 1, Name: "John"}))
+	err = Migrate(db, Migration{ID: "existing", Migrate: RenameBucket("SimpleUser", "Member")})
+	require.Equal(t, ErrAlreadyExists, err)
+}
+
+func TestRenameBucketBindLocation(t *testing.T) {
+	c, err := aes.NewAES(json.Codec, []byte("0123456789abcdef"), aes.BindLocation())
+	require.NoError(t, err)
+
+	db, cleanup := createDB(t, Codec(c))
+	defer cleanup()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, db.Save(&SimpleUser{ID: i, Name: "John"}))
+	}
+	require.NoError(t, db.From("SimpleUser").Set("child", "key", "value"))
+
+	err = Migrate(db, Migration{ID: "rename", Migrate: RenameBucket("SimpleUser", "Member")})
+	require.NoError(t, err)
+
+	type Member SimpleUser
+
+	// the records are encrypted for their new location
+	var members []Member
+	require.NoError(t, db.Find("Name", "John", &members))
+	require.Len(t, members, 3)
+	require.Equal(t, "John", members[2].Name)
+
+	var value string
+	require.NoError(t, db.From("Member").Get("child", "key", &value))
+	require.Equal(t, "value", value)
+}
+
+func TestTransform(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	type Member struct {
+		ID   int    `storm:"increment"`
+		Name string `storm:"unique"`
+	}
+
+	for _, name := range []string{"John", "Jack", "Jane"} {
+		require.NoError(t, db.Save(&Member{Name: name}))
+	}
+
+	t.Run("SameBucket", func(t *testing.T) {
+		err := Migrate(db, Migration{
+			ID: "upper",
+			Migrate: Transform(func(u *Member) (*Member, error) {
+				if u.Name == "Jack" {
+					return nil, nil
+				}
+				u.Name = strings.ToUpper(u.Name)
+				return u, nil
+			}),
+		})
+		require.NoError(t, err)
+
+		var users []Member
+		require.NoError(t, db.All(&users))
+		require.Len(t, users, 2)
+
+		var user Member
+		require.NoError(t, db.One("Name", "JANE", &user))
+		require.Equal(t, 3, user.ID)
+		require.Equal(t, ErrNotFound, db.One("Name", "Jane", &user))
+
+		// counters are kept
+		user = Member{Name: "Jim"}
+		require.NoError(t, db.Save(&user))
+		require.Equal(t, 4, user.ID)
+	})
+
+	t.Run("NewBucket", func(t *testing.T) {
+		type Person struct {
+			ID       string
+			FullName string `storm:"index"`
+		}
+
+		err := Migrate(db, Migration{
+			ID: "person",
+			Migrate: Transform(func(u *Member) (*Person, error) {
+				return &Person{ID: strings.ToLower(u.Name), FullName: u.Name}, nil
+			}),
+		})
+		require.NoError(t, err)
+
+		var person Person
+		require.NoError(t, db.One("FullName", "JOHN", &person))
+		require.Equal(t, "john", person.ID)
+
+		count, err := db.Count(new(Person))
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+
+		exists, err := db.KeyExists("Member", 1)
+		require.Equal(t, ErrNotFound, err)
+		require.False(t, exists)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		require.NoError(t, db.Save(&SimpleUser{ID: 1, Name: "John"}))
+
+		errFail := errors.New("fail")
+		err := Migrate(db, Migration{
+			ID: "fail",
+			Migrate: Transform(func(u *SimpleUser) (*SimpleUser, error) {
+				return nil, errFail
+			}),
+		})
+		require.Equal(t, errFail, err)
+
+		var user SimpleUser
+		require.NoError(t, db.One("ID", 1, &user))
+
+		for _, fn := range []interface{}{
+			nil,
+			func(u SimpleUser) (*SimpleUser, error) { return nil, nil },
+			func(u *SimpleUser) *SimpleUser { return nil },
+			func(u *SimpleUser) (*SimpleUser, bool) { return nil, false },
+		} {
+			err = Migrate(db, Migration{ID: "bad", Migrate: Transform(fn)})
+			require.Equal(t, ErrBadTransform, err)
+		}
+	})
+}
+
+func TestAddDropIndex(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	{
+		type Account struct {
+			ID       int `storm:"increment"`
+			TenantID string
+			Email    string
+			Name     string
+		}
+
+		require.NoError(t, db.Save(&Account{TenantID: "acme", Email: "john@acme.com", Name: "John"}))
+		require.NoError(t, db.Save(&Account{TenantID: "acme", Email: "jane@acme.com", Name: "Jane"}))
+		require.NoError(t, db.Save(&Account{TenantID: "globex", Email: "john@acme.com"}))
+	}
+
+	err := Migrate(db,
+		Migration{ID: "name", Migrate: AddIndex(new(Account), "Name")},
+		Migration{ID: "tenant_email", Migrate: AddIndex(new(Account), "TenantEmail")},
+	)
+	require.NoError(t, err)
+
+	var accounts []Account
+	require.NoError(t, db.AllByIndex("Name", &accounts))
+	require.Len(t, accounts, 2)
+	require.Equal(t, "Jane", accounts[0].Name)
+
+	var account Account
+	require.NoError(t, db.One("TenantEmail", []string{"globex", "john@acme.com"}, &account))
+	require.Equal(t, 3, account.ID)
+
+	err = Migrate(db, Migration{ID: "email", Migrate: AddIndex(new(Account), "Email")})
+	require.Equal(t, ErrIdxNotFound, err)
+
+	// unique indexes are enforced
+	{
+		type Contact struct {
+			ID    int `storm:"increment"`
+			Email string
+		}
+
+		require.NoError(t, db.Save(&Contact{Email: "john@acme.com"}))
+		require.NoError(t, db.Save(&Contact{Email: "john@acme.com"}))
+	}
+
+	type Contact struct {
+		ID    int    `storm:"increment"`
+		Email string `storm:"unique"`
+	}
+
+	err = Migrate(db, Migration{ID: "contact_email", Migrate: AddIndex(new(Contact), "Email")})
+	require.Equal(t, ErrAlreadyExists, err)
+
+	err = Migrate(db, Migration{ID: "drop", Migrate: DropIndex(new(Account), "Name")})
+	require.NoError(t, err)
+
+	err = db.AllByIndex("Name", &accounts)
+	require.Equal(t, ErrNotFound, err)
+
+	// records deleted without their type don't recreate the index
+	err = db.Bolt.Update(func(tx *bolt.Tx) error {
+		indexes := tx.Bucket([]byte("Account")).Bucket([]byte(metadataBucket)).Bucket([]byte(metaIndexes))
+		require.Nil(t, indexes.Get([]byte("Name")))
+		require.NotNil(t, indexes.Get([]byte("TenantEmail")))
+
+		id, err := toBytes(1, db.Codec())
+		require.NoError(t, err)
+
+		n := nodeOf(db.WithTransaction(tx))
+		return n.deleteRecord(tx, "Account", id)
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Bolt.View(func(tx *bolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("Account")).Bucket([]byte(indexPrefix+"Name")))
+		return nil
+	}))
+
+	// dropping a missing index does nothing
+	err = Migrate(db, Migration{ID: "drop_again", Migrate: DropIndex(new(Account), "Name")})
+	require.NoError(t, err)
+}
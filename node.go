@@ -1,6 +1,9 @@
 package storm
 
 import (
+	"context"
+	"encoding/binary"
+
 	"github.com/asdine/storm/v3/codec"
 	bolt "go.etcd.io/bbolt"
 )
@@ -41,6 +44,21 @@ type Node interface {
 
 	// WithBatch returns a new Storm Node with the batch mode enabled.
 	WithBatch(enabled bool) Node
+
+	// Context returns the context of the node. It defaults to context.Background.
+	Context() context.Context
+
+	// WithContext returns a new Storm Node that will stop its operations and return
+	// the context error when the given context is done.
+	WithContext(ctx context.Context) Node
+
+	// IncludeDeleted returns a new Storm Node whose finders and queries return
+	// the records marked as deleted.
+	IncludeDeleted() Node
+
+	// Watch calls the handler with the changes made to the given bucket, or to the bucket
+	// of the given type, once they are committed. It returns a function that stops the watch.
+	Watch(bucketOrType interface{}, handler func(Event)) (func(), error)
 }
 
 // A Node in Storm represents the API to a BoltDB bucket.
@@ -58,6 +76,12 @@ type node struct {
 
 	// Enable batch mode for read-write transaction, instead of update mode
 	batchMode bool
+
+	// Context checked by long running operations. Nil if not set
+	ctx context.Context
+
+	// Return the records marked as deleted
+	includeDeleted bool
 }
 
 // From returns a new Storm Node with a new bucket root below the current.
@@ -85,6 +109,44 @@ func (n node) WithBatch(enabled bool) Node {
 	return &n
 }
 
+// WithContext returns a new Storm Node that will stop its operations and return
+// the context error when the given context is done.
+func (n node) WithContext(ctx context.Context) Node {
+	n.ctx = ctx
+	return &n
+}
+
+// IncludeDeleted returns a new Storm Node whose finders and queries return
+// the records marked as deleted.
+func (n node) IncludeDeleted() Node {
+	n.includeDeleted = true
+	return &n
+}
+
+// Context returns the context of the node. It defaults to context.Background.
+func (n *node) Context() context.Context {
+	if n.ctx == nil {
+		return context.Background()
+	}
+	return n.ctx
+}
+
+// nodeOf returns the node implementing the given Node, which can be a DB.
+func nodeOf(n Node) *node {
+	if db, ok := n.(*DB); ok {
+		return db.Node.(*node)
+	}
+	return n.(*node)
+}
+
+// ctxErr returns the error of the node context, nil if it is not done.
+func (n *node) ctxErr() error {
+	if n.ctx == nil {
+		return nil
+	}
+	return n.ctx.Err()
+}
+
 // Bucket returns the bucket name as a slice from the root.
 // In the normal, simple case this will be empty.
 func (n *node) Bucket() []string {
@@ -96,26 +158,74 @@ func (n *node) Codec() codec.MarshalUnmarshaler {
 	return n.codec
 }
 
+// codecAt returns the codec encoding the value stored under the given key of the given bucket,
+// bound to that location if the codec implements codec.Binder.
+func (n *node) codecAt(bucketName string, key []byte) codec.MarshalUnmarshaler {
+	return bindCodec(n.codec, append(n.rootBucket[:len(n.rootBucket):len(n.rootBucket)], bucketName), key)
+}
+
+// bindCodec binds the codec to the given key of the bucket found at the given path from the root of the database.
+// The location is made of the length prefixed names of the buckets followed by the length prefixed key.
+func bindCodec(c codec.MarshalUnmarshaler, path []string, key []byte) codec.MarshalUnmarshaler {
+	b, ok := c.(codec.Binder)
+	if !ok {
+		return c
+	}
+
+	return b.Bind(location(path, key))
+}
+
+// location returns the location of the given key of the bucket found at the given path.
+func location(path []string, key []byte) []byte {
+	var location []byte
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, part := range append(path[:len(path):len(path)], string(key)) {
+		location = append(location, buf[:binary.PutUvarint(buf, uint64(len(part)))]...)
+		location = append(location, part...)
+	}
+
+	return location
+}
+
 // Detects if already in transaction or runs a read write transaction.
 // Uses batch mode if enabled.
+// The transaction is rolled back if the context is done before it is committed.
 func (n *node) readWriteTx(fn func(tx *bolt.Tx) error) error {
+	if err := n.ctxErr(); err != nil {
+		return err
+	}
+
 	if n.tx != nil {
 		return fn(n.tx)
 	}
 
 	if n.batchMode {
 		return n.s.Bolt.Batch(func(tx *bolt.Tx) error {
-			return fn(tx)
+			return n.withCtx(fn, tx)
 		})
 	}
 
 	return n.s.Bolt.Update(func(tx *bolt.Tx) error {
-		return fn(tx)
+		return n.withCtx(fn, tx)
 	})
 }
 
+// withCtx runs fn and returns the context error if the context is done when it returns.
+func (n *node) withCtx(fn func(tx *bolt.Tx) error, tx *bolt.Tx) error {
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return n.ctxErr()
+}
+
 // Detects if already in transaction or runs a read transaction.
 func (n *node) readTx(fn func(tx *bolt.Tx) error) error {
+	if err := n.ctxErr(); err != nil {
+		return err
+	}
+
 	if n.tx != nil {
 		return fn(n.tx)
 	}
@@ -1,10 +1,13 @@
 package storm
 
 import (
+	"context"
+	"fmt"
 	"testing"
 
 	"github.com/asdine/storm/v3/codec/gob"
 	"github.com/asdine/storm/v3/codec/json"
+	"github.com/asdine/storm/v3/q"
 	bolt "go.etcd.io/bbolt"
 	"github.com/stretchr/testify/require"
 )
@@ -133,3 +136,75 @@ func TestNodeWithCodec(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestNodeWithContext(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	for i := 1; i <= 10; i++ {
+		err := db.Save(&User{ID: i, Name: "John", Slug: fmt.Sprintf("john%d", i)})
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, context.Background(), db.Context())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := db.WithContext(ctx)
+	require.Equal(t, ctx, n.Context())
+	require.Equal(t, ctx, n.From("a").Context())
+
+	var users []User
+	err := n.Find("Name", "John", &users)
+	require.NoError(t, err)
+	require.Len(t, users, 10)
+
+	cancel()
+
+	var user User
+	require.Equal(t, context.Canceled, n.One("ID", 1, &user))
+	require.Equal(t, context.Canceled, n.Find("Name", "John", &users))
+	require.Equal(t, context.Canceled, n.AllByIndex("Name", &users))
+	require.Equal(t, context.Canceled, n.Range("Slug", "a", "z", &users))
+	require.Equal(t, context.Canceled, n.Prefix("Slug", "john", &users))
+	require.Equal(t, context.Canceled, n.Select().Find(&users))
+	require.Equal(t, context.Canceled, n.Save(&User{ID: 11, Name: "Jack"}))
+	require.Equal(t, context.Canceled, n.Set("b", "k", "v"))
+	_, err = n.Begin(true)
+	require.Equal(t, context.Canceled, err)
+
+	// the context is checked while reading the records
+	var seen int
+	cancelAt := func(count int) (context.Context, context.CancelFunc, q.Matcher) {
+		seen = 0
+		ctx, cancel := context.WithCancel(context.Background())
+		return ctx, cancel, q.NewFieldMatcher("ID", matcherFunc(func(interface{}) (bool, error) {
+			seen++
+			if seen == count {
+				cancel()
+			}
+			return true, nil
+		}))
+	}
+
+	ctx, cancel, m := cancelAt(3)
+	defer cancel()
+	err = db.WithContext(ctx).Select(m).Find(&users)
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, 3, seen)
+
+	// the transaction is rolled back
+	ctx, cancel, m = cancelAt(5)
+	defer cancel()
+	err = db.WithContext(ctx).Select(m).Delete(new(User))
+	require.Equal(t, context.Canceled, err)
+
+	count, err := db.Count(new(User))
+	require.NoError(t, err)
+	require.Equal(t, 10, count)
+}
+
+type matcherFunc func(interface{}) (bool, error)
+
+func (fn matcherFunc) MatchField(v interface{}) (bool, error) {
+	return fn(v)
+}
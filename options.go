@@ -2,6 +2,7 @@ package storm
 
 import (
 	"os"
+	"time"
 
 	"github.com/asdine/storm/v3/codec"
 	"github.com/asdine/storm/v3/index"
@@ -25,6 +26,17 @@ func Codec(c codec.MarshalUnmarshaler) func(*Options) error {
 	}
 }
 
+// SweepExpired starts a background goroutine deleting the expired records at the given interval,
+// as done by PurgeExpired, until the database is closed. The errors of the purges are passed
+// to onError, which may be nil. It is ignored by read-only databases and if interval isn't positive.
+func SweepExpired(interval time.Duration, onError func(error)) func(*Options) error {
+	return func(opts *Options) error {
+		opts.sweepInterval = interval
+		opts.onSweepError = onError
+		return nil
+	}
+}
+
 // Batch enables the use of batch instead of update for read-write transactions.
 func Batch() func(*Options) error {
 	return func(opts *Options) error {
@@ -51,6 +63,16 @@ func UseDB(b *bolt.DB) func(*Options) error {
 	}
 }
 
+// Clock sets the function returning the current time, used to fill the fields
+// tagged with created or updated, to mark records as deleted and to check whether
+// records have expired. The default is time.Now.
+func Clock(now func() time.Time) func(*Options) error {
+	return func(opts *Options) error {
+		opts.clock = now
+		return nil
+	}
+}
+
 // Limit sets the maximum number of records to return
 func Limit(limit int) func(*index.Options) {
 	return func(opts *index.Options) {
@@ -94,4 +116,13 @@ type Options struct {
 
 	// Bolt is still easily accessible
 	bolt *bolt.DB
+
+	// Returns the current time
+	clock func() time.Time
+
+	// Interval between the purges of the expired records, 0 to disable them
+	sweepInterval time.Duration
+
+	// Called with the errors of the purges
+	onSweepError func(error)
 }
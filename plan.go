@@ -0,0 +1,588 @@
+package storm
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3/codec"
+	"github.com/asdine/storm/v3/index"
+	"github.com/asdine/storm/v3/internal"
+	"github.com/asdine/storm/v3/q"
+	bolt "go.etcd.io/bbolt"
+)
+
+// indexPlan describes how a query can use an index instead of scanning the whole bucket.
+type indexPlan struct {
+	// Name of the indexed field or of the compound index
+	field string
+
+	// Kind of index, unique or index
+	kind string
+
+	// Values looked up in the index. Nil for range scans.
+	values [][]byte
+
+	// Bounds of a range scan. A nil bound leaves that side of the range open.
+	min, max []byte
+
+	// Lowercase substrings of the values looked up in a trigram index
+	substrings [][]byte
+
+	// Matcher that must still be applied on every record read through the index
+	residual q.Matcher
+}
+
+// planIndex analyses the given matcher tree and returns the best index to use to run it.
+// It returns nil if no index can be used and the bucket must be scanned entirely.
+func planIndex(tree q.Matcher, typ reflect.Type, c codec.MarshalUnmarshaler) *indexPlan {
+	if tree == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	ref := reflect.New(typ)
+	cfg, err := extract(&ref)
+	if err != nil {
+		return nil
+	}
+
+	conjuncts := q.Conjuncts(tree)
+	fields := make(map[string][]int)
+	var order []string
+	for i, m := range conjuncts {
+		cmp, ok := q.Inspect(m)
+		if !ok {
+			continue
+		}
+
+		fieldCfg, ok := cfg.Fields[cmp.Field]
+		if !ok || !fieldCfg.hasLookupIndex() {
+			continue
+		}
+
+		// only multi indexes hold the elements of slices, arrays and maps
+		if fieldCfg.Index == tagMulti && (!cmp.Elements || cmp.Token != token.EQL) {
+			continue
+		}
+		if fieldCfg.Index != tagMulti && cmp.Elements && isMultiValued(fieldCfg.Value.Type()) {
+			continue
+		}
+
+		if _, ok := fields[cmp.Field]; !ok {
+			order = append(order, cmp.Field)
+		}
+		fields[cmp.Field] = append(fields[cmp.Field], i)
+	}
+
+	var best *indexPlan
+	var bestScore int
+	for _, name := range order {
+		f, ok := internal.StructFieldByPath(typ, name)
+		if !ok || f.PkgPath != "" {
+			continue
+		}
+
+		plan, score := planField(conjuncts, fields[name], typ, cfg.Fields[name].indexType(), c)
+		if plan == nil {
+			continue
+		}
+
+		plan.field = name
+		plan.kind = cfg.Fields[name].Index
+		if plan.kind == tagUniqueIdx {
+			score++
+		}
+
+		if score > bestScore {
+			best, bestScore = plan, score
+		}
+	}
+
+	for _, m := range conjuncts {
+		plan := planTrigram(m, cfg)
+		if plan != nil && trigramScore > bestScore {
+			plan.residual = residualMatcher(conjuncts)
+			best, bestScore = plan, trigramScore
+		}
+	}
+
+	names := make([]string, 0, len(cfg.CompoundIndexes))
+	for name := range cfg.CompoundIndexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		ci := cfg.CompoundIndexes[name]
+		plan := planCompound(conjuncts, ci, typ, c)
+		if plan == nil {
+			continue
+		}
+
+		// a lookup on several fields is more selective than on a single one
+		score := 4 + len(ci.Fields)
+		if ci.Kind == tagUniqueIdx {
+			score++
+		}
+
+		if score > bestScore {
+			best, bestScore = plan, score
+		}
+	}
+
+	return best
+}
+
+// planCompound returns a lookup plan for the given compound index if every field of the index
+// is compared for equality with a single non zero value.
+func planCompound(conjuncts []q.Matcher, ci *compoundIndex, typ reflect.Type, c codec.MarshalUnmarshaler) *indexPlan {
+	var buf bytes.Buffer
+	var positions []int
+
+	for _, f := range ci.Fields {
+		sf, ok := internal.StructFieldByPath(typ, f.Name)
+		if !ok {
+			return nil
+		}
+
+		found := false
+		for i, m := range conjuncts {
+			cmp, ok := q.Inspect(m)
+			if !ok || cmp.Field != f.Name || cmp.Token != token.EQL || len(cmp.Values) != 1 {
+				continue
+			}
+
+			// zero values are not indexed
+			v, ok := convertIndexValue(cmp.Values[0], sf.Type)
+			if !ok || isZero(&v) {
+				continue
+			}
+
+			raw, err := toIndexBytes(v.Interface(), c)
+			if err != nil {
+				continue
+			}
+
+			writeTupleElem(&buf, raw)
+			positions = append(positions, i)
+			found = true
+			break
+		}
+
+		if !found {
+			return nil
+		}
+	}
+
+	return &indexPlan{
+		field:    ci.Name,
+		kind:     ci.Kind,
+		values:   [][]byte{buf.Bytes()},
+		residual: residualMatcher(conjuncts, positions...),
+	}
+}
+
+// planField returns a plan for the comparisons made on a single indexed field and a score
+// reflecting how selective it is expected to be.
+func planField(conjuncts []q.Matcher, positions []int, typ, fieldType reflect.Type, c codec.MarshalUnmarshaler) (*indexPlan, int) {
+	// zero values are never stored in indexes, the index can't be used
+	// if a zero value could match
+	zero := reflect.New(typ).Interface()
+	matchesZero := true
+	for _, i := range positions {
+		ok, err := conjuncts[i].Match(zero)
+		if err != nil {
+			return nil, 0
+		}
+		if !ok {
+			matchesZero = false
+			break
+		}
+	}
+	if matchesZero {
+		return nil, 0
+	}
+
+	var min, max []byte
+	var hasMin, hasMax bool
+	for _, i := range positions {
+		cmp, _ := q.Inspect(conjuncts[i])
+
+		if cmp.Token == token.EQL {
+			values, ok := exactIndexValues(cmp.Values, fieldType, c)
+			if !ok {
+				continue
+			}
+
+			return &indexPlan{
+				values:   values,
+				residual: residualMatcher(conjuncts, i),
+			}, 4
+		}
+
+		if !isOrderPreserving(fieldType) {
+			continue
+		}
+
+		v, ok := convertIndexValue(cmp.Values[0], fieldType)
+		if !ok {
+			continue
+		}
+
+		raw, err := toIndexBytes(v.Interface(), c)
+		if err != nil {
+			continue
+		}
+
+		switch cmp.Token {
+		case token.GTR, token.GEQ:
+			if !hasMin || bytes.Compare(raw, min) > 0 {
+				min, hasMin = raw, true
+			}
+		case token.LSS, token.LEQ:
+			if !hasMax || bytes.Compare(raw, max) < 0 {
+				max, hasMax = raw, true
+			}
+		}
+	}
+
+	if !hasMin && !hasMax {
+		return nil, 0
+	}
+
+	score := 1
+	if hasMin && hasMax {
+		score = 2
+	}
+
+	return &indexPlan{
+		min:      min,
+		max:      max,
+		residual: residualMatcher(conjuncts),
+	}, score
+}
+
+// residualMatcher returns a matcher combining all the conjuncts except the ones at the skipped positions.
+func residualMatcher(conjuncts []q.Matcher, skip ...int) q.Matcher {
+	var list []q.Matcher
+	for i, m := range conjuncts {
+		if !containsInt(skip, i) {
+			list = append(list, m)
+		}
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	return q.And(list...)
+}
+
+func containsInt(list []int, n int) bool {
+	for _, i := range list {
+		if i == n {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exactIndexValues encodes the given values the same way they are stored in the index.
+func exactIndexValues(values []interface{}, fieldType reflect.Type, c codec.MarshalUnmarshaler) ([][]byte, bool) {
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	list := make([][]byte, 0, len(values))
+	for _, value := range values {
+		v, ok := convertIndexValue(value, fieldType)
+		if !ok {
+			return nil, false
+		}
+
+		// empty values are never stored in indexes
+		raw, err := toIndexBytes(v.Interface(), c)
+		if err != nil || len(raw) == 0 {
+			return nil, false
+		}
+
+		list = append(list, raw)
+	}
+
+	return list, true
+}
+
+// convertIndexValue converts the given value to the type of the indexed field.
+// It returns false if the conversion isn't lossless or if the type can't be compared byte by byte.
+func convertIndexValue(value interface{}, fieldType reflect.Type) (reflect.Value, bool) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return v, false
+	}
+
+	switch {
+	case fieldType.Kind() == reflect.String || fieldType.Kind() == reflect.Bool:
+		if v.Kind() != fieldType.Kind() {
+			return v, false
+		}
+		return v.Convert(fieldType), true
+	case isNumberKind(fieldType.Kind()):
+		if !isNumberKind(v.Kind()) {
+			return v, false
+		}
+		if isSigned(v.Type()) && v.Int() < 0 && isUnsigned(fieldType) {
+			return v, false
+		}
+
+		cv := v.Convert(fieldType)
+		if cv.Convert(v.Type()).Interface() != v.Interface() {
+			return v, false
+		}
+		if isUnsigned(v.Type()) && isSigned(fieldType) && cv.Int() < 0 {
+			return v, false
+		}
+		return cv, true
+	case fieldType == timeType:
+		if v.Type() != timeType {
+			return v, false
+		}
+		return v, true
+	}
+
+	return v, false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// isOrderPreserving reports whether the encoded values of the given type sort like the values.
+func isOrderPreserving(typ reflect.Type) bool {
+	return typ.Kind() == reflect.String || isNumberKind(typ.Kind()) || typ == timeType
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64 && kind != reflect.Uintptr
+}
+
+func isSigned(typ reflect.Type) bool {
+	kind := typ.Kind()
+	return kind >= reflect.Int && kind <= reflect.Int64
+}
+
+func isUnsigned(typ reflect.Type) bool {
+	kind := typ.Kind()
+	return kind >= reflect.Uint && kind <= reflect.Uint64
+}
+
+// ids returns the sorted list of IDs selected by the plan.
+func (p *indexPlan) ids(bucket *bolt.Bucket, reverse bool) ([][]byte, error) {
+	if bucket.Bucket([]byte(indexPrefix+p.field)) == nil {
+		return nil, index.ErrNotFound
+	}
+
+	idx, err := getIndex(bucket, p.kind, p.field)
+	if err != nil {
+		return nil, err
+	}
+
+	var list [][]byte
+	if p.substrings != nil {
+		list = idx.(*index.TrigramIndex).Candidates(p.substrings...)
+	} else if p.values != nil {
+		for _, value := range p.values {
+			ids, err := idx.All(value, nil)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, ids...)
+		}
+	} else {
+		list, err = idx.Range(p.min, p.max, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// records are returned in the same order as a bucket scan
+	sort.Slice(list, func(i, j int) bool {
+		if reverse {
+			return bytes.Compare(list[i], list[j]) > 0
+		}
+		return bytes.Compare(list[i], list[j]) < 0
+	})
+
+	// remove duplicates
+	ids := list[:0]
+	for i := range list {
+		if i == 0 || !bytes.Equal(list[i], list[i-1]) {
+			ids = append(ids, list[i])
+		}
+	}
+
+	return ids, nil
+}
+
+// A QueryPlan describes how a query is executed.
+type QueryPlan struct {
+	// Bucket read by the query, from the root.
+	Bucket []string
+
+	// Index is the name of the indexed field or of the compound index used to select the records.
+	// It is empty if the whole bucket is scanned.
+	Index string
+
+	// IndexKind is the kind of the index, "index", "unique", "multi" or "trigram".
+	IndexKind string
+
+	// Range is true if the index is read over a range of values instead of looking up exact values or substrings.
+	Range bool
+
+	// Filters are the matchers applied on every record read, including the implicit filters
+	// removing the records marked as deleted and the expired records.
+	Filters []q.Matcher
+
+	// OrderBy lists the fields used to sort the records in memory, if any.
+	OrderBy []string
+
+	// BucketRows is the number of records stored in the bucket.
+	BucketRows int
+
+	// ReadRows is the number of records read and decoded by the query, before the filters
+	// are applied. It is counted from the index used by the query, or equal to BucketRows
+	// if the bucket is scanned.
+	ReadRows int
+}
+
+// FullScan reports whether every record of the bucket is read.
+func (p *QueryPlan) FullScan() bool {
+	return p.Index == ""
+}
+
+// Sort reports whether the records are sorted in memory.
+func (p *QueryPlan) Sort() bool {
+	return len(p.OrderBy) > 0
+}
+
+// String returns a human readable description of the plan.
+func (p *QueryPlan) String() string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "bucket: %s\n", strings.Join(p.Bucket, "/"))
+	switch {
+	case p.FullScan():
+		buf.WriteString("scan: full\n")
+	case p.Range:
+		fmt.Fprintf(&buf, "scan: %s index %s, range\n", p.IndexKind, p.Index)
+	default:
+		fmt.Fprintf(&buf, "scan: %s index %s, lookup\n", p.IndexKind, p.Index)
+	}
+
+	for _, m := range p.Filters {
+		fmt.Fprintf(&buf, "filter: %v\n", m)
+	}
+
+	if p.Sort() {
+		fmt.Fprintf(&buf, "sort: %s\n", strings.Join(p.OrderBy, ", "))
+	}
+
+	fmt.Fprintf(&buf, "rows: %d of %d", p.ReadRows, p.BucketRows)
+	return buf.String()
+}
+
+// explain returns the plan the given query would use to run with the given sink.
+// The index is read to count the selected records, the records themselves are not read.
+func explain(tx *bolt.Tx, qry *query, sink sink) (*QueryPlan, error) {
+	bucketName := qry.bucket
+	if bucketName == "" {
+		bucketName = sink.bucketName()
+	}
+
+	implicit := implicitFilters(qry.node, sink)
+	plan := QueryPlan{
+		Bucket:  append(append([]string{}, qry.node.rootBucket...), bucketName),
+		Filters: append(q.Conjuncts(qry.tree), implicit...),
+		OrderBy: qry.orderBy,
+	}
+
+	bucket := qry.node.GetBucket(tx, bucketName)
+	if bucket == nil {
+		return &plan, nil
+	}
+
+	plan.BucketRows = countBucketRows(bucket)
+	plan.ReadRows = plan.BucketRows
+
+	idxPlan, ids, err := qry.lookupIndex(bucket, sink)
+	if err != nil {
+		return nil, err
+	}
+
+	if idxPlan != nil {
+		plan.Index = idxPlan.field
+		plan.IndexKind = idxPlan.kind
+		plan.Range = idxPlan.values == nil && idxPlan.substrings == nil
+		plan.Filters = append(q.Conjuncts(idxPlan.residual), implicit...)
+		plan.ReadRows = len(ids)
+	}
+
+	return &plan, nil
+}
+
+// implicitFilters returns the filters the sorter applies on the records read with the given sink,
+// in addition to the matchers of the query.
+func implicitFilters(n *node, snk sink) []q.Matcher {
+	s := newSorter(n, snk)
+
+	var filters []q.Matcher
+	if s.deleted != "" {
+		filters = append(filters, deletedFilter(s.deleted))
+	}
+	if s.expires != "" {
+		filters = append(filters, &expiryFilter{field: s.expires, now: s.now})
+	}
+
+	return filters
+}
+
+// deletedFilter removes the records marked as deleted by the field it names.
+type deletedFilter string
+
+func (f deletedFilter) Match(i interface{}) (bool, error) {
+	return !isDeleted(reflect.ValueOf(i), string(f)), nil
+}
+
+func (f deletedFilter) String() string {
+	return fmt.Sprintf("%s is not set", string(f))
+}
+
+// expiryFilter removes the records whose expiry time has passed.
+type expiryFilter struct {
+	field string
+	now   time.Time
+}
+
+func (f *expiryFilter) Match(i interface{}) (bool, error) {
+	return !isExpired(reflect.ValueOf(i), f.field, f.now), nil
+}
+
+func (f *expiryFilter) String() string {
+	return fmt.Sprintf("%s is zero or after %s", f.field, f.now.Format(time.RFC3339))
+}
+
+// countBucketRows counts the records of a bucket. Nested buckets, the Storm internal ones
+// or the buckets of nodes, are skipped without being read.
+func countBucketRows(bucket *bolt.Bucket) int {
+	var rows int
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil {
+			rows++
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,205 @@
+package storm
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3/codec/json"
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanIndex(t *testing.T) {
+	typ := reflect.TypeOf(User{})
+
+	plan := planIndex(q.And(q.Eq("Group", "a"), q.Eq("Name", "John")), typ, json.Codec)
+	require.NotNil(t, plan)
+	require.Equal(t, "Name", plan.field)
+	require.Equal(t, tagIdx, plan.kind)
+	require.Equal(t, [][]byte{[]byte("John")}, plan.values)
+	require.NotNil(t, plan.residual)
+
+	// unique indexes are preferred
+	plan = planIndex(q.And(q.Eq("Name", "John"), q.Eq("Slug", "john")), typ, json.Codec)
+	require.NotNil(t, plan)
+	require.Equal(t, "Slug", plan.field)
+	require.Equal(t, tagUniqueIdx, plan.kind)
+
+	// exact lookups are preferred over ranges
+	plan = planIndex(q.And(q.Gte("Slug", "a"), q.Eq("Name", "John")), typ, json.Codec)
+	require.NotNil(t, plan)
+	require.Equal(t, "Name", plan.field)
+
+	plan = planIndex(q.Eq("Name", "John"), typ, json.Codec)
+	require.NotNil(t, plan)
+	require.Nil(t, plan.residual)
+
+	plan = planIndex(q.And(q.Gt("Slug", "a"), q.Lte("Slug", "c")), typ, json.Codec)
+	require.NotNil(t, plan)
+	require.Equal(t, []byte("a"), plan.min)
+	require.Equal(t, []byte("c"), plan.max)
+	require.Nil(t, plan.values)
+
+	// not indexed
+	require.Nil(t, planIndex(q.Eq("Group", "a"), typ, json.Codec))
+	// zero values are not indexed
+	require.Nil(t, planIndex(q.Eq("Name", ""), typ, json.Codec))
+	require.Nil(t, planIndex(q.Lt("Slug", "c"), typ, json.Codec))
+	// negative numbers don't sort
+	require.Nil(t, planIndex(q.Gte("Age", -5), typ, json.Codec))
+	// lossy conversion
+	require.Nil(t, planIndex(q.Eq("Age", 5.5), typ, json.Codec))
+	require.Nil(t, planIndex(q.Or(q.Eq("Name", "John")), typ, json.Codec))
+	require.Nil(t, planIndex(nil, typ, json.Codec))
+}
+
+func TestExplain(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	plan, err := db.Select(q.Eq("Name", "John")).Explain(&User{})
+	require.NoError(t, err)
+	require.True(t, plan.FullScan())
+	require.Equal(t, []string{"User"}, plan.Bucket)
+	require.Zero(t, plan.BucketRows)
+
+	for i := 0; i < 20; i++ {
+		err := db.Save(&User{ID: i + 1, Name: fmt.Sprintf("John%d", i%4), Slug: fmt.Sprintf("john-%02d", i), Group: "staff"})
+		require.NoError(t, err)
+	}
+
+	plan, err = db.Select(q.Eq("Group", "staff")).OrderBy("Name").Explain(&User{})
+	require.NoError(t, err)
+	require.True(t, plan.FullScan())
+	require.True(t, plan.Sort())
+	require.Len(t, plan.Filters, 1)
+	require.Equal(t, 20, plan.BucketRows)
+	require.Equal(t, 20, plan.ReadRows)
+	require.Equal(t, "bucket: User\nscan: full\nfilter: Group == staff\nsort: Name\nrows: 20 of 20", plan.String())
+
+	plan, err = db.Select(q.Eq("Name", "John1"), q.Eq("Group", "staff")).Explain(&User{})
+	require.NoError(t, err)
+	require.False(t, plan.FullScan())
+	require.False(t, plan.Sort())
+	require.Equal(t, "Name", plan.Index)
+	require.Equal(t, "index", plan.IndexKind)
+	require.False(t, plan.Range)
+	require.Equal(t, 5, plan.ReadRows)
+	require.Equal(t, "bucket: User\nscan: index index Name, lookup\nfilter: Group == staff\nrows: 5 of 20", plan.String())
+
+	plan, err = db.Select(q.Gte("Slug", "john-05"), q.Lt("Slug", "john-10")).Explain(&User{})
+	require.NoError(t, err)
+	require.Equal(t, "Slug", plan.Index)
+	require.Equal(t, "unique", plan.IndexKind)
+	require.True(t, plan.Range)
+	require.Len(t, plan.Filters, 2)
+	require.Equal(t, 6, plan.ReadRows)
+
+	plan, err = db.From("a").Select(q.Eq("Name", "John1")).Bucket("Other").Explain(&User{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "Other"}, plan.Bucket)
+
+	_, err = db.Select().Explain(nil)
+	require.Equal(t, ErrStructPtrNeeded, err)
+
+	// the records of nodes nested in the bucket are not counted
+	for i := 0; i < 3; i++ {
+		require.NoError(t, db.From("User").Save(&User{ID: i + 1, Name: "Jim", Slug: fmt.Sprintf("jim-%d", i)}))
+	}
+	plan, err = db.Select().Explain(&User{})
+	require.NoError(t, err)
+	require.Equal(t, 20, plan.BucketRows)
+}
+
+func TestExplainImplicitFilters(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, cleanup := createDB(t, Clock(func() time.Time {
+		return now
+	}))
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Task{Name: "a", Group: "g"}))
+	require.NoError(t, db.Save(&Task{Name: "b", Group: "g"}))
+	require.NoError(t, db.DeleteStruct(&Task{ID: 2}))
+
+	plan, err := db.Select(q.Eq("Group", "g")).Explain(&Task{})
+	require.NoError(t, err)
+	require.Equal(t, "Group", plan.Index)
+	require.Equal(t, 2, plan.ReadRows)
+	require.Equal(t, "bucket: Task\nscan: index index Group, lookup\nfilter: DeletedAt is not set\nrows: 2 of 2", plan.String())
+
+	plan, err = db.IncludeDeleted().Select(q.Eq("Group", "g")).Explain(&Task{})
+	require.NoError(t, err)
+	require.Empty(t, plan.Filters)
+
+	plan, err = db.Select(q.Eq("User", "john")).Explain(&Session{})
+	require.NoError(t, err)
+	require.Len(t, plan.Filters, 2)
+	require.Equal(t, "ExpiresAt is zero or after 2020-01-01T00:00:00Z", fmt.Sprint(plan.Filters[1]))
+}
+
+func TestExplainProjection(t *testing.T) {
+	db, cleanup := prepareArticleDB(t)
+	defer cleanup()
+
+	type summary struct {
+		ID    int
+		Title string
+	}
+
+	// the plan is built for the projected kind, like with Find
+	plan, err := db.Select(q.Eq("Author", "John")).Project(new(Article), "Title").Explain(new(summary))
+	require.NoError(t, err)
+	require.Equal(t, []string{"Article"}, plan.Bucket)
+	require.Equal(t, "Author", plan.Index)
+	require.Equal(t, 2, plan.ReadRows)
+}
+
+func TestPlanMultiIndex(t *testing.T) {
+	typ := reflect.TypeOf(Post{})
+
+	plan := planIndex(q.Contains("Tags", "go"), typ, json.Codec)
+	require.NotNil(t, plan)
+	require.Equal(t, "Tags", plan.field)
+	require.Equal(t, tagMulti, plan.kind)
+	require.Equal(t, [][]byte{[]byte("go")}, plan.values)
+
+	plan = planIndex(q.In("Voters", []int{1, 2}), typ, json.Codec)
+	require.NotNil(t, plan)
+	require.Equal(t, "Voters", plan.field)
+	require.Len(t, plan.values, 2)
+
+	// the field is compared as a whole
+	require.Nil(t, planIndex(q.Eq("Tags", "go"), typ, json.Codec))
+	require.Nil(t, planIndex(q.Gt("Tags", "go"), typ, json.Codec))
+
+	// empty values are not indexed
+	require.Nil(t, planIndex(q.Contains("Tags", ""), typ, json.Codec))
+
+	// the elements of slices are not in regular indexes
+	require.Nil(t, planIndex(q.Contains("Words", "go"), typ, json.Codec))
+
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Post{Title: "Storm", Tags: []string{"go", "db"}}))
+	require.NoError(t, db.Save(&Post{Title: "Bolt", Tags: []string{"db"}}))
+	require.NoError(t, db.Save(&Post{Title: "Rust", Tags: []string{"rust"}}))
+
+	query := db.Select(q.In("Tags", []string{"go", "db"}))
+	qp, err := query.Explain(new(Post))
+	require.NoError(t, err)
+	require.Equal(t, "Tags", qp.Index)
+	require.Equal(t, tagMulti, qp.IndexKind)
+
+	var list []Post
+	require.NoError(t, query.Find(&list))
+	require.Len(t, list, 2)
+	require.Equal(t, "Storm", list[0].Title)
+	require.Equal(t, "Bolt", list[1].Title)
+
+	require.NoError(t, db.Select(q.Contains("Tags", "rust"), q.Eq("Title", "Rust")).Find(&list))
+	require.Len(t, list, 1)
+}
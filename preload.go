@@ -0,0 +1,258 @@
+package storm
+
+import (
+	"reflect"
+
+	"github.com/asdine/storm/v3/index"
+	bolt "go.etcd.io/bbolt"
+)
+
+// preload fills the given fields of the records held by the target,
+// a pointer to a struct or to a slice of structs or of pointers to structs.
+func (n *node) preload(tx *bolt.Tx, target reflect.Value, fields []string) error {
+	var records []reflect.Value
+	v := reflect.Indirect(target)
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			records = append(records, reflect.Indirect(v.Index(i)))
+		}
+	} else {
+		records = append(records, v)
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	typ := records[0].Type()
+	kind := reflect.New(typ)
+	cfg, err := extract(&kind)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		f, ok := typ.FieldByName(field)
+		if !ok || f.PkgPath != "" {
+			return ErrBadPreload
+		}
+
+		switch {
+		case f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct:
+			err = n.preloadRef(tx, cfg, records, field, f.Type.Elem())
+		case f.Type.Kind() == reflect.Slice:
+			err = n.preloadReferrers(tx, cfg, records, field, f.Type)
+		default:
+			err = ErrBadPreload
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// refField returns the name of the field of the struct referencing the records of the given type.
+// If a preloaded field is given, the field named after it with an ID suffix is preferred,
+// otherwise it is the only field referencing the type.
+func refField(cfg *structConfig, field string, typ reflect.Type) (string, bool) {
+	if f, ok := cfg.Fields[field+"ID"]; ok && field != "" && f.Ref == typ.Name() {
+		return field + "ID", true
+	}
+
+	var name string
+	for fieldName, f := range cfg.Fields {
+		if f.Ref != typ.Name() {
+			continue
+		}
+
+		if name != "" {
+			return "", false
+		}
+		name = fieldName
+	}
+
+	return name, name != ""
+}
+
+// preloadRef sets the given pointer field of each record to the record it references.
+// Each referenced record is read once. The field is set to nil if there is no such record.
+func (n *node) preloadRef(tx *bolt.Tx, cfg *structConfig, records []reflect.Value, field string, typ reflect.Type) error {
+	ref, ok := refField(cfg, field, typ)
+	if !ok {
+		return ErrBadPreload
+	}
+
+	bucket := n.GetBucket(tx, typ.Name())
+	deleted, expires, now := n.filteredDeleted(typ), expiryField(typ), n.s.clock()
+	loaded := make(map[string]reflect.Value)
+
+	for _, record := range records {
+		if err := n.ctxErr(); err != nil {
+			return err
+		}
+
+		f := record.FieldByName(field)
+		f.Set(reflect.Zero(f.Type()))
+
+		idv := record.FieldByName(ref)
+		if bucket == nil || isZero(&idv) {
+			continue
+		}
+
+		id, err := toBytes(idv.Interface(), n.codec)
+		if err != nil {
+			return err
+		}
+
+		target, ok := loaded[string(id)]
+		if !ok {
+			raw := bucket.Get(id)
+			if raw != nil {
+				target = reflect.New(typ)
+				err = n.codecAt(typ.Name(), id).Unmarshal(raw, target.Interface())
+				if err != nil {
+					return err
+				}
+
+				if isDeleted(target, deleted) || isExpired(target, expires, now) {
+					target = reflect.Value{}
+				} else {
+					err = n.afterLoad(tx, target.Interface())
+					if err != nil {
+						return err
+					}
+				}
+			}
+			loaded[string(id)] = target
+		}
+
+		if target.IsValid() {
+			f.Set(target)
+		}
+	}
+
+	return nil
+}
+
+// preloadReferrers sets the given slice field of each record to the records referencing it.
+// The index of the referencing field is opened once, and the referencing records of the records
+// sharing the same ID are read once.
+func (n *node) preloadReferrers(tx *bolt.Tx, cfg *structConfig, records []reflect.Value, field string, sliceType reflect.Type) error {
+	elemType := sliceType.Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	if elemType.Kind() != reflect.Struct {
+		return ErrBadPreload
+	}
+
+	elem := reflect.New(elemType)
+	ecfg, err := extract(&elem)
+	if err != nil {
+		return err
+	}
+
+	ref, ok := refField(ecfg, "", cfg.Type)
+	if !ok {
+		return ErrBadPreload
+	}
+
+	// the records are grouped by ID to look each of them up once
+	var values []string
+	groups := make(map[string][]reflect.Value)
+	for _, record := range records {
+		value, err := toFieldIndexBytes(record.FieldByName(cfg.ID.Name).Interface(), ecfg.Fields[ref], n.codec)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := groups[string(value)]; !ok {
+			values = append(values, string(value))
+		}
+		groups[string(value)] = append(groups[string(value)], record)
+		record.FieldByName(field).Set(reflect.Zero(sliceType))
+	}
+
+	bucket := n.GetBucket(tx, ecfg.Name)
+	if bucket == nil {
+		return nil
+	}
+
+	// legacy indexes can't be read, Find scans the bucket instead
+	if hasLegacyIndexes(bucket) {
+		nn := n.WithTransaction(tx)
+		for _, record := range records {
+			list := reflect.New(sliceType)
+			err := nn.Find(ref, record.FieldByName(cfg.ID.Name).Interface(), list.Interface())
+			if err != nil && err != ErrNotFound {
+				return err
+			}
+
+			record.FieldByName(field).Set(list.Elem())
+		}
+
+		return nil
+	}
+
+	idx, err := getIndex(bucket, ecfg.Fields[ref].Index, ref)
+	if err == index.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	deleted, expires, now := n.filteredDeleted(elemType), expiryField(elemType), n.s.clock()
+	for _, value := range values {
+		if err := n.ctxErr(); err != nil {
+			return err
+		}
+
+		ids, err := idx.All([]byte(value), nil)
+		if err != nil {
+			return err
+		}
+
+		list := reflect.MakeSlice(sliceType, 0, len(ids))
+		for _, id := range ids {
+			raw := bucket.Get(id)
+			if raw == nil {
+				return ErrNotFound
+			}
+
+			elem := reflect.New(elemType)
+			err = n.codecAt(ecfg.Name, id).Unmarshal(raw, elem.Interface())
+			if err != nil {
+				return err
+			}
+
+			if isDeleted(elem, deleted) || isExpired(elem, expires, now) {
+				continue
+			}
+
+			err = n.afterLoad(tx, elem.Interface())
+			if err != nil {
+				return err
+			}
+
+			if sliceType.Elem().Kind() == reflect.Ptr {
+				list = reflect.Append(list, elem)
+			} else {
+				list = reflect.Append(list, elem.Elem())
+			}
+		}
+
+		if list.Len() == 0 {
+			continue
+		}
+
+		for _, record := range groups[value] {
+			record.FieldByName(field).Set(list)
+		}
+	}
+
+	return nil
+}
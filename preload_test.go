@@ -0,0 +1,98 @@
+package storm
+
+import (
+	"testing"
+
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+type Team struct {
+	ID      int       `storm:"increment"`
+	Name    string    `storm:"index"`
+	Players []*Player `json:"-"`
+}
+
+type Player struct {
+	ID      int    `storm:"increment"`
+	Name    string `storm:"index"`
+	TeamID  int    `storm:"ref=Team:setnull"`
+	CoachID int    `storm:"ref=Team:setnull"`
+	Team    *Team  `json:"-"`
+}
+
+func TestPreload(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Team{Name: "a"}))
+	require.NoError(t, db.Save(&Team{Name: "b"}))
+	require.NoError(t, db.Save(&Player{Name: "john", TeamID: 1}))
+	require.NoError(t, db.Save(&Player{Name: "jack", TeamID: 1}))
+	require.NoError(t, db.Save(&Player{Name: "jim", TeamID: 2}))
+	require.NoError(t, db.Save(&Player{Name: "joe"}))
+
+	var players []Player
+	require.NoError(t, db.Select().Preload("Team").Find(&players))
+	require.Len(t, players, 4)
+	require.Equal(t, "a", players[0].Team.Name)
+	require.True(t, players[0].Team == players[1].Team)
+	require.Equal(t, "b", players[2].Team.Name)
+	require.Nil(t, players[3].Team)
+
+	var player Player
+	require.NoError(t, db.Select(q.Eq("Name", "jim")).Preload("Team").First(&player))
+	require.Equal(t, "b", player.Team.Name)
+
+	// the referencing field must be unique
+	var teams []Team
+	require.Equal(t, ErrBadPreload, db.Select().Preload("Players").Find(&teams))
+	require.Equal(t, ErrBadPreload, db.Select().Preload("Name").Find(&teams))
+	require.Equal(t, ErrBadPreload, db.Select().Preload("Unknown").Find(&teams))
+}
+
+type Album struct {
+	ID     int     `storm:"increment"`
+	Tracks []Track `json:"-"`
+}
+
+type Track struct {
+	ID      int `storm:"increment"`
+	Title   string
+	AlbumID int `storm:"ref=Album:cascade"`
+}
+
+func TestPreloadReferrers(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Album{}))
+	require.NoError(t, db.Save(&Album{}))
+	require.NoError(t, db.Save(&Track{Title: "a", AlbumID: 1}))
+	require.NoError(t, db.Save(&Track{Title: "b", AlbumID: 1}))
+
+	var albums []*Album
+	require.NoError(t, db.Select().Preload("Tracks").Find(&albums))
+	require.Len(t, albums, 2)
+	require.Len(t, albums[0].Tracks, 2)
+	require.Equal(t, "b", albums[0].Tracks[1].Title)
+	require.Empty(t, albums[1].Tracks)
+
+	// the tracks of all the albums are read with the same index
+	require.NoError(t, db.Save(&Track{Title: "c", AlbumID: 2}))
+	require.NoError(t, db.Save(&Track{Title: "d", AlbumID: 1}))
+	require.NoError(t, db.Select().Reverse().Preload("Tracks").Find(&albums))
+	require.Len(t, albums[0].Tracks, 1)
+	require.Equal(t, "c", albums[0].Tracks[0].Title)
+	require.Len(t, albums[1].Tracks, 3)
+	require.Equal(t, "d", albums[1].Tracks[2].Title)
+
+	// legacy indexes are not read
+	require.NoError(t, db.Bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("Track")).Bucket([]byte(metadataBucket)).Delete([]byte(metaIndexEncoding))
+	}))
+	require.NoError(t, db.Select().Preload("Tracks").Find(&albums))
+	require.Len(t, albums[0].Tracks, 3)
+	require.Len(t, albums[1].Tracks, 1)
+}
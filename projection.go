@@ -0,0 +1,235 @@
+package storm
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/asdine/storm/v3/codec"
+	"github.com/asdine/storm/v3/q"
+)
+
+// projection of a query on some fields of a kind of records.
+type projection struct {
+	kind   interface{}
+	fields []string
+}
+
+func newProjectSink(node Node, p *projection, to interface{}, first bool, tree q.Matcher, orderBy []string) (*projectSink, error) {
+	ref := reflect.ValueOf(p.kind)
+	if !ref.IsValid() || ref.Kind() != reflect.Ptr || ref.Elem().Kind() != reflect.Struct {
+		return nil, ErrStructPtrNeeded
+	}
+
+	kind := ref.Elem().Type()
+	if kind.Name() == "" {
+		return nil, ErrNoName
+	}
+
+	for _, field := range p.fields {
+		if _, ok := kind.FieldByName(field); !ok {
+			return nil, q.ErrUnknownField
+		}
+	}
+
+	target := reflect.ValueOf(to)
+	if !target.IsValid() || target.Kind() != reflect.Ptr {
+		return nil, ErrPtrNeeded
+	}
+
+	elemType := target.Elem().Type()
+	if !first {
+		if elemType.Kind() != reflect.Slice {
+			return nil, ErrSlicePtrNeeded
+		}
+		elemType = elemType.Elem()
+	}
+
+	isPtr := elemType.Kind() == reflect.Ptr && !first
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+
+	if err := checkProjectionTarget(kind, p.fields, elemType); err != nil {
+		return nil, err
+	}
+
+	s := projectSink{
+		ref:        target,
+		first:      first,
+		isPtr:      isPtr,
+		elemType:   elemType,
+		kind:       kind,
+		decodeType: kind,
+		fields:     p.fields,
+	}
+
+	// partial decoding ignores the location of the records
+	_, bound := node.Codec().(codec.Binder)
+	if pu, ok := node.Codec().(codec.PartialUnmarshaler); ok && !bound {
+		// the deleted and expiry fields are decoded to filter out deleted and expired records
+		fields := p.fields
+		if name := nodeOf(node).filteredDeleted(kind); name != "" {
+			fields = append(fields[:len(fields):len(fields)], name)
+		}
+		if name := expiryField(kind); name != "" {
+			fields = append(fields[:len(fields):len(fields)], name)
+		}
+
+		if typ := partialType(kind, fields, tree, orderBy); typ != nil {
+			s.decodeType = typ
+			s.partial = pu
+		}
+	}
+
+	if !first {
+		s.results = reflect.MakeSlice(target.Elem().Type(), 0, 0)
+	}
+
+	return &s, nil
+}
+
+// checkProjectionTarget checks that the projected fields of the given kind can be stored in
+// the given type, a struct declaring these fields or a map indexed by field name.
+func checkProjectionTarget(kind reflect.Type, fields []string, target reflect.Type) error {
+	for _, field := range fields {
+		f, _ := kind.FieldByName(field)
+
+		switch target.Kind() {
+		case reflect.Struct:
+			tf, ok := target.FieldByName(field)
+			if !ok || tf.PkgPath != "" || !f.Type.AssignableTo(tf.Type) {
+				return ErrIncompatibleValue
+			}
+		case reflect.Map:
+			if target.Key().Kind() != reflect.String || !f.Type.AssignableTo(target.Elem()) {
+				return ErrIncompatibleValue
+			}
+		default:
+			return ErrIncompatibleValue
+		}
+	}
+
+	return nil
+}
+
+// partialType returns a struct type declaring only the fields of the given kind that are projected,
+// matched or sorted on. It returns nil if the records must be fully decoded, e.g. when
+// a custom matcher is used or when one of the fields is embedded.
+func partialType(kind reflect.Type, fields []string, tree q.Matcher, orderBy []string) reflect.Type {
+	matched, ok := q.Fields(tree)
+	if !ok {
+		return nil
+	}
+
+	needed := make(map[string]bool)
+	for _, list := range [][]string{fields, matched, orderBy} {
+		for _, field := range list {
+			// nested fields are decoded with their parent
+			field = strings.SplitN(field, ".", 2)[0]
+
+			f, ok := kind.FieldByName(field)
+			if !ok || len(f.Index) != 1 || f.Anonymous || f.PkgPath != "" {
+				return nil
+			}
+			needed[field] = true
+		}
+	}
+
+	// fields are declared in the same order as the original type
+	var structFields []reflect.StructField
+	for i := 0; i < kind.NumField(); i++ {
+		f := kind.Field(i)
+		if needed[f.Name] {
+			structFields = append(structFields, reflect.StructField{
+				Name: f.Name,
+				Type: f.Type,
+				Tag:  f.Tag,
+			})
+		}
+	}
+
+	return reflect.StructOf(structFields)
+}
+
+type projectSink struct {
+	ref      reflect.Value
+	results  reflect.Value
+	first    bool
+	found    bool
+	isPtr    bool
+	elemType reflect.Type
+
+	// type of the records and type they are decoded into
+	kind       reflect.Type
+	decodeType reflect.Type
+	partial    codec.PartialUnmarshaler
+	fields     []string
+}
+
+func (p *projectSink) elem() reflect.Value {
+	return reflect.New(p.decodeType)
+}
+
+func (p *projectSink) decode(c codec.MarshalUnmarshaler, raw []byte, to interface{}) error {
+	if p.partial == nil {
+		return c.Unmarshal(raw, to)
+	}
+
+	return p.partial.UnmarshalPartial(raw, to)
+}
+
+func (p *projectSink) bucketName() string {
+	return p.kind.Name()
+}
+
+func (p *projectSink) add(i *item) error {
+	src := i.value.Elem()
+
+	var dst reflect.Value
+	if p.elemType.Kind() == reflect.Map {
+		dst = reflect.MakeMap(p.elemType)
+		for _, field := range p.fields {
+			dst.SetMapIndex(reflect.ValueOf(field).Convert(p.elemType.Key()), src.FieldByName(field))
+		}
+	} else {
+		dst = reflect.New(p.elemType).Elem()
+		for _, field := range p.fields {
+			dst.FieldByName(field).Set(src.FieldByName(field))
+		}
+	}
+
+	if p.first {
+		p.ref.Elem().Set(dst)
+		p.found = true
+		return nil
+	}
+
+	if p.isPtr {
+		ptr := reflect.New(p.elemType)
+		ptr.Elem().Set(dst)
+		dst = ptr
+	}
+
+	p.results = reflect.Append(p.results, dst)
+	return nil
+}
+
+func (p *projectSink) flush() error {
+	if p.first {
+		if !p.found {
+			return ErrNotFound
+		}
+		return nil
+	}
+
+	if p.results.Len() == 0 {
+		return ErrNotFound
+	}
+
+	p.ref.Elem().Set(p.results)
+	return nil
+}
+
+func (p *projectSink) readOnly() bool {
+	return true
+}
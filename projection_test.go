@@ -0,0 +1,141 @@
+package storm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/asdine/storm/v3/codec/gob"
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+)
+
+type Article struct {
+	ID     int    `storm:"increment"`
+	Title  string `storm:"index"`
+	Author string `storm:"index"`
+	Views  int
+	Body   []byte
+}
+
+func prepareArticleDB(t *testing.T, options ...func(*Options) error) (*DB, func()) {
+	db, cleanup := createDB(t, options...)
+
+	articles := []Article{
+		{Title: "Storm", Author: "John", Views: 10, Body: []byte("storm")},
+		{Title: "Bolt", Author: "Jane", Views: 30, Body: []byte("bolt")},
+		{Title: "Go", Author: "John", Views: 20, Body: []byte("go")},
+	}
+
+	for i := range articles {
+		require.NoError(t, db.Save(&articles[i]))
+	}
+
+	return db, cleanup
+}
+
+func TestProjection(t *testing.T) {
+	db, cleanup := prepareArticleDB(t)
+	defer cleanup()
+
+	type summary struct {
+		Title string
+		Views int
+	}
+
+	var list []summary
+	err := db.Select(q.Eq("Author", "John")).Project(new(Article), "Title", "Views").Find(&list)
+	require.NoError(t, err)
+	require.Equal(t, []summary{{"Storm", 10}, {"Go", 20}}, list)
+
+	var ptrs []*summary
+	err = db.Select(q.Gt("Views", 10)).OrderBy("Views").Reverse().Project(new(Article), "Title").Find(&ptrs)
+	require.NoError(t, err)
+	require.Len(t, ptrs, 2)
+	require.Equal(t, &summary{Title: "Bolt"}, ptrs[0])
+	require.Equal(t, &summary{Title: "Go"}, ptrs[1])
+
+	var maps []map[string]interface{}
+	err = db.Select().Limit(2).Project(new(Article), "Title", "Author").Find(&maps)
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{
+		{"Title": "Storm", "Author": "John"},
+		{"Title": "Bolt", "Author": "Jane"},
+	}, maps)
+
+	var first summary
+	err = db.Select(q.Eq("Title", "Go")).Project(new(Article), "Title", "Views").First(&first)
+	require.NoError(t, err)
+	require.Equal(t, summary{"Go", 20}, first)
+
+	var m map[string]interface{}
+	err = db.Select(q.Eq("Title", "Go")).Project(new(Article), "Views").First(&m)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"Views": 20}, m)
+
+	err = db.Select(q.Eq("Title", "C")).Project(new(Article), "Views").First(&m)
+	require.Equal(t, ErrNotFound, err)
+
+	err = db.Select(q.Eq("Title", "C")).Project(new(Article), "Views").Find(&list)
+	require.Equal(t, ErrNotFound, err)
+
+	// custom field matchers only read their field
+	err = db.Select(q.NewFieldMatcher("Body", matcherFunc(func(v interface{}) (bool, error) {
+		return string(v.([]byte)) == "bolt", nil
+	}))).Project(new(Article), "Title").Find(&list)
+	require.NoError(t, err)
+	require.Equal(t, []summary{{Title: "Bolt"}}, list)
+
+	err = db.Select().Project(new(Article), "Name").Find(&list)
+	require.Equal(t, q.ErrUnknownField, err)
+
+	err = db.Select().Project(new(Article), "Author").Find(&list)
+	require.Equal(t, ErrIncompatibleValue, err)
+
+	var views []map[string]string
+	err = db.Select().Project(new(Article), "Views").Find(&views)
+	require.Equal(t, ErrIncompatibleValue, err)
+
+	err = db.Select().Project(new(Article), "Title").Find(&first)
+	require.Equal(t, ErrSlicePtrNeeded, err)
+}
+
+func TestProjectionDecoding(t *testing.T) {
+	db, cleanup := prepareArticleDB(t)
+	defer cleanup()
+
+	sink, err := newProjectSink(db.Node, &projection{kind: new(Article), fields: []string{"Title"}}, new([]Article), false, q.Eq("Author", "John"), []string{"Views"})
+	require.NoError(t, err)
+	require.NotEqual(t, reflect.TypeOf(Article{}), sink.decodeType)
+	require.Equal(t, 3, sink.decodeType.NumField())
+	_, ok := sink.decodeType.FieldByName("Body")
+	require.False(t, ok)
+
+	// the index of the record type is used
+	plan := newQuery(db.Node.(*node), q.Eq("Author", "John")).planIndex(sink)
+	require.NotNil(t, plan)
+	require.Equal(t, "Author", plan.field)
+
+	// custom matchers may read the whole record
+	sink, err = newProjectSink(db.Node, &projection{kind: new(Article), fields: []string{"Title"}}, new([]Article), false, q.And(q.Eq("Author", "John"), recordMatcher{}), nil)
+	require.NoError(t, err)
+	require.Equal(t, reflect.TypeOf(Article{}), sink.decodeType)
+
+	// codecs that can't skip fields decode the whole record
+	gdb, gcleanup := prepareArticleDB(t, Codec(gob.Codec))
+	defer gcleanup()
+
+	sink, err = newProjectSink(gdb.Node, &projection{kind: new(Article), fields: []string{"Title"}}, new([]Article), false, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, reflect.TypeOf(Article{}), sink.decodeType)
+
+	var titles []map[string]interface{}
+	err = gdb.Select(q.Gte("Views", 20)).Project(new(Article), "Title").Find(&titles)
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{{"Title": "Bolt"}, {"Title": "Go"}}, titles)
+}
+
+type recordMatcher struct{}
+
+func (recordMatcher) Match(interface{}) (bool, error) {
+	return true, nil
+}
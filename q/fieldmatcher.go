@@ -2,8 +2,11 @@ package q
 
 import (
 	"errors"
+	"fmt"
 	"go/token"
 	"reflect"
+
+	"github.com/asdine/storm/v3/internal"
 )
 
 // ErrUnknownField is returned when an unknown field is passed.
@@ -15,6 +18,7 @@ type fieldMatcherDelegate struct {
 }
 
 // NewFieldMatcher creates a Matcher for a given field.
+// Fields of nested structs can be designated by a dotted path, e.g. "Address.City".
 func NewFieldMatcher(field string, fm FieldMatcher) Matcher {
 	return fieldMatcherDelegate{Field: field, FieldMatcher: fm}
 }
@@ -32,13 +36,21 @@ func (r fieldMatcherDelegate) Match(i interface{}) (bool, error) {
 }
 
 func (r fieldMatcherDelegate) MatchValue(v *reflect.Value) (bool, error) {
-	field := v.FieldByName(r.Field)
+	field := internal.FieldByPath(*v, r.Field)
 	if !field.IsValid() {
 		return false, ErrUnknownField
 	}
 	return r.MatchField(field.Interface())
 }
 
+func (r fieldMatcherDelegate) String() string {
+	if s, ok := r.FieldMatcher.(fmt.Stringer); ok {
+		return r.Field + " " + s.String()
+	}
+
+	return r.Field + " " + fmt.Sprintf("%T", r.FieldMatcher)
+}
+
 // NewField2FieldMatcher creates a Matcher for a given field1 and field2.
 func NewField2FieldMatcher(field1, field2 string, tok token.Token) Matcher {
 	return field2fieldMatcherDelegate{Field1: field1, Field2: field2, Tok: tok}
@@ -55,13 +67,17 @@ func (r field2fieldMatcherDelegate) Match(i interface{}) (bool, error) {
 }
 
 func (r field2fieldMatcherDelegate) MatchValue(v *reflect.Value) (bool, error) {
-	field1 := v.FieldByName(r.Field1)
+	field1 := internal.FieldByPath(*v, r.Field1)
 	if !field1.IsValid() {
 		return false, ErrUnknownField
 	}
-	field2 := v.FieldByName(r.Field2)
+	field2 := internal.FieldByPath(*v, r.Field2)
 	if !field2.IsValid() {
 		return false, ErrUnknownField
 	}
 	return compare(field1.Interface(), field2.Interface(), r.Tok), nil
 }
+
+func (r field2fieldMatcherDelegate) String() string {
+	return fmt.Sprintf("%s %s %s", r.Field1, r.Tok, r.Field2)
+}
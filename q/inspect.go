@@ -0,0 +1,168 @@
+package q
+
+import (
+	"go/token"
+	"reflect"
+	"regexp"
+)
+
+// A Comparison describes a matcher that compares a single field with one or more constant values.
+// It is used by query engines to decide if a matcher can be answered by an index.
+type Comparison struct {
+	// Field is the name of the compared field.
+	Field string
+
+	// Token is the comparison operator. In and Eq matchers both use token.EQL.
+	Token token.Token
+
+	// Values compared with the field. The comparison matches if it is true for at least one of them.
+	Values []interface{}
+
+	// Elements is true if the values are also compared with the elements of the field
+	// when it is a slice, an array or a map, as done by In and Contains.
+	Elements bool
+}
+
+// Inspect returns the Comparison described by the given matcher.
+// It returns false if the matcher is not a simple field comparison, e.g. an Or, a Re or a custom matcher.
+func Inspect(m Matcher) (*Comparison, bool) {
+	fm, ok := m.(fieldMatcherDelegate)
+	if !ok {
+		return nil, false
+	}
+
+	switch t := fm.FieldMatcher.(type) {
+	case *cmp:
+		return &Comparison{
+			Field:  fm.Field,
+			Token:  t.token,
+			Values: []interface{}{t.value},
+		}, true
+	case *in:
+		ref := reflect.ValueOf(t.list)
+		if ref.Kind() != reflect.Slice {
+			return nil, false
+		}
+
+		values := make([]interface{}, ref.Len())
+		for i := range values {
+			values[i] = ref.Index(i).Interface()
+		}
+
+		return &Comparison{
+			Field:    fm.Field,
+			Token:    token.EQL,
+			Values:   values,
+			Elements: true,
+		}, true
+	case *contains:
+		return &Comparison{
+			Field:    fm.Field,
+			Token:    token.EQL,
+			Values:   []interface{}{t.value},
+			Elements: true,
+		}, true
+	}
+
+	return nil, false
+}
+
+// A Pattern describes a matcher that matches a field with a regular expression, as done by Re and Like.
+type Pattern struct {
+	// Field is the name of the matched field.
+	Field string
+
+	// Regexp is the regular expression matched by the field.
+	Regexp *regexp.Regexp
+}
+
+// InspectPattern returns the Pattern described by the given matcher.
+// It returns false if the matcher is not a Re or a Like matcher, or if its regular expression is invalid.
+func InspectPattern(m Matcher) (*Pattern, bool) {
+	fm, ok := m.(fieldMatcherDelegate)
+	if !ok {
+		return nil, false
+	}
+
+	r, ok := fm.FieldMatcher.(*regexpMatcher)
+	if !ok || r.err != nil {
+		return nil, false
+	}
+
+	return &Pattern{Field: fm.Field, Regexp: r.r}, true
+}
+
+// Conjuncts returns the list of matchers that must all match for the given matcher to match.
+// And matchers are flattened recursively, any other matcher is returned as is.
+func Conjuncts(m Matcher) []Matcher {
+	if m == nil {
+		return nil
+	}
+
+	a, ok := m.(*and)
+	if !ok {
+		return []Matcher{m}
+	}
+
+	var list []Matcher
+	for _, child := range a.children {
+		list = append(list, Conjuncts(child)...)
+	}
+
+	return list
+}
+
+// Fields returns the names of the fields read by the given matcher, in order of appearance.
+// It returns false if the matcher, or one of its children, is a custom matcher whose fields are unknown.
+func Fields(m Matcher) ([]string, bool) {
+	var fields []string
+
+	var walk func(m Matcher) bool
+	walk = func(m Matcher) bool {
+		var children []Matcher
+
+		switch t := m.(type) {
+		case nil, *trueMatcher:
+			return true
+		case fieldMatcherDelegate:
+			fields = appendField(fields, t.Field)
+			return true
+		case field2fieldMatcherDelegate:
+			fields = appendField(fields, t.Field1)
+			fields = appendField(fields, t.Field2)
+			return true
+		case *and:
+			children = t.children
+		case *or:
+			children = t.children
+		case *not:
+			children = t.children
+		default:
+			return false
+		}
+
+		for _, child := range children {
+			if !walk(child) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if !walk(m) {
+		return nil, false
+	}
+
+	return fields, true
+}
+
+func appendField(fields []string, field string) []string {
+	for _, f := range fields {
+		if f == field {
+			return fields
+		}
+	}
+
+	return append(fields, field)
+}
@@ -0,0 +1,92 @@
+package q
+
+import (
+	"fmt"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	c, ok := Inspect(Eq("Name", "John"))
+	require.True(t, ok)
+	require.Equal(t, &Comparison{Field: "Name", Token: token.EQL, Values: []interface{}{"John"}}, c)
+
+	c, ok = Inspect(Gte("Age", 10))
+	require.True(t, ok)
+	require.Equal(t, &Comparison{Field: "Age", Token: token.GEQ, Values: []interface{}{10}}, c)
+
+	c, ok = Inspect(In("Age", []int{1, 2}))
+	require.True(t, ok)
+	require.Equal(t, &Comparison{Field: "Age", Token: token.EQL, Values: []interface{}{1, 2}, Elements: true}, c)
+
+	c, ok = Inspect(Contains("Tags", "go"))
+	require.True(t, ok)
+	require.Equal(t, &Comparison{Field: "Tags", Token: token.EQL, Values: []interface{}{"go"}, Elements: true}, c)
+
+	_, ok = Inspect(In("Age", 1))
+	require.False(t, ok)
+
+	_, ok = Inspect(Re("Name", "^J"))
+	require.False(t, ok)
+
+	_, ok = Inspect(And(Eq("Name", "John")))
+	require.False(t, ok)
+}
+
+func TestInspectPattern(t *testing.T) {
+	p, ok := InspectPattern(Re("Name", "^J"))
+	require.True(t, ok)
+	require.Equal(t, "Name", p.Field)
+	require.Equal(t, "^J", p.Regexp.String())
+
+	p, ok = InspectPattern(Like("Name", "%a.b_"))
+	require.True(t, ok)
+	require.Equal(t, `(?is)^.*a\.b.$`, p.Regexp.String())
+
+	_, ok = InspectPattern(Re("Name", "("))
+	require.False(t, ok)
+
+	_, ok = InspectPattern(Eq("Name", "John"))
+	require.False(t, ok)
+}
+
+func TestConjuncts(t *testing.T) {
+	a, b, c := Eq("A", 1), Eq("B", 2), Or(Eq("C", 3))
+
+	require.Nil(t, Conjuncts(nil))
+	require.Equal(t, []Matcher{a}, Conjuncts(a))
+	require.Equal(t, []Matcher{a, b, c}, Conjuncts(And(a, And(b, c))))
+	require.Equal(t, []Matcher{c}, Conjuncts(c))
+}
+
+func TestFields(t *testing.T) {
+	fields, ok := Fields(nil)
+	require.True(t, ok)
+	require.Empty(t, fields)
+
+	fields, ok = Fields(And(Eq("Name", "John"), Or(Gt("Age", 10), Re("Name", "^J")), Not(EqF("A", "B")), True()))
+	require.True(t, ok)
+	require.Equal(t, []string{"Name", "Age", "A", "B"}, fields)
+
+	_, ok = Fields(And(Eq("Name", "John"), custom{}))
+	require.False(t, ok)
+}
+
+type custom struct{}
+
+func (custom) Match(interface{}) (bool, error) { return true, nil }
+
+func TestMatcherString(t *testing.T) {
+	m := And(
+		Eq("Name", "John"),
+		Or(Gt("Age", 10), Not(In("Group", []string{"a", "b"}))),
+		Re("Name", "^J"),
+		StrictEq("Age", 5),
+		LteF("Age", "Max"),
+		True(),
+	)
+
+	require.Equal(t, "and(Name == John, or(Age > 10, not(Group in [a b])), Name =~ ^J, Age === 5, Age <= Max, true)", m.(fmt.Stringer).String())
+}
@@ -3,6 +3,7 @@ package q
 import (
 	"fmt"
 	"regexp"
+	"strings"
 	"sync"
 )
 
@@ -26,6 +27,27 @@ func Re(field string, re string) Matcher {
 	return NewFieldMatcher(field, &regexpMatcher{r: r, err: err})
 }
 
+// Like creates a matcher checking if the given string field matches the given pattern, ignoring case.
+// As in SQL, % matches any sequence of characters and _ matches a single character,
+// e.g. "%phone%" matches the values containing "phone".
+func Like(field string, pattern string) Matcher {
+	var buf strings.Builder
+	buf.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			buf.WriteString(".*")
+		case '_':
+			buf.WriteString(".")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	buf.WriteString("$")
+
+	return Re(field, buf.String())
+}
+
 var regexpCache = struct {
 	sync.RWMutex
 	m map[string]*regexp.Regexp
@@ -36,6 +58,14 @@ type regexpMatcher struct {
 	err error
 }
 
+func (r *regexpMatcher) String() string {
+	if r.err != nil {
+		return "=~ (" + r.err.Error() + ")"
+	}
+
+	return "=~ " + r.r.String()
+}
+
 func (r *regexpMatcher) MatchField(v interface{}) (bool, error) {
 	if r.err != nil {
 		return false, r.err
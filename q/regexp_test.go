@@ -12,6 +12,23 @@ type StringAndBytes struct {
 	C int
 }
 
+func TestLike(t *testing.T) {
+	a := StringAndBytes{A: "Smart Phone 2"}
+
+	for pattern, expected := range map[string]bool{
+		"%phone%":       true,
+		"smart phone _": true,
+		"smart%":        true,
+		"phone%":        false,
+		"%phone":        false,
+		"smart.phone%":  false,
+	} {
+		ok, err := Like("A", pattern).Match(&a)
+		require.NoError(t, err)
+		require.Equal(t, expected, ok, pattern)
+	}
+}
+
 func TestRe(t *testing.T) {
 	a := StringAndBytes{
 		A: "ABC",
@@ -2,8 +2,10 @@
 package q
 
 import (
+	"fmt"
 	"go/token"
 	"reflect"
+	"strings"
 )
 
 // A Matcher is used to test against a record to see if it matches.
@@ -28,6 +30,10 @@ func (c *cmp) MatchField(v interface{}) (bool, error) {
 	return compare(v, c.value, c.token), nil
 }
 
+func (c *cmp) String() string {
+	return fmt.Sprintf("%s %v", c.token, c.value)
+}
+
 type trueMatcher struct{}
 
 func (*trueMatcher) Match(i interface{}) (bool, error) {
@@ -38,6 +44,10 @@ func (*trueMatcher) MatchValue(v *reflect.Value) (bool, error) {
 	return true, nil
 }
 
+func (*trueMatcher) String() string {
+	return "true"
+}
+
 type or struct {
 	children []Matcher
 }
@@ -72,10 +82,18 @@ func (c *or) MatchValue(v *reflect.Value) (bool, error) {
 	return false, nil
 }
 
+func (c *or) String() string {
+	return "or(" + joinMatchers(c.children) + ")"
+}
+
 type and struct {
 	children []Matcher
 }
 
+func (c *and) String() string {
+	return "and(" + joinMatchers(c.children) + ")"
+}
+
 func (c *and) Match(i interface{}) (bool, error) {
 	v := reflect.Indirect(reflect.ValueOf(i))
 	return c.MatchValue(&v)
@@ -115,6 +133,10 @@ func (s *strictEq) MatchField(v interface{}) (bool, error) {
 	return reflect.DeepEqual(v, s.value), nil
 }
 
+func (s *strictEq) String() string {
+	return fmt.Sprintf("=== %v", s.value)
+}
+
 type in struct {
 	list interface{}
 }
@@ -125,28 +147,82 @@ func (i *in) MatchField(v interface{}) (bool, error) {
 		return false, nil
 	}
 
-	c := cmp{
-		token: token.EQL,
+	return anyElement(v, func(e interface{}) bool {
+		for i := 0; i < ref.Len(); i++ {
+			if compare(e, ref.Index(i).Interface(), token.EQL) {
+				return true
+			}
+		}
+
+		return false
+	}), nil
+}
+
+func (i *in) String() string {
+	return fmt.Sprintf("in %v", i.list)
+}
+
+type contains struct {
+	value interface{}
+}
+
+func (c *contains) MatchField(v interface{}) (bool, error) {
+	return anyElement(v, func(e interface{}) bool {
+		return compare(e, c.value, token.EQL)
+	}), nil
+}
+
+func (c *contains) String() string {
+	return fmt.Sprintf("contains %v", c.value)
+}
+
+// anyElement reports whether the given value, or one of its elements
+// if it is a slice, an array or the keys of a map, satisfies fn.
+func anyElement(v interface{}, fn func(interface{}) bool) bool {
+	if fn(v) {
+		return true
 	}
 
-	for i := 0; i < ref.Len(); i++ {
-		c.value = ref.Index(i).Interface()
-		ok, err := c.MatchField(v)
-		if err != nil {
-			return false, err
+	ref := reflect.ValueOf(v)
+	switch ref.Kind() {
+	case reflect.Slice, reflect.Array:
+		if ref.Type().Elem().Kind() == reflect.Uint8 {
+			return false
 		}
-		if ok {
-			return true, nil
+
+		for i := 0; i < ref.Len(); i++ {
+			if fn(ref.Index(i).Interface()) {
+				return true
+			}
+		}
+	case reflect.Map:
+		for _, k := range ref.MapKeys() {
+			if fn(k.Interface()) {
+				return true
+			}
 		}
 	}
 
-	return false, nil
+	return false
 }
 
 type not struct {
 	children []Matcher
 }
 
+func (n *not) String() string {
+	return "not(" + joinMatchers(n.children) + ")"
+}
+
+func joinMatchers(matchers []Matcher) string {
+	list := make([]string, len(matchers))
+	for i, m := range matchers {
+		list[i] = fmt.Sprint(m)
+	}
+
+	return strings.Join(list, ", ")
+}
+
 func (n *not) Match(i interface{}) (bool, error) {
 	v := reflect.Indirect(reflect.ValueOf(i))
 	return n.MatchValue(&v)
@@ -229,11 +305,18 @@ func LteF(field1, field2 string) Matcher {
 }
 
 // In matcher, checks if the given field matches one of the value of the given slice.
+// If the field is a slice, an array or a map, it also matches if one of its elements, or keys, is in the slice.
 // v must be a slice.
 func In(field string, v interface{}) Matcher {
 	return NewFieldMatcher(field, &in{list: v})
 }
 
+// Contains matcher, checks if the given field, a slice, an array or a map, holds the given value.
+// The keys of maps are compared with the value. Fields of other types must be equal to the value.
+func Contains(field string, v interface{}) Matcher {
+	return NewFieldMatcher(field, &contains{value: v})
+}
+
 // True matcher, always returns true
 func True() Matcher { return &trueMatcher{} }
 
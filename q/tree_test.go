@@ -66,6 +66,15 @@ func TestCompare(t *testing.T) {
 	require.True(t, compare(uint32(10), float32(5), token.GTR))
 	require.True(t, compare(int32(10), uint32(5), token.GTR))
 	require.True(t, compare(float32(10), uint32(5), token.GTR))
+
+	// nil slices are compared like nil pointers, and never panic when compared with other kinds
+	var nilSlice []int
+	require.True(t, compare(nilSlice, nil, token.EQL))
+	require.True(t, compare(nil, nilSlice, token.EQL))
+	require.True(t, compare((*A)(nil), nilSlice, token.EQL))
+	require.False(t, compare(nilSlice, 5, token.EQL))
+	require.False(t, compare(nilSlice, "a", token.EQL))
+	require.False(t, compare([]int{5}, nilSlice, token.EQL))
 }
 
 func TestCmp(t *testing.T) {
@@ -172,6 +181,75 @@ func TestIn(t *testing.T) {
 	ok, err = q.Match(&a)
 	require.NoError(t, err)
 	require.False(t, ok)
+
+	type T struct {
+		Tags   []string
+		Scores map[int]bool
+		Raw    []byte
+	}
+
+	b := T{Tags: []string{"go", "db"}, Scores: map[int]bool{10: true}, Raw: []byte("go")}
+
+	ok, err = In("Tags", []string{"rust", "db"}).Match(&b)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = In("Tags", []string{"rust"}).Match(&b)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ok, err = In("Scores", []int{1, 10}).Match(&b)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// a whole slice still matches, byte slices are not split
+	ok, err = In("Tags", [][]string{{"go", "db"}}).Match(&b)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = In("Raw", []byte("go")).Match(&b)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// nil slices match no element
+	ok, err = In("Tags", []string{"go"}).Match(&T{})
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestContains(t *testing.T) {
+	type T struct {
+		Name   string
+		Tags   []string
+		Scores map[int]bool
+		Raw    []byte
+		Nums   [2]int
+		Empty  []string
+	}
+
+	a := T{Name: "John", Tags: []string{"go", "db"}, Scores: map[int]bool{10: true}, Raw: []byte("go"), Nums: [2]int{1, 2}}
+
+	tests := []struct {
+		m     Matcher
+		match bool
+	}{
+		{Contains("Tags", "go"), true},
+		{Contains("Tags", "rust"), false},
+		{Contains("Scores", 10), true},
+		{Contains("Scores", 10.0), true},
+		{Contains("Scores", true), false},
+		{Contains("Nums", 2), true},
+		{Contains("Raw", byte('g')), false},
+		{Contains("Name", "John"), true},
+		{Contains("Name", "Jo"), false},
+		{Contains("Empty", "go"), false},
+	}
+
+	for _, test := range tests {
+		ok, err := test.m.Match(&a)
+		require.NoError(t, err)
+		require.Equal(t, test.match, ok, "%s", test.m)
+	}
 }
 
 func TestAnd(t *testing.T) {
@@ -286,3 +364,28 @@ func TestAndOr(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, ok)
 }
+
+func TestNestedField(t *testing.T) {
+	type C struct {
+		Owner  A
+		Parent *B
+	}
+
+	c := C{Owner: A{Age: 10, Name: "John"}}
+
+	ok, err := Eq("Owner.Name", "John").Match(&c)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = EqF("Owner.Age", "Parent.Age").Match(&c)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// nil structs hold zero values
+	ok, err = Eq("Parent.Age", 0).Match(&c)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, err = Eq("Owner.Group", "admin").Match(&c)
+	require.Equal(t, ErrUnknownField, err)
+}
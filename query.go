@@ -1,12 +1,18 @@
 package storm
 
 import (
+	"bytes"
+	"reflect"
+	"sort"
+
+	"github.com/asdine/storm/v3/index"
 	"github.com/asdine/storm/v3/internal"
 	"github.com/asdine/storm/v3/q"
 	bolt "go.etcd.io/bbolt"
 )
 
-// Select a list of records that match a list of matchers. Doesn't use indexes.
+// Select a list of records that match a list of matchers.
+// Comparisons on indexed fields are used to avoid scanning the whole bucket when possible.
 func (n *node) Select(matchers ...q.Matcher) Query {
 	tree := q.And(matchers...)
 	return newQuery(n, tree)
@@ -26,9 +32,21 @@ type Query interface {
 	// Reverse the order of the results
 	Reverse() Query
 
+	// After returns the records following the one the given continuation token was created for.
+	// See Node.Token.
+	After(token string) Query
+
 	// Bucket specifies the bucket name
 	Bucket(string) Query
 
+	// Project decodes only the given fields of the records of the given kind when calling Find or First.
+	// Results can be structs declaring these fields or maps indexed by field name.
+	Project(kind interface{}, fields ...string) Query
+
+	// Preload fills the given fields of the records returned by Find or First with the records
+	// they reference, for pointers, or with the records referencing them, for slices.
+	Preload(fields ...string) Query
+
 	// Find a list of matching records
 	Find(interface{}) error
 
@@ -41,6 +59,24 @@ type Query interface {
 	// Count all the matching records
 	Count(interface{}) (int, error)
 
+	// Sum returns the sum of the given numeric field of the matching records
+	Sum(kind interface{}, field string) (float64, error)
+
+	// Avg returns the average of the given numeric field of the matching records
+	Avg(kind interface{}, field string) (float64, error)
+
+	// Min returns the smallest value of the given field of the matching records
+	Min(kind interface{}, field string) (interface{}, error)
+
+	// Max returns the greatest value of the given field of the matching records
+	Max(kind interface{}, field string) (interface{}, error)
+
+	// Distinct returns the distinct values of the given field of the matching records, in order of appearance
+	Distinct(kind interface{}, field string) ([]interface{}, error)
+
+	// GroupBy groups the matching records by the value of the given field
+	GroupBy(field string) GroupQuery
+
 	// Returns all the records without decoding them
 	Raw() ([][]byte, error)
 
@@ -49,6 +85,13 @@ type Query interface {
 
 	// Execute the given function for each element
 	Each(interface{}, func(interface{}) error) error
+
+	// Iter returns an iterator reading the matching records one at a time
+	Iter() Iterator
+
+	// Explain describes how the query would be executed for the given kind of records,
+	// or for the projected kind if the query is projected
+	Explain(interface{}) (*QueryPlan, error)
 }
 
 func newQuery(n *node, tree q.Matcher) *query {
@@ -68,6 +111,10 @@ type query struct {
 	node    *node
 	bucket  string
 	orderBy []string
+	after   *index.Position
+	project *projection
+	preload []string
+	err     error
 }
 
 func (q *query) Skip(nb int) Query {
@@ -90,12 +137,39 @@ func (q *query) Reverse() Query {
 	return q
 }
 
+func (q *query) After(token string) Query {
+	q.after, q.err = decodeToken(token)
+	return q
+}
+
 func (q *query) Bucket(bucketName string) Query {
 	q.bucket = bucketName
 	return q
 }
 
+func (q *query) Project(kind interface{}, fields ...string) Query {
+	q.project = &projection{
+		kind:   kind,
+		fields: fields,
+	}
+	return q
+}
+
+func (q *query) Preload(fields ...string) Query {
+	q.preload = append(q.preload, fields...)
+	return q
+}
+
 func (q *query) Find(to interface{}) error {
+	if q.project != nil {
+		sink, err := newProjectSink(q.node, q.project, to, false, q.tree, q.orderBy)
+		if err != nil {
+			return err
+		}
+
+		return q.runQuery(sink)
+	}
+
 	sink, err := newListSink(q.node, to)
 	if err != nil {
 		return err
@@ -105,6 +179,16 @@ func (q *query) Find(to interface{}) error {
 }
 
 func (q *query) First(to interface{}) error {
+	if q.project != nil {
+		sink, err := newProjectSink(q.node, q.project, to, true, q.tree, q.orderBy)
+		if err != nil {
+			return err
+		}
+
+		q.limit = 1
+		return q.runQuery(sink)
+	}
+
 	sink, err := newFirstSink(q.node, to)
 	if err != nil {
 		return err
@@ -137,6 +221,74 @@ func (q *query) Count(kind interface{}) (int, error) {
 	return sink.counter, nil
 }
 
+func (q *query) Sum(kind interface{}, field string) (float64, error) {
+	sink, err := q.aggregate(kind, field, "", func() aggregate { return new(sumAggregate) })
+	if err != nil {
+		return 0, err
+	}
+
+	return sink.result().(*sumAggregate).sum, nil
+}
+
+func (q *query) Avg(kind interface{}, field string) (float64, error) {
+	sink, err := q.aggregate(kind, field, "", func() aggregate { return new(sumAggregate) })
+	if err != nil {
+		return 0, err
+	}
+
+	return sink.result().(*sumAggregate).avg()
+}
+
+func (q *query) Min(kind interface{}, field string) (interface{}, error) {
+	return q.bound(kind, field, false)
+}
+
+func (q *query) Max(kind interface{}, field string) (interface{}, error) {
+	return q.bound(kind, field, true)
+}
+
+func (q *query) bound(kind interface{}, field string, max bool) (interface{}, error) {
+	sink, err := q.aggregate(kind, field, "", func() aggregate {
+		return &boundAggregate{codec: q.node.codec, max: max}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sink.result().(*boundAggregate).result()
+}
+
+func (q *query) Distinct(kind interface{}, field string) ([]interface{}, error) {
+	sink, err := q.aggregate(kind, field, "", func() aggregate { return new(distinctAggregate) })
+	if err != nil {
+		return nil, err
+	}
+
+	return sink.result().(*distinctAggregate).values, nil
+}
+
+func (q *query) GroupBy(field string) GroupQuery {
+	return &groupQuery{
+		query: q,
+		field: field,
+	}
+}
+
+// aggregate runs the query with a sink computing the aggregates of the given field.
+func (q *query) aggregate(kind interface{}, field, groupBy string, newAggregate func() aggregate) (*aggregateSink, error) {
+	sink, err := newAggregateSink(q.node, kind, field, groupBy, newAggregate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.runQuery(sink)
+	if err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+}
+
 func (q *query) Raw() ([][]byte, error) {
 	sink := newRawSink()
 
@@ -157,7 +309,7 @@ func (q *query) RawEach(fn func([]byte, []byte) error) error {
 }
 
 func (q *query) Each(kind interface{}, fn func(interface{}) error) error {
-	sink, err := newEachSink(kind)
+	sink, err := newEachSink(q.node, kind)
 	if err != nil {
 		return err
 	}
@@ -167,7 +319,37 @@ func (q *query) Each(kind interface{}, fn func(interface{}) error) error {
 	return q.runQuery(sink)
 }
 
+func (q *query) Iter() Iterator {
+	return newIterator(q)
+}
+
+func (q *query) Explain(kind interface{}) (*QueryPlan, error) {
+	var sink sink
+	var err error
+	if q.project != nil {
+		// projected records are planned on the projected kind, like with Find
+		sink, err = newProjectSink(q.node, q.project, &[]map[string]interface{}{}, false, q.tree, q.orderBy)
+	} else {
+		sink, err = newCountSink(q.node, kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var plan *QueryPlan
+	err = q.node.readTx(func(tx *bolt.Tx) error {
+		plan, err = explain(tx, q, sink)
+		return err
+	})
+
+	return plan, err
+}
+
 func (q *query) runQuery(sink sink) error {
+	if err := q.node.ctxErr(); err != nil {
+		return err
+	}
+
 	if q.node.tx != nil {
 		return q.query(q.node.tx, sink)
 	}
@@ -177,11 +359,35 @@ func (q *query) runQuery(sink sink) error {
 		})
 	}
 	return q.node.s.Bolt.Update(func(tx *bolt.Tx) error {
-		return q.query(tx, sink)
+		return q.node.withCtx(func(tx *bolt.Tx) error {
+			return q.query(tx, sink)
+		}, tx)
 	})
 }
 
 func (q *query) query(tx *bolt.Tx, sink sink) error {
+	err := q.collect(tx, sink)
+	if err != nil || len(q.preload) == 0 {
+		return err
+	}
+
+	if psink, ok := sink.(preloadSink); ok {
+		return q.node.preload(tx, psink.target(), q.preload)
+	}
+
+	return nil
+}
+
+// collect passes the records matching the query to the sink.
+func (q *query) collect(tx *bolt.Tx, sink sink) error {
+	if q.err != nil {
+		return q.err
+	}
+
+	if q.after != nil && len(q.orderBy) > 0 {
+		return ErrTokenWithOrderBy
+	}
+
 	bucketName := q.bucket
 	if bucketName == "" {
 		bucketName = sink.bucketName()
@@ -198,8 +404,23 @@ func (q *query) query(tx *bolt.Tx, sink sink) error {
 	sorter.skip = q.skip
 	sorter.limit = q.limit
 	if bucket != nil {
+		plan, ids, err := q.lookupIndex(bucket, sink)
+		if err != nil {
+			return err
+		}
+		if plan != nil {
+			return q.queryIDs(bucket, q.idsAfter(ids), plan.residual, sorter)
+		}
+
 		c := internal.Cursor{C: bucket.Cursor(), Reverse: q.reverse}
+		if q.after != nil {
+			c.After = q.after.ID
+		}
 		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if err := q.node.ctxErr(); err != nil {
+				return err
+			}
+
 			if v == nil {
 				continue
 			}
@@ -217,3 +438,175 @@ func (q *query) query(tx *bolt.Tx, sink sink) error {
 
 	return sorter.flush()
 }
+
+// planIndex returns the index plan of the query, or nil if the bucket must be scanned.
+func (q *query) planIndex(sink sink) *indexPlan {
+	rsink, ok := sink.(reflectSink)
+	if !ok {
+		return nil
+	}
+
+	typ := reflect.Indirect(rsink.elem()).Type()
+	if psink, ok := sink.(*projectSink); ok {
+		// projected records may be decoded into a type declaring only some of their fields
+		typ = psink.kind
+	}
+
+	return planIndex(q.tree, typ, q.node.codec)
+}
+
+// lookupIndex returns the IDs of the records selected by the index plan of the query.
+// It returns a nil plan if the whole bucket must be scanned.
+func (q *query) lookupIndex(bucket *bolt.Bucket, sink sink) (*indexPlan, [][]byte, error) {
+	plan := q.planIndex(sink)
+	if plan == nil || hasLegacyIndexes(bucket) {
+		return nil, nil, nil
+	}
+
+	ids, err := plan.ids(bucket, q.reverse)
+	if err != nil {
+		if err == index.ErrNotFound {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	return plan, ids, nil
+}
+
+// idsAfter removes the IDs up to the one of the continuation token from the given sorted list.
+func (q *query) idsAfter(ids [][]byte) [][]byte {
+	if q.after == nil {
+		return ids
+	}
+
+	i := sort.Search(len(ids), func(i int) bool {
+		cmp := bytes.Compare(ids[i], q.after.ID)
+		if q.reverse {
+			return cmp < 0
+		}
+		return cmp > 0
+	})
+
+	return ids[i:]
+}
+
+func (q *query) queryIDs(bucket *bolt.Bucket, ids [][]byte, tree q.Matcher, sorter *sorter) error {
+	for _, id := range ids {
+		if err := q.node.ctxErr(); err != nil {
+			return err
+		}
+
+		v := bucket.Get(id)
+		if v == nil {
+			continue
+		}
+
+		stop, err := sorter.filter(tree, bucket, id, v)
+		if err != nil {
+			return err
+		}
+
+		if stop {
+			break
+		}
+	}
+
+	return sorter.flush()
+}
+
+// GroupQuery computes aggregates for each distinct value of a field of the records matching a query.
+// Results are indexed by the value of the field.
+type GroupQuery interface {
+	// Count the matching records of each group
+	Count(kind interface{}) (map[interface{}]int, error)
+
+	// Sum returns the sum of the given numeric field for each group
+	Sum(kind interface{}, field string) (map[interface{}]float64, error)
+
+	// Avg returns the average of the given numeric field for each group
+	Avg(kind interface{}, field string) (map[interface{}]float64, error)
+
+	// Min returns the smallest value of the given field for each group
+	Min(kind interface{}, field string) (map[interface{}]interface{}, error)
+
+	// Max returns the greatest value of the given field for each group
+	Max(kind interface{}, field string) (map[interface{}]interface{}, error)
+}
+
+type groupQuery struct {
+	query *query
+	field string
+}
+
+func (g *groupQuery) Count(kind interface{}) (map[interface{}]int, error) {
+	sink, err := g.query.aggregate(kind, "", g.field, func() aggregate { return new(countAggregate) })
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[interface{}]int, len(sink.groups))
+	for key, agg := range sink.groups {
+		results[key] = agg.(*countAggregate).count
+	}
+
+	return results, nil
+}
+
+func (g *groupQuery) Sum(kind interface{}, field string) (map[interface{}]float64, error) {
+	sink, err := g.query.aggregate(kind, field, g.field, func() aggregate { return new(sumAggregate) })
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[interface{}]float64, len(sink.groups))
+	for key, agg := range sink.groups {
+		results[key] = agg.(*sumAggregate).sum
+	}
+
+	return results, nil
+}
+
+func (g *groupQuery) Avg(kind interface{}, field string) (map[interface{}]float64, error) {
+	sink, err := g.query.aggregate(kind, field, g.field, func() aggregate { return new(sumAggregate) })
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[interface{}]float64, len(sink.groups))
+	for key, agg := range sink.groups {
+		results[key], err = agg.(*sumAggregate).avg()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func (g *groupQuery) Min(kind interface{}, field string) (map[interface{}]interface{}, error) {
+	return g.bound(kind, field, false)
+}
+
+func (g *groupQuery) Max(kind interface{}, field string) (map[interface{}]interface{}, error) {
+	return g.bound(kind, field, true)
+}
+
+func (g *groupQuery) bound(kind interface{}, field string, max bool) (map[interface{}]interface{}, error) {
+	sink, err := g.query.aggregate(kind, field, g.field, func() aggregate {
+		return &boundAggregate{codec: g.query.node.codec, max: max}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[interface{}]interface{}, len(sink.groups))
+	for key, agg := range sink.groups {
+		results[key], err = agg.(*boundAggregate).result()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
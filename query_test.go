@@ -552,3 +552,184 @@ func TestSelectEach(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 2, i)
 }
+
+func TestSelectWithIndex(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	for i := 0; i < 50; i++ {
+		err := db.Save(&User{
+			ID:    i + 1,
+			Name:  fmt.Sprintf("John%d", i%10),
+			Age:   i % 7,
+			Slug:  fmt.Sprintf("john-%02d", i),
+			Group: fmt.Sprintf("Group%d", i%3),
+		})
+		require.NoError(t, err)
+	}
+
+	matchers := []q.Matcher{
+		q.Eq("Name", "John3"),
+		q.Eq("Slug", "john-12"),
+		q.Eq("Age", int8(3)),
+		q.Eq("Age", 0),
+		q.In("Name", []string{"John1", "John2", "John1"}),
+		q.And(q.Eq("Name", "John4"), q.Eq("Group", "Group1")),
+		q.And(q.Gte("Slug", "john-10"), q.Lt("Slug", "john-20")),
+		q.And(q.Gt("Age", 2), q.Lte("Age", 4)),
+		q.And(q.Gte("ID", 10), q.Eq("Group", "Group2")),
+		q.Lte("Age", 2),
+		q.Gt("Age", -3),
+		q.Eq("Name", "Unknown"),
+	}
+
+	for _, m := range matchers {
+		var expected, actual []User
+
+		// Or can't be answered by an index and forces a bucket scan
+		err := db.Select(q.Or(m)).Find(&expected)
+		if err != nil {
+			require.Equal(t, ErrNotFound, err)
+		}
+
+		err = db.Select(m).Find(&actual)
+		if err != nil {
+			require.Equal(t, ErrNotFound, err)
+		}
+		require.Equal(t, expected, actual)
+
+		err = db.Select(m).Reverse().Skip(1).Limit(3).Find(&actual)
+		if err != nil {
+			require.Equal(t, ErrNotFound, err)
+		}
+		err = db.Select(q.Or(m)).Reverse().Skip(1).Limit(3).Find(&expected)
+		if err != nil {
+			require.Equal(t, ErrNotFound, err)
+		}
+		require.Equal(t, expected, actual)
+	}
+
+	err := db.Select(q.Eq("Name", "John3")).Delete(&User{})
+	require.NoError(t, err)
+
+	var users []User
+	err = db.Find("Name", "John3", &users)
+	require.Equal(t, ErrNotFound, err)
+
+	count, err := db.Count(&User{})
+	require.NoError(t, err)
+	require.Equal(t, 45, count)
+}
+
+func TestSelectAggregates(t *testing.T) {
+	db, cleanup := prepareScoreDB(t)
+	defer cleanup()
+
+	sum, err := db.Select().Sum(new(Score), "Value")
+	require.NoError(t, err)
+	require.Equal(t, float64(190), sum)
+
+	sum, err = db.Select(q.Gte("Value", 10)).Skip(2).Limit(3).Sum(new(Score), "Value")
+	require.NoError(t, err)
+	require.Equal(t, float64(12+13+14), sum)
+
+	sum, err = db.Select(q.Gt("Value", 100)).Sum(new(Score), "Value")
+	require.NoError(t, err)
+	require.Zero(t, sum)
+
+	avg, err := db.Select(q.Lt("Value", 4)).Avg(new(Score), "Value")
+	require.NoError(t, err)
+	require.Equal(t, 1.5, avg)
+
+	_, err = db.Select(q.Gt("Value", 100)).Avg(new(Score), "Value")
+	require.Equal(t, ErrNotFound, err)
+
+	min, err := db.Select(q.Gt("Value", 5)).Min(new(Score), "Value")
+	require.NoError(t, err)
+	require.Equal(t, 6, min)
+
+	max, err := db.Select(q.Lt("Value", 5)).Max(new(Score), "Value")
+	require.NoError(t, err)
+	require.Equal(t, 4, max)
+
+	_, err = db.Select(q.Gt("Value", 100)).Max(new(Score), "Value")
+	require.Equal(t, ErrNotFound, err)
+
+	_, err = db.Select().Sum(new(Score), "Name")
+	require.Equal(t, q.ErrUnknownField, err)
+
+	_, err = db.Select().Sum(Score{}, "Value")
+	require.Equal(t, ErrStructPtrNeeded, err)
+
+	type Order struct {
+		ID       int `storm:"increment"`
+		Customer string
+		Status   string
+		Amount   float64
+		Items    []string
+		Extra    interface{}
+	}
+
+	orders := []Order{
+		{Customer: "John", Status: "paid", Amount: 10.5},
+		{Customer: "Jane", Status: "paid", Amount: 20},
+		{Customer: "John", Status: "refunded", Amount: 5, Extra: "late"},
+		{Customer: "Jack", Status: "paid", Amount: 7.5, Extra: []string{"gift"}},
+		{Customer: "John", Status: "paid", Amount: 2},
+	}
+	for i := range orders {
+		require.NoError(t, db.Save(&orders[i]))
+	}
+
+	_, err = db.Select().Sum(new(Order), "Customer")
+	require.Equal(t, ErrIncompatibleValue, err)
+
+	customers, err := db.Select(q.Eq("Status", "paid")).Distinct(new(Order), "Customer")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"John", "Jane", "Jack"}, customers)
+
+	last, err := db.Select().Max(new(Order), "Customer")
+	require.NoError(t, err)
+	require.Equal(t, "John", last)
+
+	counts, err := db.Select().GroupBy("Customer").Count(new(Order))
+	require.NoError(t, err)
+	require.Equal(t, map[interface{}]int{"John": 3, "Jane": 1, "Jack": 1}, counts)
+
+	sums, err := db.Select(q.Eq("Status", "paid")).GroupBy("Customer").Sum(new(Order), "Amount")
+	require.NoError(t, err)
+	require.Equal(t, map[interface{}]float64{"John": 12.5, "Jane": 20, "Jack": 7.5}, sums)
+
+	avgs, err := db.Select().GroupBy("Status").Avg(new(Order), "Amount")
+	require.NoError(t, err)
+	require.Equal(t, map[interface{}]float64{"paid": 10, "refunded": 5}, avgs)
+
+	mins, err := db.Select().GroupBy("Customer").Min(new(Order), "Amount")
+	require.NoError(t, err)
+	require.Equal(t, map[interface{}]interface{}{"John": 2.0, "Jane": 20.0, "Jack": 7.5}, mins)
+
+	maxs, err := db.Select().Limit(2).GroupBy("Customer").Max(new(Order), "Status")
+	require.NoError(t, err)
+	require.Equal(t, map[interface{}]interface{}{"John": "paid", "Jane": "paid"}, maxs)
+
+	counts, err = db.Select(q.Eq("Status", "unknown")).GroupBy("Customer").Count(new(Order))
+	require.NoError(t, err)
+	require.Empty(t, counts)
+
+	_, err = db.Select().GroupBy("Items").Count(new(Order))
+	require.Equal(t, ErrIncompatibleValue, err)
+
+	// the values held by interfaces are checked
+	extras, err := db.Select(q.Eq("Status", "refunded")).Distinct(new(Order), "Extra")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"late"}, extras)
+
+	_, err = db.Select().Distinct(new(Order), "Extra")
+	require.Equal(t, ErrIncompatibleValue, err)
+
+	_, err = db.Select().GroupBy("Extra").Count(new(Order))
+	require.Equal(t, ErrIncompatibleValue, err)
+
+	_, err = db.Select().GroupBy("Unknown").Count(new(Order))
+	require.Equal(t, q.ErrUnknownField, err)
+}
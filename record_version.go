@@ -0,0 +1,70 @@
+package storm
+
+import (
+	"reflect"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// versionField returns the config of the field tagged with version, if any.
+func versionField(cfg *structConfig) (string, *fieldConfig) {
+	for name, f := range cfg.Fields {
+		if f.IsVersion {
+			return name, f
+		}
+	}
+
+	return "", nil
+}
+
+// savedVersion returns the version of the saved record, or 0 if it isn't saved yet.
+// It returns ErrVersionConflict if it is not the version of the given record.
+func (n *node) savedVersion(tx *bolt.Tx, cfg *structConfig) (int64, error) {
+	name, f := versionField(cfg)
+	if f == nil {
+		return 0, nil
+	}
+
+	saved, err := n.savedRecord(tx, cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	var version int64
+	if saved.IsValid() {
+		version = intValue(saved.FieldByName(name))
+	}
+
+	if version != intValue(*f.Value) {
+		return 0, ErrVersionConflict
+	}
+
+	return version, nil
+}
+
+// setVersion sets the version of the record.
+func setVersion(cfg *structConfig, data interface{}, version int64) {
+	name, f := versionField(cfg)
+	if f == nil {
+		return
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(data)).FieldByName(name)
+	if v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64 {
+		v.SetUint(uint64(version))
+	} else {
+		v.SetInt(version)
+	}
+
+	f.Value = &v
+	f.IsZero = false
+	f.ForceUpdate = true
+}
+
+func intValue(v reflect.Value) int64 {
+	if v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64 {
+		return int64(v.Uint())
+	}
+
+	return v.Int()
+}
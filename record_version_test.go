@@ -0,0 +1,60 @@
+package storm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type Document struct {
+	ID      int `storm:"increment"`
+	Title   string
+	Version uint `storm:"version,index"`
+}
+
+func TestVersion(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	d := Document{Title: "a"}
+	require.NoError(t, db.Save(&d))
+	require.Equal(t, uint(1), d.Version)
+
+	// a record read before a concurrent save is outdated
+	var d1, d2 Document
+	require.NoError(t, db.One("ID", 1, &d1))
+	require.NoError(t, db.One("ID", 1, &d2))
+
+	d1.Title = "b"
+	require.NoError(t, db.Save(&d1))
+	require.Equal(t, uint(2), d1.Version)
+
+	d2.Title = "c"
+	require.Equal(t, ErrVersionConflict, db.Save(&d2))
+	require.Equal(t, ErrVersionConflict, db.Update(&Document{ID: 1, Title: "c", Version: 1}))
+	require.Equal(t, ErrVersionConflict, db.UpdateField(&Document{ID: 1}, "Title", "c"))
+
+	require.NoError(t, db.Update(&Document{ID: 1, Title: "c", Version: 2}))
+	require.NoError(t, db.UpdateField(&Document{ID: 1, Version: 3}, "Title", "d"))
+
+	// the updated record gets the new version
+	u := Document{ID: 1, Title: "d", Version: 4}
+	require.NoError(t, db.Update(&u))
+	require.Equal(t, uint(5), u.Version)
+	require.NoError(t, db.UpdateField(&u, "Title", "d"))
+	require.Equal(t, uint(6), u.Version)
+	require.NoError(t, db.Update(&Document{ID: 1, Version: 6}))
+
+	require.NoError(t, db.One("Version", uint(7), &d))
+	require.Equal(t, "d", d.Title)
+
+	// a deleted record can't be saved again with its version
+	require.NoError(t, db.DeleteStruct(&d))
+	require.Equal(t, ErrVersionConflict, db.Save(&d))
+
+	type BadVersion struct {
+		ID      int
+		Version string `storm:"version"`
+	}
+	require.Equal(t, ErrBadVersion, db.Save(&BadVersion{ID: 1}))
+}
@@ -0,0 +1,140 @@
+package storm
+
+import (
+	"bytes"
+
+	"github.com/asdine/storm/v3/codec"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ReEncrypt re-encrypts with the active key the values of all the buckets below the given node
+// that were saved with its codec, which must implement codec.ReEncrypter.
+// Values already encrypted with the active key are left as is, which makes it possible
+// to resume an interrupted re-encryption or to run it in the background.
+//
+// Values are re-encrypted in batches of batchSize values, each in its own write transaction,
+// or in the transaction of the node. A batchSize lower than 1 re-encrypts each bucket at once.
+func ReEncrypt(n Node, batchSize int) error {
+	if _, ok := n.Codec().(codec.ReEncrypter); !ok {
+		return ErrCannotReEncrypt
+	}
+
+	nn := nodeOf(n)
+
+	var buckets [][]string
+	err := nn.readTx(func(tx *bolt.Tx) error {
+		buckets = nn.encryptedBuckets(tx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range buckets {
+		var next []byte
+		for done := false; !done; {
+			err = nn.readWriteTx(func(tx *bolt.Tx) error {
+				var err error
+				next, done, err = nn.reEncrypt(tx, path, next, batchSize)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// encryptedBuckets returns the path of the buckets below the node whose values are encoded
+// with the codec of the node. Index and metadata buckets are skipped.
+func (n *node) encryptedBuckets(tx *bolt.Tx) [][]string {
+	var paths [][]string
+
+	var walk func(b *bolt.Bucket, path []string)
+	walk = func(b *bolt.Bucket, path []string) {
+		if m := b.Bucket([]byte(metadataBucket)); m != nil && string(m.Get([]byte(metaCodec))) == n.codec.Name() {
+			paths = append(paths, path)
+		}
+
+		b.ForEach(func(k, v []byte) error {
+			if v == nil && string(k) != metadataBucket && !bytes.HasPrefix(k, []byte(indexPrefix)) {
+				walk(b.Bucket(k), append(path[:len(path):len(path)], string(k)))
+			}
+			return nil
+		})
+	}
+
+	if len(n.rootBucket) > 0 {
+		if b := n.GetBucket(tx); b != nil {
+			walk(b, nil)
+		}
+		return paths
+	}
+
+	tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		walk(b, []string{string(name)})
+		return nil
+	})
+
+	return paths
+}
+
+// reEncrypt re-encrypts the values of the bucket found at the given path, starting after
+// the given key. It returns the last key read and reports whether the whole bucket was read.
+func (n *node) reEncrypt(tx *bolt.Tx, path []string, after []byte, batchSize int) ([]byte, bool, error) {
+	bucket := n.GetBucket(tx, path...)
+	if bucket == nil {
+		return nil, true, nil
+	}
+
+	// path of the bucket from the root of the database, the values may be bound to it
+	fullPath := append(n.rootBucket[:len(n.rootBucket):len(n.rootBucket)], path...)
+
+	// the batch is read before being written, bucket cursors are invalidated by writes
+	var keys, values [][]byte
+	var last []byte
+	c := bucket.Cursor()
+	k, v := c.First()
+	if after != nil {
+		k, v = c.Seek(after)
+		if bytes.Equal(k, after) {
+			k, v = c.Next()
+		}
+	}
+	for ; k != nil && (batchSize < 1 || len(keys) < batchSize); k, v = c.Next() {
+		last = append([]byte(nil), k...)
+		if v == nil {
+			continue
+		}
+
+		re, ok := bindCodec(n.codec, fullPath, k).(codec.ReEncrypter)
+		if !ok {
+			return nil, false, ErrCannotReEncrypt
+		}
+
+		raw, err := re.ReEncrypt(v)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if raw != nil {
+			keys = append(keys, last)
+			values = append(values, raw)
+		}
+	}
+
+	for i := range keys {
+		if err := n.ctxErr(); err != nil {
+			return nil, false, err
+		}
+
+		err := bucket.Put(keys[i], values[i])
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return last, k == nil, nil
+}
@@ -0,0 +1,104 @@
+package storm
+
+import (
+	"testing"
+
+	"github.com/asdine/storm/v3/codec/aes"
+	"github.com/asdine/storm/v3/codec/json"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReEncrypt(t *testing.T) {
+	key1 := []byte("0123456789abcdef")
+	key2 := []byte("fedcba9876543210")
+
+	k1, err := aes.NewKeyring(json.Codec, map[uint32][]byte{1: key1}, 1)
+	require.NoError(t, err)
+
+	db, cleanup := createDB(t, Codec(k1))
+	defer cleanup()
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, db.Save(&User{ID: i, Name: "John"}))
+		require.NoError(t, db.From("a", "b").Save(&SimpleUser{ID: i, Name: "Jack"}))
+	}
+	require.NoError(t, db.Set("config", "theme", &SimpleUser{Name: "dark"}))
+
+	err = ReEncrypt(db.WithCodec(json.Codec), 2)
+	require.Equal(t, ErrCannotReEncrypt, err)
+
+	k2, err := aes.NewKeyring(json.Codec, map[uint32][]byte{1: key1, 2: key2}, 2)
+	require.NoError(t, err)
+
+	n := db.WithCodec(k2)
+	require.NoError(t, n.Save(&User{ID: 6, Name: "John"}))
+
+	err = ReEncrypt(n, 2)
+	require.NoError(t, err)
+
+	// the first key is not needed anymore
+	k3, err := aes.NewKeyring(json.Codec, map[uint32][]byte{2: key2}, 2)
+	require.NoError(t, err)
+	n = db.WithCodec(k3)
+
+	var users []User
+	require.NoError(t, n.Find("Name", "John", &users))
+	require.Len(t, users, 6)
+
+	var simpleUsers []SimpleUser
+	require.NoError(t, n.From("a", "b").All(&simpleUsers))
+	require.Len(t, simpleUsers, 5)
+
+	var theme SimpleUser
+	require.NoError(t, n.Get("config", "theme", &theme))
+	require.Equal(t, "dark", theme.Name)
+
+	raw, err := n.GetBytes("User", 1)
+	require.NoError(t, err)
+	id, err := k3.KeyID(raw)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), id)
+
+	// only the buckets below the node are re-encrypted
+	k4, err := aes.NewKeyring(json.Codec, map[uint32][]byte{2: key2, 4: key1}, 4)
+	require.NoError(t, err)
+	require.NoError(t, ReEncrypt(db.From("a").WithCodec(k4), 0))
+
+	raw, err = n.GetBytes("User", 1)
+	require.NoError(t, err)
+	id, err = k3.KeyID(raw)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), id)
+
+	raw, err = n.From("a", "b").GetBytes("SimpleUser", 1)
+	require.NoError(t, err)
+	id, err = k4.KeyID(raw)
+	require.NoError(t, err)
+	require.Equal(t, uint32(4), id)
+}
+
+func TestReEncryptBindLocation(t *testing.T) {
+	key1 := []byte("0123456789abcdef")
+	key2 := []byte("fedcba9876543210")
+
+	k1, err := aes.NewKeyring(json.Codec, map[uint32][]byte{1: key1}, 1, aes.BindLocation())
+	require.NoError(t, err)
+
+	db, cleanup := createDB(t, Codec(k1), Root("root"))
+	defer cleanup()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, db.From("a").Save(&User{ID: i, Name: "John"}))
+	}
+
+	k2, err := aes.NewKeyring(json.Codec, map[uint32][]byte{1: key1, 2: key2}, 2, aes.BindLocation())
+	require.NoError(t, err)
+	require.NoError(t, ReEncrypt(db.WithCodec(k2), 2))
+
+	k3, err := aes.NewKeyring(json.Codec, map[uint32][]byte{2: key2}, 2, aes.BindLocation())
+	require.NoError(t, err)
+
+	var users []User
+	require.NoError(t, db.From("a").WithCodec(k3).Find("Name", "John", &users))
+	require.Len(t, users, 3)
+}
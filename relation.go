@@ -0,0 +1,455 @@
+package storm
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/asdine/storm/v3/index"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Actions applied to the records referencing a deleted record.
+const (
+	onDeleteRestrict = "restrict"
+	onDeleteCascade  = "cascade"
+	onDeleteSetNull  = "setnull"
+)
+
+// metaRefs is the metadata bucket listing the fields referencing the records of a bucket.
+const metaRefs = "refs"
+
+// Metadata of a field referencing the records of a bucket, used when its type is unknown.
+const (
+	refOnDelete = "on_delete"
+	refIndex    = "index"
+	refKind     = "kind"
+)
+
+func isOnDeleteAction(action string) bool {
+	return action == onDeleteRestrict || action == onDeleteCascade || action == onDeleteSetNull
+}
+
+// A relation is a field of a type referencing the records of another bucket.
+type relation struct {
+	// Path of the referenced bucket from the root of the database
+	target []string
+
+	kind  reflect.Type
+	field string
+}
+
+// relations between the types used with a database.
+type relations struct {
+	mu   sync.RWMutex
+	list []*relation
+}
+
+func (r *relations) add(rel *relation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.find(rel.target, rel.kind.Name(), rel.field) == nil {
+		r.list = append(r.list, rel)
+	}
+}
+
+func (r *relations) get(target []string, kindName, field string) *relation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.find(target, kindName, field)
+}
+
+func (r *relations) find(target []string, kindName, field string) *relation {
+	for _, rel := range r.list {
+		if rel.kind.Name() == kindName && rel.field == field && equalPaths(rel.target, target) {
+			return rel
+		}
+	}
+
+	return nil
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// path returns the path of the given bucket from the root of the database.
+func (n *node) path(bucketName string) []string {
+	return append(n.rootBucket[:len(n.rootBucket):len(n.rootBucket)], bucketName)
+}
+
+// registerRelations registers the references declared by the given type.
+func (n *node) registerRelations(cfg *structConfig) {
+	for name, f := range cfg.Fields {
+		if f.Ref != "" {
+			n.s.relations.add(&relation{target: n.path(f.Ref), kind: cfg.Type, field: name})
+		}
+	}
+}
+
+// checkRefs returns ErrRefNotFound if the record references records that don't exist.
+// The referenced buckets keep track of the fields referencing them.
+// Records marked as deleted or expired can be referenced: they are still stored, can be restored,
+// and the delete actions of their references apply when they are purged. Their type is unknown here anyway.
+// Records being marked as deleted aren't checked, their references may have been deleted in cascade.
+func (n *node) checkRefs(tx *bolt.Tx, cfg *structConfig) error {
+	n.registerRelations(cfg)
+
+	var deleted bool
+	if name := deletedField(cfg.Type); name != "" {
+		deleted, _ = deletedAt(*cfg.Fields[name].Value)
+	}
+
+	for name, f := range cfg.Fields {
+		if f.Ref == "" || f.IsZero {
+			continue
+		}
+
+		target := n.GetBucket(tx, f.Ref)
+		if target == nil {
+			if deleted {
+				continue
+			}
+			return ErrRefNotFound
+		}
+
+		id, err := toBytes(f.Value.Interface(), n.codec)
+		if err != nil {
+			return err
+		}
+
+		if target.Get(id) == nil {
+			if deleted {
+				continue
+			}
+			return ErrRefNotFound
+		}
+
+		meta, err := newMeta(target, n)
+		if err != nil {
+			return err
+		}
+
+		refs, err := meta.bucket.CreateBucketIfNotExists([]byte(metaRefs))
+		if err != nil {
+			return err
+		}
+
+		b, err := refs.CreateBucketIfNotExists([]byte(cfg.Name + "." + name))
+		if err != nil {
+			return err
+		}
+
+		err = saveRef(b, f)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveRef stores how to find the records referencing a bucket by the given field,
+// and what to do with them when a referenced record is deleted.
+func saveRef(b *bolt.Bucket, f *fieldConfig) error {
+	err := putMeta(b, refOnDelete, f.OnDelete)
+	if err != nil {
+		return err
+	}
+
+	err = putMeta(b, refIndex, f.Index)
+	if err != nil {
+		return err
+	}
+
+	return putMeta(b, refKind, kindName(f.indexType()))
+}
+
+// referrers are the records referencing a record by the same field.
+type referrers struct {
+	// Bucket and field of the referencing records
+	bucket   string
+	field    string
+	onDelete string
+
+	// Config of their type, nil if it is unknown
+	cfg *structConfig
+
+	// true if the records are marked as deleted instead of being removed
+	softDelete bool
+
+	ids [][]byte
+}
+
+// referrers returns the records referencing the given record of the given bucket.
+// It returns ErrReferenced if one of them references it by a field restricting its deletion.
+// If the type of the record is nil, its ID is decoded from its key.
+// The referencing types that were not saved or initialized since the database was opened
+// are found with the metadata of the bucket.
+func (n *node) referrers(tx *bolt.Tx, bucketName string, kind reflect.Type, id, raw []byte) ([]referrers, error) {
+	bucket := n.GetBucket(tx, bucketName)
+	if bucket == nil {
+		return nil, nil
+	}
+
+	m := bucket.Bucket([]byte(metadataBucket))
+	if m == nil {
+		return nil, nil
+	}
+
+	refs := m.Bucket([]byte(metaRefs))
+	if refs == nil {
+		return nil, nil
+	}
+
+	var keys [][]byte
+	refs.ForEach(func(k, v []byte) error {
+		keys = append(keys, append([]byte(nil), k...))
+		return nil
+	})
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	value, err := n.idValue(m, bucketName, kind, id, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []referrers
+	for _, key := range keys {
+		parts := strings.SplitN(string(key), ".", 2)
+
+		var r referrers
+		if rel := n.s.relations.get(n.path(bucketName), parts[0], parts[1]); rel != nil {
+			r, err = n.referrersOf(tx, rel, value)
+		} else {
+			r, err = n.storedReferrersOf(tx, parts[0], parts[1], refs.Bucket(key), value)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(r.ids) == 0 {
+			continue
+		}
+
+		switch {
+		case r.onDelete == "" || r.onDelete == onDeleteRestrict:
+			return nil, ErrReferenced
+		case r.cfg == nil && (r.onDelete == onDeleteSetNull || r.softDelete):
+			// the referencing records can't be modified without their type
+			return nil, ErrUnknownRef
+		}
+
+		list = append(list, r)
+	}
+
+	return list, nil
+}
+
+// idValue returns the ID of the given record, decoded with its type if it is not nil,
+// or from its key with the kind stored in the given metadata.
+func (n *node) idValue(m *bolt.Bucket, bucketName string, kind reflect.Type, id, raw []byte) (interface{}, error) {
+	if kind == nil {
+		value, ok := idFromBytes(id, string(m.Get([]byte(metaIDKind))))
+		if !ok {
+			return nil, ErrUnknownRef
+		}
+		return value, nil
+	}
+
+	record := reflect.New(kind)
+	err := n.codecAt(bucketName, id).Unmarshal(raw, record.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := extract(&record)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.ID.Value.Interface(), nil
+}
+
+// referrersOf returns the records referencing the given ID by the field of the given relation.
+func (n *node) referrersOf(tx *bolt.Tx, rel *relation, id interface{}) (referrers, error) {
+	kind := reflect.New(rel.kind)
+	cfg, err := extract(&kind)
+	if err != nil {
+		return referrers{}, err
+	}
+
+	field := cfg.Fields[rel.field]
+	r := referrers{
+		bucket:     cfg.Name,
+		field:      rel.field,
+		onDelete:   field.OnDelete,
+		cfg:        cfg,
+		softDelete: deletedField(cfg.Type) != "",
+	}
+
+	value, err := toFieldIndexBytes(id, field, n.codec)
+	if err != nil {
+		return r, err
+	}
+
+	return r, n.findReferrers(tx, &r, field.Index, value)
+}
+
+// storedReferrersOf returns the records of the given bucket referencing the given ID
+// by the given field, described by the given metadata.
+func (n *node) storedReferrersOf(tx *bolt.Tx, bucketName, field string, m *bolt.Bucket, id interface{}) (referrers, error) {
+	r := referrers{
+		bucket:   bucketName,
+		field:    field,
+		onDelete: string(m.Get([]byte(refOnDelete))),
+	}
+
+	typ, ok := basicTypes[string(m.Get([]byte(refKind)))]
+	if !ok {
+		return r, ErrUnknownRef
+	}
+
+	if b := n.GetBucket(tx, bucketName); b != nil {
+		if bm := b.Bucket([]byte(metadataBucket)); bm != nil {
+			r.softDelete = len(bm.Get([]byte(metaDeleted))) > 0
+		}
+	}
+
+	if v, ok := convertIndexValue(id, typ); ok {
+		id = v.Interface()
+	}
+
+	value, err := toIndexBytes(id, n.codec)
+	if err != nil {
+		return r, err
+	}
+
+	return r, n.findReferrers(tx, &r, string(m.Get([]byte(refIndex))), value)
+}
+
+// findReferrers adds to the given referrers the IDs found in their index for the given value.
+func (n *node) findReferrers(tx *bolt.Tx, r *referrers, indexKind string, value []byte) error {
+	bucket := n.GetBucket(tx, r.bucket)
+	if bucket == nil {
+		return nil
+	}
+
+	idx, err := getIndex(bucket, indexKind, r.field)
+	if err != nil {
+		return err
+	}
+
+	list, err := idx.All(value, nil)
+	if err != nil && err != index.ErrNotFound {
+		return err
+	}
+
+	// the index is modified when the records are deleted or updated
+	for _, id := range list {
+		r.ids = append(r.ids, append([]byte(nil), id...))
+	}
+
+	return nil
+}
+
+// deleteRefs applies the delete action of their field to the given records.
+// It is called once the record they reference is removed from its bucket and indexes,
+// so that cascades stop if they reach it again.
+func (n *node) deleteRefs(tx *bolt.Tx, list []referrers) error {
+	for _, r := range list {
+		bucket := n.GetBucket(tx, r.bucket)
+
+		for _, id := range r.ids {
+			// the record may have been deleted by another cascade
+			raw := bucket.Get(id)
+			if raw == nil {
+				continue
+			}
+
+			var err error
+			switch {
+			case r.onDelete == onDeleteCascade && r.cfg != nil:
+				err = n.cascade(tx, r.cfg, id, raw)
+			case r.onDelete == onDeleteCascade:
+				err = n.deleteRecord(tx, r.bucket, id)
+			default:
+				err = n.clearRef(tx, r.cfg, id, raw, r.field)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// cascade deletes a record referencing a deleted record like with DeleteStruct:
+// its delete hooks are called, and it is marked as deleted if its type has a deleted field.
+func (n *node) cascade(tx *bolt.Tx, cfg *structConfig, id, raw []byte) error {
+	record := reflect.New(cfg.Type)
+	err := n.codecAt(cfg.Name, id).Unmarshal(raw, record.Interface())
+	if err != nil {
+		return err
+	}
+
+	// records already marked as deleted stay referenced until they are purged
+	name := deletedField(cfg.Type)
+	if isDeleted(record, name) {
+		return nil
+	}
+
+	data := record.Interface()
+	err = n.beforeDelete(tx, data)
+	if err != nil {
+		return err
+	}
+
+	if name != "" {
+		err = n.markDeleted(tx, record)
+	} else {
+		err = n.deleteStruct(tx, cfg, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	return n.afterDelete(tx, data)
+}
+
+// clearRef sets the given reference field of a record to its zero value.
+// The record is updated like with Update: its hooks are called and its version and update times are set.
+func (n *node) clearRef(tx *bolt.Tx, cfg *structConfig, id, raw []byte, field string) error {
+	record := reflect.New(cfg.Type)
+	err := n.codecAt(cfg.Name, id).Unmarshal(raw, record.Interface())
+	if err != nil {
+		return err
+	}
+
+	v := record.Elem().FieldByName(field)
+	v.Set(reflect.Zero(v.Type()))
+
+	rcfg, err := extract(&record)
+	if err != nil {
+		return err
+	}
+	rcfg.Fields[field].ForceUpdate = true
+
+	return n.saveWithHooks(tx, rcfg, record.Interface(), true)
+}
@@ -0,0 +1,190 @@
+package storm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+)
+
+type Author struct {
+	ID   int `storm:"increment"`
+	Name string
+}
+
+type Book struct {
+	ID       int `storm:"increment"`
+	AuthorID int `storm:"ref=Author:cascade"`
+	EditorID int `storm:"ref=Author:setnull"`
+}
+
+type Review struct {
+	ID     int `storm:"increment"`
+	BookID int `storm:"ref=Book"`
+}
+
+type Chapter struct {
+	ID        int `storm:"increment"`
+	AuthorID  int `storm:"ref=Author:cascade"`
+	Locked    bool
+	DeletedAt *time.Time `storm:"deleted"`
+}
+
+func (c *Chapter) BeforeDelete(n Node) error {
+	if c.Locked {
+		return errors.New("locked")
+	}
+	return nil
+}
+
+func (c *Chapter) AfterDelete(n Node) error {
+	return n.Set("deleted_chapters", c.ID, true)
+}
+
+func TestRelations(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	require.Equal(t, ErrRefNotFound, db.Save(&Book{AuthorID: 1}))
+
+	require.NoError(t, db.Save(&Author{Name: "a"}))
+	require.NoError(t, db.Save(&Author{Name: "b"}))
+	require.NoError(t, db.Save(&Book{AuthorID: 1, EditorID: 2}))
+	require.NoError(t, db.Save(&Book{AuthorID: 1}))
+	require.NoError(t, db.Save(&Book{AuthorID: 2, EditorID: 1}))
+	require.Equal(t, ErrRefNotFound, db.UpdateField(&Book{ID: 1}, "AuthorID", 3))
+
+	// references are indexed
+	var books []Book
+	require.NoError(t, db.Find("AuthorID", 1, &books))
+	require.Len(t, books, 2)
+
+	require.NoError(t, db.Save(&Review{BookID: 3}))
+	require.Equal(t, ErrReferenced, db.DeleteStruct(&Book{ID: 3}))
+	require.Equal(t, ErrReferenced, db.DeleteStruct(&Author{ID: 2}))
+
+	require.NoError(t, db.DeleteStruct(&Author{ID: 1}))
+	require.NoError(t, db.All(&books))
+	require.Len(t, books, 1)
+	require.Equal(t, 3, books[0].ID)
+	require.Equal(t, 0, books[0].EditorID)
+	require.Equal(t, ErrNotFound, db.One("EditorID", 1, &books[0]))
+
+	require.NoError(t, db.DeleteStruct(&Review{ID: 1}))
+	require.NoError(t, db.Select(q.Eq("Name", "b")).Delete(&Author{}))
+
+	count, err := db.Count(&Book{})
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+}
+
+func TestRelationsSetNullUpdate(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, cleanup := createDB(t, Clock(func() time.Time {
+		return now
+	}))
+	defer cleanup()
+
+	type Manuscript struct {
+		ID        int       `storm:"increment"`
+		EditorID  int       `storm:"ref=Author:setnull"`
+		Version   int       `storm:"version"`
+		UpdatedAt time.Time `storm:"updated"`
+	}
+
+	require.NoError(t, db.Save(&Author{Name: "a"}))
+	m := Manuscript{EditorID: 1}
+	require.NoError(t, db.Save(&m))
+
+	// the referencing records are updated like with Update
+	now = now.Add(time.Hour)
+	require.NoError(t, db.DeleteStruct(&Author{ID: 1}))
+	require.NoError(t, db.One("ID", 1, &m))
+	require.Equal(t, 0, m.EditorID)
+	require.Equal(t, 2, m.Version)
+	require.Equal(t, now, m.UpdatedAt)
+
+	require.Equal(t, ErrVersionConflict, db.Save(&Manuscript{ID: 1, Version: 1}))
+}
+
+func TestRelationsCascadeSoftDelete(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, cleanup := createDB(t, Clock(func() time.Time {
+		return now
+	}))
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Author{Name: "a"}))
+	require.NoError(t, db.Save(&Author{Name: "b"}))
+	require.NoError(t, db.Save(&Chapter{AuthorID: 1}))
+	require.NoError(t, db.Save(&Chapter{AuthorID: 1}))
+	require.NoError(t, db.Save(&Chapter{AuthorID: 2, Locked: true}))
+
+	// the referencing records are deleted like with DeleteStruct
+	require.NoError(t, db.DeleteStruct(&Author{ID: 1}))
+
+	var chapters []Chapter
+	require.NoError(t, db.All(&chapters))
+	require.Len(t, chapters, 1)
+	require.NoError(t, db.IncludeDeleted().Find("AuthorID", 1, &chapters))
+	require.Len(t, chapters, 2)
+	require.Equal(t, now, *chapters[0].DeletedAt)
+
+	var deleted bool
+	require.NoError(t, db.Get("deleted_chapters", 2, &deleted))
+	require.True(t, deleted)
+
+	// records referencing a deleted record can't be restored
+	require.Equal(t, ErrRefNotFound, db.Restore(&Chapter{ID: 1}))
+
+	require.EqualError(t, db.DeleteStruct(&Author{ID: 2}), "locked")
+	require.NoError(t, db.One("ID", 2, &Author{}))
+
+	// records are only marked as deleted with their type
+	require.NoError(t, db.Save(&Chapter{AuthorID: 2}))
+	db.relations.list = nil
+	require.Equal(t, ErrUnknownRef, db.Select(q.Eq("Name", "b")).Delete(&Author{}))
+
+	require.NoError(t, db.Purge(&Chapter{}, 0))
+	require.NoError(t, db.IncludeDeleted().All(&chapters))
+	require.Len(t, chapters, 2)
+}
+
+func TestRelationsUnknownRef(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Author{Name: "a"}))
+	require.NoError(t, db.Save(&Author{Name: "b"}))
+	require.NoError(t, db.Save(&Book{AuthorID: 1}))
+	require.NoError(t, db.Save(&Book{AuthorID: 1, EditorID: 2}))
+	require.NoError(t, db.Save(&Review{BookID: 1}))
+
+	// the referencing types are not known after reopening the database,
+	// their references are found with the metadata of the buckets
+	db.relations.list = nil
+	require.Equal(t, ErrReferenced, db.DeleteStruct(&Author{ID: 1}))
+	require.NoError(t, db.DeleteStruct(&Review{ID: 1}))
+	require.NoError(t, db.DeleteStruct(&Author{ID: 1}))
+
+	var books []Book
+	require.NoError(t, db.All(&books))
+	require.Empty(t, books)
+	require.Equal(t, ErrNotFound, db.Find("AuthorID", 1, &books))
+
+	// setting a reference to zero requires the type
+	require.NoError(t, db.Save(&Book{AuthorID: 2, EditorID: 2}))
+	db.relations.list = nil
+	require.Equal(t, ErrUnknownRef, db.DeleteStruct(&Author{ID: 2}))
+
+	require.NoError(t, db.Init(&Book{}))
+	require.NoError(t, db.DeleteStruct(&Author{ID: 2}))
+
+	type BadRef struct {
+		ID       int
+		AuthorID int `storm:"ref=Author:ignore"`
+	}
+	require.Equal(t, ErrUnknownTag, db.Save(&BadRef{ID: 1}))
+}
@@ -4,7 +4,9 @@ import (
 	"reflect"
 	"sort"
 	"time"
+	"github.com/asdine/storm/v3/codec"
 	"github.com/asdine/storm/v3/index"
+	"github.com/asdine/storm/v3/internal"
 	"github.com/asdine/storm/v3/q"
 	bolt "go.etcd.io/bbolt"
 )
@@ -17,7 +19,7 @@ type item struct {
 }
 
 func newSorter(n Node, snk sink) *sorter {
-	return &sorter{
+	s := sorter{
 		node:  n,
 		sink:  snk,
 		skip:  0,
@@ -26,6 +28,18 @@ func newSorter(n Node, snk sink) *sorter {
 		err:   make(chan error),
 		done:  make(chan struct{}),
 	}
+
+	if rsink, ok := snk.(reflectSink); ok {
+		typ := reflect.Indirect(rsink.elem()).Type()
+		if psink, ok := snk.(*projectSink); ok {
+			typ = psink.kind
+		}
+		s.deleted = nodeOf(n).filteredDeleted(typ)
+		s.expires = expiryField(typ)
+		s.now = nodeOf(n).s.clock()
+	}
+
+	return &s
 }
 
 type sorter struct {
@@ -38,6 +52,13 @@ type sorter struct {
 	reverse bool
 	err     chan error
 	done    chan struct{}
+
+	// deleted field of the records filtered out, if any
+	deleted string
+
+	// expiry field of the records filtered out once expired, if any
+	expires string
+	now     time.Time
 }
 
 func (s *sorter) filter(tree q.Matcher, bucket *bolt.Bucket, k, v []byte) (bool, error) {
@@ -52,11 +73,22 @@ func (s *sorter) filter(tree q.Matcher, bucket *bolt.Bucket, k, v []byte) (bool,
 	}
 
 	newElem := rsink.elem()
-	if err := s.node.Codec().Unmarshal(v, newElem.Interface()); err != nil {
+	c := nodeOf(s.node).codecAt(s.sink.bucketName(), k)
+	var err error
+	if dsink, ok := s.sink.(decoderSink); ok {
+		err = dsink.decode(c, v, newElem.Interface())
+	} else {
+		err = c.Unmarshal(v, newElem.Interface())
+	}
+	if err != nil {
 		return false, err
 	}
 	itm.value = &newElem
 
+	if isDeleted(newElem, s.deleted) || isExpired(newElem, s.expires, s.now) {
+		return false, nil
+	}
+
 	if tree != nil {
 		ok, err := tree.Match(newElem.Interface())
 		if err != nil {
@@ -101,6 +133,10 @@ func (s *sorter) add(itm *item) (stop bool, err error) {
 }
 
 func (s *sorter) compareValue(left reflect.Value, right reflect.Value) int {
+	return compareValue(left, right, s.node.Codec())
+}
+
+func compareValue(left reflect.Value, right reflect.Value, c codec.MarshalUnmarshaler) int {
 	if !left.IsValid() || !right.IsValid() {
 		if left.IsValid() {
 			return 1
@@ -154,11 +190,11 @@ func (s *sorter) compareValue(left reflect.Value, right reflect.Value) int {
 			}
 		}
 	default:
-		rawLeft, err := toBytes(left.Interface(), s.node.Codec())
+		rawLeft, err := toBytes(left.Interface(), c)
 		if err != nil {
 			return -1
 		}
-		rawRight, err := toBytes(right.Interface(), s.node.Codec())
+		rawRight, err := toBytes(right.Interface(), c)
 		if err != nil {
 			return 1
 		}
@@ -177,12 +213,12 @@ func (s *sorter) compareValue(left reflect.Value, right reflect.Value) int {
 
 func (s *sorter) less(leftElem reflect.Value, rightElem reflect.Value) bool {
 	for _, orderBy := range s.orderBy {
-		leftField := reflect.Indirect(leftElem).FieldByName(orderBy)
+		leftField := internal.FieldByPath(reflect.Indirect(leftElem), orderBy)
 		if !leftField.IsValid() {
 			s.err <- ErrNotFound
 			return false
 		}
-		rightField := reflect.Indirect(rightElem).FieldByName(orderBy)
+		rightField := internal.FieldByPath(reflect.Indirect(rightElem), orderBy)
 		if !rightField.IsValid() {
 			s.err <- ErrNotFound
 			return false
@@ -302,6 +338,17 @@ type reflectSink interface {
 	elem() reflect.Value
 }
 
+// decoderSink is implemented by sinks decoding the records themselves.
+// They are given the codec bound to the location of the record.
+type decoderSink interface {
+	decode(codec.MarshalUnmarshaler, []byte, interface{}) error
+}
+
+// preloadSink is implemented by sinks returning records whose fields can be preloaded.
+type preloadSink interface {
+	target() reflect.Value
+}
+
 type sliceSink interface {
 	slice() reflect.Value
 	setSlice(reflect.Value)
@@ -344,6 +391,10 @@ type listSink struct {
 	name     string
 	isPtr    bool
 	idx      int
+
+	// transaction of the added records, and number of records whose AfterLoad hook was called
+	tx     *bolt.Tx
+	loaded int
 }
 
 func (l *listSink) slice() reflect.Value {
@@ -360,7 +411,11 @@ func (l *listSink) reset() {
 
 func (l *listSink) elem() reflect.Value {
 	if l.results.IsValid() && l.idx < l.results.Len() {
-		return l.results.Index(l.idx).Addr()
+		// the slot may hold a record skipped or filtered out by the sorter,
+		// codecs omitting zero values would leave its fields in the next record
+		slot := l.results.Index(l.idx)
+		slot.Set(reflect.Zero(slot.Type()))
+		return slot.Addr()
 	}
 	return reflect.New(l.elemType)
 }
@@ -370,6 +425,7 @@ func (l *listSink) bucketName() string {
 }
 
 func (l *listSink) add(i *item) error {
+	l.tx = i.bucket.Tx()
 	if l.idx == l.results.Len() {
 		if l.isPtr {
 			l.results = reflect.Append(l.results, *i.value)
@@ -384,18 +440,41 @@ func (l *listSink) add(i *item) error {
 }
 
 func (l *listSink) flush() error {
-	if l.results.IsValid() && l.results.Len() > 0 {
-		reflect.Indirect(l.ref).Set(l.results)
-		return nil
+	// the results are allocated in advance when reading an index, some may have been filtered out
+	if l.results.IsValid() && l.idx < l.results.Len() {
+		l.results = l.results.Slice(0, l.idx)
 	}
 
-	return ErrNotFound
+	if !l.results.IsValid() || l.results.Len() == 0 {
+		return ErrNotFound
+	}
+
+	// the hooks are called once the records are skipped, limited and sorted,
+	// flush can be called again once the transaction is closed
+	for ; l.loaded < l.results.Len(); l.loaded++ {
+		record := l.results.Index(l.loaded)
+		if !l.isPtr {
+			record = record.Addr()
+		}
+
+		err := nodeOf(l.node).afterLoad(l.tx, record.Interface())
+		if err != nil {
+			return err
+		}
+	}
+
+	reflect.Indirect(l.ref).Set(l.results)
+	return nil
 }
 
 func (l *listSink) readOnly() bool {
 	return true
 }
 
+func (l *listSink) target() reflect.Value {
+	return l.ref
+}
+
 func newFirstSink(node Node, to interface{}) (*firstSink, error) {
 	ref := reflect.ValueOf(to)
 
@@ -424,6 +503,11 @@ func (f *firstSink) bucketName() string {
 }
 
 func (f *firstSink) add(i *item) error {
+	err := nodeOf(f.node).afterLoad(i.bucket.Tx(), i.value.Interface())
+	if err != nil {
+		return err
+	}
+
 	reflect.Indirect(f.ref).Set(i.value.Elem())
 	f.found = true
 	return nil
@@ -441,6 +525,10 @@ func (f *firstSink) readOnly() bool {
 	return true
 }
 
+func (f *firstSink) target() reflect.Value {
+	return f.ref
+}
+
 func newDeleteSink(node Node, kind interface{}) (*deleteSink, error) {
 	ref := reflect.ValueOf(kind)
 
@@ -469,11 +557,32 @@ func (d *deleteSink) bucketName() string {
 }
 
 func (d *deleteSink) add(i *item) error {
+	n := nodeOf(d.node)
+	err := n.beforeDelete(i.bucket.Tx(), i.value.Interface())
+	if err != nil {
+		return err
+	}
+
 	info, err := extract(&d.ref)
 	if err != nil {
 		return err
 	}
 
+	if deletedField(info.Type) != "" {
+		d.removed++
+		err = n.markDeleted(i.bucket.Tx(), *i.value)
+		if err != nil {
+			return err
+		}
+
+		return n.afterDelete(i.bucket.Tx(), i.value.Interface())
+	}
+
+	refs, err := n.referrers(i.bucket.Tx(), info.Name, info.Type, i.k, i.v)
+	if err != nil {
+		return err
+	}
+
 	for fieldName, fieldCfg := range info.Fields {
 		if fieldCfg.Index == "" {
 			continue
@@ -492,8 +601,31 @@ func (d *deleteSink) add(i *item) error {
 		}
 	}
 
+	for _, ci := range info.CompoundIndexes {
+		idx, err := getIndex(i.bucket, ci.Kind, ci.Name)
+		if err != nil {
+			return err
+		}
+
+		err = idx.RemoveID(i.k)
+		if err != nil {
+			return err
+		}
+	}
+
 	d.removed++
-	return i.bucket.Delete(i.k)
+	n.emit(i.bucket.Tx(), d.bucketName(), Event{Type: EventDelete, ID: i.k, Old: i.v})
+	err = i.bucket.Delete(i.k)
+	if err != nil {
+		return err
+	}
+
+	err = n.deleteRefs(i.bucket.Tx(), refs)
+	if err != nil {
+		return err
+	}
+
+	return n.afterDelete(i.bucket.Tx(), i.value.Interface())
 }
 
 func (d *deleteSink) flush() error {
@@ -548,6 +680,212 @@ func (c *countSink) readOnly() bool {
 	return true
 }
 
+func newAggregateSink(node Node, kind interface{}, field, groupBy string, newAggregate func() aggregate) (*aggregateSink, error) {
+	ref := reflect.ValueOf(kind)
+
+	if !ref.IsValid() || ref.Kind() != reflect.Ptr || ref.Elem().Kind() != reflect.Struct {
+		return nil, ErrStructPtrNeeded
+	}
+
+	return &aggregateSink{
+		node:         node,
+		ref:          ref,
+		field:        field,
+		groupBy:      groupBy,
+		newAggregate: newAggregate,
+		groups:       make(map[interface{}]aggregate),
+	}, nil
+}
+
+// aggregateSink computes an aggregate of a field of the records,
+// for each distinct value of the groupBy field if set.
+type aggregateSink struct {
+	node         Node
+	ref          reflect.Value
+	field        string
+	groupBy      string
+	newAggregate func() aggregate
+	groups       map[interface{}]aggregate
+}
+
+func (a *aggregateSink) elem() reflect.Value {
+	return reflect.New(reflect.Indirect(a.ref).Type())
+}
+
+func (a *aggregateSink) bucketName() string {
+	return reflect.Indirect(a.ref).Type().Name()
+}
+
+func (a *aggregateSink) add(i *item) error {
+	record := reflect.Indirect(*i.value)
+
+	var key interface{}
+	if a.groupBy != "" {
+		g := internal.FieldByPath(record, a.groupBy)
+		if !g.IsValid() {
+			return q.ErrUnknownField
+		}
+		if !isComparable(g) {
+			return ErrIncompatibleValue
+		}
+		key = g.Interface()
+	}
+
+	var v reflect.Value
+	if a.field != "" {
+		v = internal.FieldByPath(record, a.field)
+		if !v.IsValid() {
+			return q.ErrUnknownField
+		}
+	}
+
+	agg, ok := a.groups[key]
+	if !ok {
+		agg = a.newAggregate()
+		a.groups[key] = agg
+	}
+
+	return agg.add(v)
+}
+
+func (a *aggregateSink) flush() error {
+	return nil
+}
+
+func (a *aggregateSink) readOnly() bool {
+	return true
+}
+
+// result returns the aggregate of the records when they are not grouped.
+func (a *aggregateSink) result() aggregate {
+	agg, ok := a.groups[nil]
+	if !ok {
+		return a.newAggregate()
+	}
+	return agg
+}
+
+// aggregate accumulates the values of a field.
+type aggregate interface {
+	add(v reflect.Value) error
+}
+
+type countAggregate struct {
+	count int
+}
+
+func (c *countAggregate) add(v reflect.Value) error {
+	c.count++
+	return nil
+}
+
+type sumAggregate struct {
+	sum   float64
+	count int
+}
+
+func (s *sumAggregate) add(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s.sum += float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s.sum += float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		s.sum += v.Float()
+	default:
+		return ErrIncompatibleValue
+	}
+
+	s.count++
+	return nil
+}
+
+func (s *sumAggregate) avg() (float64, error) {
+	if s.count == 0 {
+		return 0, ErrNotFound
+	}
+
+	return s.sum / float64(s.count), nil
+}
+
+type boundAggregate struct {
+	codec codec.MarshalUnmarshaler
+	max   bool
+	value reflect.Value
+}
+
+func (b *boundAggregate) add(v reflect.Value) error {
+	if !b.value.IsValid() {
+		b.value = v
+		return nil
+	}
+
+	cmp := compareValue(v, b.value, b.codec)
+	if (b.max && cmp > 0) || (!b.max && cmp < 0) {
+		b.value = v
+	}
+
+	return nil
+}
+
+func (b *boundAggregate) result() (interface{}, error) {
+	if !b.value.IsValid() {
+		return nil, ErrNotFound
+	}
+
+	return b.value.Interface(), nil
+}
+
+type distinctAggregate struct {
+	seen   map[interface{}]struct{}
+	values []interface{}
+}
+
+func (d *distinctAggregate) add(v reflect.Value) error {
+	if !isComparable(v) {
+		return ErrIncompatibleValue
+	}
+
+	if d.seen == nil {
+		d.seen = make(map[interface{}]struct{})
+	}
+
+	value := v.Interface()
+	if _, ok := d.seen[value]; !ok {
+		d.seen[value] = struct{}{}
+		d.values = append(d.values, value)
+	}
+
+	return nil
+}
+
+// isComparable reports whether the given value can be used as a map key without panicking.
+// Unlike Type().Comparable, it checks the dynamic values held by interfaces.
+func isComparable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Interface:
+		return v.IsNil() || isComparable(v.Elem())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !isComparable(v.Index(i)) {
+				return false
+			}
+		}
+		return v.Type().Comparable()
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isComparable(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return v.Type().Comparable()
+}
+
 func newRawSink() *rawSink {
 	return &rawSink{}
 }
@@ -582,7 +920,7 @@ func (r *rawSink) readOnly() bool {
 	return true
 }
 
-func newEachSink(to interface{}) (*eachSink, error) {
+func newEachSink(node Node, to interface{}) (*eachSink, error) {
 	ref := reflect.ValueOf(to)
 
 	if !ref.IsValid() || ref.Kind() != reflect.Ptr || ref.Elem().Kind() != reflect.Struct {
@@ -590,11 +928,13 @@ func newEachSink(to interface{}) (*eachSink, error) {
 	}
 
 	return &eachSink{
-		ref: ref,
+		node: node,
+		ref:  ref,
 	}, nil
 }
 
 type eachSink struct {
+	node   Node
 	ref    reflect.Value
 	execFn func(interface{}) error
 }
@@ -608,6 +948,11 @@ func (e *eachSink) bucketName() string {
 }
 
 func (e *eachSink) add(i *item) error {
+	err := nodeOf(e.node).afterLoad(i.bucket.Tx(), i.value.Interface())
+	if err != nil {
+		return err
+	}
+
 	return e.execFn(i.value.Interface())
 }
 
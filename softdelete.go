@@ -0,0 +1,246 @@
+package storm
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3/index"
+	bolt "go.etcd.io/bbolt"
+)
+
+// isDeletedType reports whether a field of the given type can be tagged with deleted.
+func isDeletedType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Bool || typ == timeType || typ == reflect.PtrTo(timeType)
+}
+
+// deletedField returns the name of the field of the given struct type tagged with deleted, if any.
+func deletedField(typ reflect.Type) string {
+	return taggedField(typ, tagDeleted)
+}
+
+// taggedField returns the name of the first field of the given struct type tagged with one of the given tags, if any.
+// Nested fields are ignored.
+func taggedField(typ reflect.Type, tags ...string) string {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		for _, tag := range strings.Split(f.Tag.Get("storm"), ",") {
+			for _, t := range tags {
+				if tag == t {
+					return f.Name
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// deletedAt reports whether the given deleted field marks its record as deleted,
+// and since when if it is a time.
+func deletedAt(v reflect.Value) (bool, time.Time) {
+	switch {
+	case v.Kind() == reflect.Bool:
+		return v.Bool(), time.Time{}
+	case v.Kind() == reflect.Ptr:
+		if v.IsNil() {
+			return false, time.Time{}
+		}
+		return true, v.Elem().Interface().(time.Time)
+	default:
+		t := v.Interface().(time.Time)
+		return !t.IsZero(), t
+	}
+}
+
+// isDeleted reports whether the given record is marked as deleted by the given field.
+// It always returns false if field is empty.
+func isDeleted(record reflect.Value, field string) bool {
+	if field == "" {
+		return false
+	}
+
+	for record.Kind() == reflect.Ptr {
+		record = record.Elem()
+	}
+
+	deleted, _ := deletedAt(record.FieldByName(field))
+	return deleted
+}
+
+// filteredDeleted returns the deleted field of the records of the given type
+// if the node must filter out deleted records, an empty string otherwise.
+func (n *node) filteredDeleted(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if n.includeDeleted || typ.Kind() != reflect.Struct {
+		return ""
+	}
+
+	return deletedField(typ)
+}
+
+// indexOptions returns the options used to read the IDs passed to the sorter from an index.
+// When deleted or expired records are filtered out, the sorter skips and limits the records itself.
+func (s *sorter) indexOptions(opts *index.Options) *index.Options {
+	if s.deleted == "" && s.expires == "" {
+		return opts
+	}
+
+	o := *opts
+	s.skip, s.limit = o.Skip, o.Limit
+	o.Skip, o.Limit = 0, -1
+	return &o
+}
+
+// softDelete marks the saved version of the given record as deleted.
+func (n *node) softDelete(tx *bolt.Tx, cfg *structConfig) error {
+	saved, err := n.savedRecord(tx, cfg)
+	if err != nil {
+		return err
+	}
+
+	if !saved.IsValid() || isDeleted(saved, n.filteredDeleted(cfg.Type)) {
+		return ErrNotFound
+	}
+
+	return n.markDeleted(tx, saved.Addr())
+}
+
+// markDeleted sets the deleted field of the given record and updates it like with Update:
+// its hooks are called and its version and update times are set.
+func (n *node) markDeleted(tx *bolt.Tx, record reflect.Value) error {
+	cfg, err := extract(&record)
+	if err != nil {
+		return err
+	}
+
+	name := deletedField(cfg.Type)
+	v := record.Elem().FieldByName(name)
+	if v.Kind() == reflect.Bool {
+		v.SetBool(true)
+	} else {
+		setTime(v, n.s.clock())
+	}
+
+	f := cfg.Fields[name]
+	f.IsZero = false
+	f.ForceUpdate = true
+	return n.saveWithHooks(tx, cfg, record.Interface(), true)
+}
+
+// Restore restores a structure marked as deleted. The given structure is replaced by the restored one.
+// It is updated like with Update: its hooks are called and its version and update times are set.
+func (n *node) Restore(data interface{}) error {
+	ref := reflect.ValueOf(data)
+
+	if !ref.IsValid() || ref.Kind() != reflect.Ptr || ref.Elem().Kind() != reflect.Struct {
+		return ErrStructPtrNeeded
+	}
+
+	cfg, err := extract(&ref)
+	if err != nil {
+		return err
+	}
+
+	name := deletedField(cfg.Type)
+	if name == "" {
+		return ErrNoDeleted
+	}
+
+	return n.readWriteTx(func(tx *bolt.Tx) error {
+		saved, err := n.savedRecord(tx, cfg)
+		if err != nil {
+			return err
+		}
+
+		if !saved.IsValid() {
+			return ErrNotFound
+		}
+
+		v := saved.FieldByName(name)
+		v.Set(reflect.Zero(v.Type()))
+
+		record := saved.Addr()
+		rcfg, err := extract(&record)
+		if err != nil {
+			return err
+		}
+		rcfg.Fields[name].ForceUpdate = true
+
+		err = n.saveWithHooks(tx, rcfg, record.Interface(), true)
+		if err != nil {
+			return err
+		}
+
+		ref.Elem().Set(saved)
+		return nil
+	})
+}
+
+// Purge removes the structures of the given kind marked as deleted for longer than the given duration.
+// Structures marked as deleted by a bool field are all removed.
+func (n *node) Purge(kind interface{}, olderThan time.Duration) error {
+	ref := reflect.ValueOf(kind)
+
+	if !ref.IsValid() || ref.Kind() != reflect.Ptr || ref.Elem().Kind() != reflect.Struct {
+		return ErrStructPtrNeeded
+	}
+
+	cfg, err := extract(&ref)
+	if err != nil {
+		return err
+	}
+
+	name := deletedField(cfg.Type)
+	if name == "" {
+		return ErrNoDeleted
+	}
+
+	limit := n.s.clock().Add(-olderThan)
+
+	return n.readWriteTx(func(tx *bolt.Tx) error {
+		bucket := n.GetBucket(tx, cfg.Name)
+		if bucket == nil {
+			return nil
+		}
+
+		var ids [][]byte
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if err := n.ctxErr(); err != nil {
+				return err
+			}
+
+			if v == nil {
+				continue
+			}
+
+			record := reflect.New(cfg.Type)
+			err := n.codecAt(cfg.Name, k).Unmarshal(v, record.Interface())
+			if err != nil {
+				return err
+			}
+
+			deleted, at := deletedAt(record.Elem().FieldByName(name))
+			if deleted && !at.After(limit) {
+				ids = append(ids, append([]byte(nil), k...))
+			}
+		}
+
+		for _, id := range ids {
+			err := n.deleteStruct(tx, cfg, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,194 @@
+package storm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3/codec/gob"
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+)
+
+type Task struct {
+	ID        int        `storm:"increment"`
+	Name      string     `storm:"index"`
+	Group     string     `storm:"index"`
+	DeletedAt *time.Time `storm:"deleted"`
+}
+
+type Comment struct {
+	ID      int    `storm:"increment"`
+	Text    string `storm:"unique"`
+	Deleted bool   `storm:"deleted"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, cleanup := createDB(t, Clock(func() time.Time {
+		return now
+	}))
+	defer cleanup()
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, db.Save(&Task{Name: name, Group: "g"}))
+	}
+
+	require.NoError(t, db.DeleteStruct(&Task{ID: 1}))
+	require.Equal(t, ErrNotFound, db.DeleteStruct(&Task{ID: 1}))
+
+	var task Task
+	require.Equal(t, ErrNotFound, db.One("ID", 1, &task))
+	require.Equal(t, ErrNotFound, db.One("Name", "a", &task))
+	require.Zero(t, task)
+
+	// the first record of the group is deleted
+	require.NoError(t, db.One("Group", "g", &task))
+	require.Equal(t, "b", task.Name)
+	require.Equal(t, ErrNotFound, db.Update(&Task{ID: 1, Name: "e"}))
+
+	var tasks []Task
+	require.NoError(t, db.All(&tasks))
+	require.Len(t, tasks, 3)
+	require.NoError(t, db.Find("Group", "g", &tasks, Limit(2)))
+	require.Len(t, tasks, 2)
+	require.Equal(t, "b", tasks[0].Name)
+	require.Equal(t, "c", tasks[1].Name)
+	require.NoError(t, db.Range("Name", "a", "c", &tasks))
+	require.Len(t, tasks, 2)
+	require.Equal(t, ErrNotFound, db.Prefix("Name", "a", &tasks))
+	require.Equal(t, ErrNotFound, db.Find("Name", "a", &tasks))
+
+	var ptrs []*Task
+	require.NoError(t, db.AllByIndex("Name", &ptrs, Skip(1)))
+	require.Len(t, ptrs, 2)
+	require.Equal(t, "c", ptrs[0].Name)
+
+	count, err := db.Count(&Task{})
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	var names []map[string]interface{}
+	require.NoError(t, db.Select().Project(&Task{}, "Name").Find(&names))
+	require.Len(t, names, 3)
+
+	// deleted records are still saved
+	require.NoError(t, db.IncludeDeleted().One("Name", "a", &task))
+	require.Equal(t, now, *task.DeletedAt)
+	require.NoError(t, db.IncludeDeleted().All(&tasks))
+	require.Len(t, tasks, 4)
+	count, err = db.IncludeDeleted().Select(q.Eq("Group", "g")).Count(&Task{})
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+
+	task = Task{ID: 1}
+	require.NoError(t, db.Restore(&task))
+	require.Equal(t, "a", task.Name)
+	require.Nil(t, task.DeletedAt)
+	require.NoError(t, db.One("Name", "a", &task))
+
+	now = now.Add(time.Hour)
+	require.NoError(t, db.Select(q.In("Name", []string{"a", "b"})).Delete(&Task{}))
+	now = now.Add(time.Hour)
+	require.NoError(t, db.DeleteStruct(&Task{ID: 3}))
+	require.NoError(t, db.All(&tasks))
+	require.Len(t, tasks, 1)
+
+	require.NoError(t, db.Purge(&Task{}, 30*time.Minute))
+	require.NoError(t, db.IncludeDeleted().All(&tasks))
+	require.Len(t, tasks, 2)
+	require.Equal(t, ErrNotFound, db.Restore(&Task{ID: 1}))
+
+	require.Equal(t, ErrNoDeleted, db.Restore(&User{ID: 1}))
+	require.Equal(t, ErrNoDeleted, db.Purge(&User{}, 0))
+}
+
+func TestSoftDeleteBool(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Comment{Text: "a"}))
+	require.NoError(t, db.DeleteStruct(&Comment{ID: 1}))
+
+	var comment Comment
+	require.Equal(t, ErrNotFound, db.One("Text", "a", &comment))
+
+	// unique values are kept until the records are purged
+	require.Equal(t, ErrAlreadyExists, db.Save(&Comment{Text: "a"}))
+	require.NoError(t, db.Purge(&Comment{}, time.Hour))
+	require.NoError(t, db.Save(&Comment{Text: "a"}))
+
+	type BadDeleted struct {
+		ID      int
+		Deleted int `storm:"deleted"`
+	}
+	require.Equal(t, ErrBadDeleted, db.Save(&BadDeleted{ID: 1}))
+}
+
+type Memo struct {
+	ID      int
+	Group   string `storm:"index"`
+	Secret  string
+	Deleted bool `storm:"deleted"`
+}
+
+func TestSoftDeleteReusedSlots(t *testing.T) {
+	// gob doesn't encode zero values, the records must be decoded into zeroed values
+	db, cleanup := createDB(t, Codec(gob.Codec))
+	defer cleanup()
+
+	require.NoError(t, db.Save(&Memo{ID: 1, Group: "g", Secret: "s"}))
+	require.NoError(t, db.Save(&Memo{ID: 2, Group: "g"}))
+
+	var memos []Memo
+	require.NoError(t, db.Find("Group", "g", &memos, Skip(1)))
+	require.Equal(t, []Memo{{ID: 2, Group: "g"}}, memos)
+
+	require.NoError(t, db.DeleteStruct(&Memo{ID: 1}))
+	require.NoError(t, db.Find("Group", "g", &memos))
+	require.Equal(t, []Memo{{ID: 2, Group: "g"}}, memos)
+
+	require.NoError(t, db.AllByIndex("Group", &memos))
+	require.Equal(t, []Memo{{ID: 2, Group: "g"}}, memos)
+
+	require.NoError(t, db.Range("Group", "a", "z", &memos))
+	require.Equal(t, []Memo{{ID: 2, Group: "g"}}, memos)
+
+	var ptrs []*Memo
+	require.NoError(t, db.IncludeDeleted().Find("Group", "g", &ptrs, Skip(1)))
+	require.Equal(t, []*Memo{{ID: 2, Group: "g"}}, ptrs)
+}
+
+func TestSoftDeleteUpdate(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, cleanup := createDB(t, Clock(func() time.Time {
+		return now
+	}))
+	defer cleanup()
+
+	type Ticket struct {
+		ID        int       `storm:"increment"`
+		Version   int       `storm:"version"`
+		UpdatedAt time.Time `storm:"updated"`
+		Deleted   bool      `storm:"deleted"`
+	}
+
+	ticket := Ticket{}
+	require.NoError(t, db.Save(&ticket))
+	stale := ticket
+
+	// the records are marked as deleted and restored like with Update
+	now = now.Add(time.Hour)
+	require.NoError(t, db.DeleteStruct(&Ticket{ID: 1}))
+	require.Equal(t, ErrVersionConflict, db.Save(&stale))
+
+	var deleted Ticket
+	require.NoError(t, db.IncludeDeleted().One("ID", 1, &deleted))
+	require.Equal(t, 2, deleted.Version)
+	require.Equal(t, now, deleted.UpdatedAt)
+
+	now = now.Add(time.Hour)
+	require.NoError(t, db.Restore(&ticket))
+	require.Equal(t, 3, ticket.Version)
+	require.Equal(t, now, ticket.UpdatedAt)
+	require.False(t, ticket.Deleted)
+}
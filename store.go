@@ -3,9 +3,11 @@ package storm
 import (
 	"bytes"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/asdine/storm/v3/index"
-	"github.com/asdine/storm/v3/q"
+	"github.com/asdine/storm/v3/internal"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -32,6 +34,12 @@ type TypeStore interface {
 
 	// DeleteStruct deletes a structure from the associated bucket
 	DeleteStruct(data interface{}) error
+
+	// Restore restores a structure marked as deleted
+	Restore(data interface{}) error
+
+	// Purge removes the structures of the given kind marked as deleted for longer than the given duration
+	Purge(kind interface{}, olderThan time.Duration) error
 }
 
 // Init creates the indexes and buckets for a given structure
@@ -48,13 +56,24 @@ func (n *node) Init(data interface{}) error {
 }
 
 func (n *node) init(tx *bolt.Tx, cfg *structConfig) error {
+	n.registerRelations(cfg)
+
 	bucket, err := n.CreateBucketIfNotExists(tx, cfg.Name)
 	if err != nil {
 		return err
 	}
 
 	// save node configuration in the bucket
-	_, err = newMeta(bucket, n)
+	meta, err := newMeta(bucket, n)
+	if err != nil {
+		return err
+	}
+
+	if meta.hasLegacyIndexes() {
+		return ErrLegacyIndexes
+	}
+
+	err = meta.saveSchema(cfg)
 	if err != nil {
 		return err
 	}
@@ -68,6 +87,12 @@ func (n *node) init(tx *bolt.Tx, cfg *structConfig) error {
 			_, err = index.NewUniqueIndex(bucket, []byte(indexPrefix+fieldName))
 		case tagIdx:
 			_, err = index.NewListIndex(bucket, []byte(indexPrefix+fieldName))
+		case tagMulti:
+			_, err = index.NewMultiListIndex(bucket, []byte(indexPrefix+fieldName))
+		case tagFullText:
+			_, err = index.NewFullTextIndex(bucket, []byte(indexPrefix+fieldName))
+		case tagTrigram:
+			_, err = index.NewTrigramIndex(bucket, []byte(indexPrefix+fieldName))
 		default:
 			err = ErrIdxNotFound
 		}
@@ -77,6 +102,13 @@ func (n *node) init(tx *bolt.Tx, cfg *structConfig) error {
 		}
 	}
 
+	for _, ci := range cfg.CompoundIndexes {
+		_, err = getIndex(bucket, ci.Kind, ci.Name)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -93,45 +125,73 @@ func (n *node) ReIndex(data interface{}) error {
 	}
 
 	return n.readWriteTx(func(tx *bolt.Tx) error {
-		return n.reIndex(tx, data, cfg)
+		return n.reIndex(tx, cfg)
 	})
 }
 
-func (n *node) reIndex(tx *bolt.Tx, data interface{}, cfg *structConfig) error {
-	root := n.WithTransaction(tx)
-	nodes := root.From(cfg.Name).PrefixScan(indexPrefix)
-	bucket := root.GetBucket(tx, cfg.Name)
+func (n *node) reIndex(tx *bolt.Tx, cfg *structConfig) error {
+	bucket := n.GetBucket(tx, cfg.Name)
 	if bucket == nil {
 		return ErrNotFound
 	}
 
-	for _, node := range nodes {
-		buckets := node.Bucket()
-		name := buckets[len(buckets)-1]
-		err := bucket.DeleteBucket([]byte(name))
+	var ids, indexes [][]byte
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil {
+			ids = append(ids, append([]byte(nil), k...))
+		} else if bytes.HasPrefix(k, []byte(indexPrefix)) {
+			indexes = append(indexes, append([]byte(nil), k...))
+		}
+	}
+
+	for _, name := range indexes {
+		err := bucket.DeleteBucket(name)
 		if err != nil {
 			return err
 		}
 	}
 
-	total, err := root.Count(data)
+	meta, err := newMeta(bucket, n)
+	if err != nil {
+		return err
+	}
+
+	err = meta.bucket.Put([]byte(metaIndexEncoding), []byte(indexEncoding))
 	if err != nil {
 		return err
 	}
 
-	for i := 0; i < total; i++ {
-		err = root.Select(q.True()).Skip(i).First(data)
+	// the indexes stored in the metadata are replaced by those of the given type
+	err = meta.bucket.DeleteBucket([]byte(metaIndexes))
+	if err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := n.ctxErr(); err != nil {
+			return err
+		}
+
+		record := reflect.New(cfg.Type)
+		err = n.codecAt(cfg.Name, id).Unmarshal(bucket.Get(id), record.Interface())
 		if err != nil {
 			return err
 		}
 
-		err = root.Update(data)
+		rcfg, err := extract(&record)
+		if err != nil {
+			return err
+		}
+
+		// only the indexes are rebuilt, the records are not modified
+		err = n.saveIndexes(bucket, rcfg, record.Interface(), id, false)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return meta.saveSchema(cfg)
 }
 
 // Save a structure
@@ -154,7 +214,7 @@ func (n *node) Save(data interface{}) error {
 	}
 
 	return n.readWriteTx(func(tx *bolt.Tx) error {
-		return n.save(tx, cfg, data, false)
+		return n.saveWithHooks(tx, cfg, data, false)
 	})
 }
 
@@ -170,6 +230,15 @@ func (n *node) save(tx *bolt.Tx, cfg *structConfig, data interface{}, update boo
 		return err
 	}
 
+	if meta.hasLegacyIndexes() {
+		return ErrLegacyIndexes
+	}
+
+	err = meta.saveSchema(cfg)
+	if err != nil {
+		return err
+	}
+
 	if cfg.ID.IsZero {
 		err = meta.increment(cfg.ID)
 		if err != nil {
@@ -182,14 +251,33 @@ func (n *node) save(tx *bolt.Tx, cfg *structConfig, data interface{}, update boo
 		return err
 	}
 
-	for fieldName, fieldCfg := range cfg.Fields {
+	for _, fieldCfg := range cfg.Fields {
 		if !update && !fieldCfg.IsID && fieldCfg.Increment && fieldCfg.IsInteger && fieldCfg.IsZero {
 			err = meta.increment(fieldCfg)
 			if err != nil {
 				return err
 			}
 		}
+	}
+
+	err = n.saveIndexes(bucket, cfg, data, id, update)
+	if err != nil {
+		return err
+	}
+
+	raw, err := n.codecAt(cfg.Name, id).Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	n.emit(tx, cfg.Name, putEvent(id, bucket.Get(id), raw))
+	return bucket.Put(id, raw)
+}
 
+// saveIndexes references the record of the given ID in the indexes of its fields and in its compound indexes.
+// When updating, the zero fields are skipped unless their update is forced.
+func (n *node) saveIndexes(bucket *bolt.Bucket, cfg *structConfig, data interface{}, id []byte, update bool) error {
+	for fieldName, fieldCfg := range cfg.Fields {
 		if fieldCfg.Index == "" {
 			continue
 		}
@@ -211,47 +299,124 @@ func (n *node) save(tx *bolt.Tx, cfg *structConfig, data interface{}, update boo
 			continue
 		}
 
-		value, err := toBytes(fieldCfg.Value.Interface(), n.codec)
+		err = n.indexField(idx, fieldCfg, id)
 		if err != nil {
 			return err
 		}
+	}
+
+	if len(cfg.CompoundIndexes) > 0 {
+		return n.saveCompoundIndexes(bucket, data, id)
+	}
+
+	return nil
+}
+
+// saveCompoundIndexes indexes the given record in all of its compound indexes.
+func (n *node) saveCompoundIndexes(bucket *bolt.Bucket, data interface{}, id []byte) error {
+	// when updating, the config only holds the modified fields,
+	// the indexed values are extracted from the whole record instead
+	ref := reflect.ValueOf(data)
+	cfg, err := extract(&ref)
+	if err != nil {
+		return err
+	}
 
-		var found bool
-		idsSaved, err := idx.All(value, nil)
+	for _, ci := range cfg.CompoundIndexes {
+		idx, err := getIndex(bucket, ci.Kind, ci.Name)
 		if err != nil {
 			return err
 		}
-		for _, idSaved := range idsSaved {
-			if bytes.Compare(idSaved, id) == 0 {
-				found = true
-				break
-			}
-		}
 
-		if found {
-			continue
+		value, err := ci.value(n.codec)
+		if err != nil {
+			return err
 		}
 
-		err = idx.RemoveID(id)
+		if value == nil {
+			err = idx.RemoveID(id)
+		} else {
+			err = updateIndex(idx, value, id)
+		}
 		if err != nil {
 			return err
 		}
+	}
 
-		err = idx.Add(value, id)
+	return nil
+}
+
+// updateIndex associates the given value with the id, replacing its previous value if any.
+func updateIndex(idx index.Index, value, id []byte) error {
+	idsSaved, err := idx.All(value, nil)
+	if err != nil {
+		return err
+	}
+	for _, idSaved := range idsSaved {
+		if bytes.Compare(idSaved, id) == 0 {
+			return nil
+		}
+	}
+
+	err = idx.RemoveID(id)
+	if err != nil {
+		return err
+	}
+
+	err = idx.Add(value, id)
+	if err != nil {
+		if err == index.ErrAlreadyExists {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// indexField references the given id by the value of the field in its index.
+func (n *node) indexField(idx index.Index, fieldCfg *fieldConfig, id []byte) error {
+	if fieldCfg.Index == tagFullText {
+		terms := analyze(fieldCfg.Value.String(), fieldCfg.Analyzer)
+		return idx.Add([]byte(strings.Join(terms, " ")), id)
+	}
+
+	if fieldCfg.Index == tagTrigram {
+		return idx.Add([]byte(strings.ToLower(fieldCfg.Value.String())), id)
+	}
+
+	if fieldCfg.Index == tagMulti {
+		values, err := toMultiIndexBytes(*fieldCfg.Value, n.codec)
 		if err != nil {
-			if err == index.ErrAlreadyExists {
-				return ErrAlreadyExists
-			}
 			return err
 		}
+
+		return updateMultiIndex(idx, values, id)
 	}
 
-	raw, err := n.codec.Marshal(data)
+	value, err := toIndexBytes(fieldCfg.Value.Interface(), n.codec)
 	if err != nil {
 		return err
 	}
 
-	return bucket.Put(id, raw)
+	return updateIndex(idx, value, id)
+}
+
+// updateMultiIndex replaces the values referencing the given id in a multi index.
+func updateMultiIndex(idx index.Index, values [][]byte, id []byte) error {
+	err := idx.RemoveID(id)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		err = idx.Add(value, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Update a structure
@@ -334,10 +499,39 @@ func (n *node) update(data interface{}, fn func(*reflect.Value, *reflect.Value,
 			return err
 		}
 
-		return n.save(tx, cfg, current.Interface(), true)
+		refreshNestedFields(cfg, &cref)
+		err = n.saveWithHooks(tx, cfg, current.Interface(), true)
+		if err != nil {
+			return err
+		}
+
+		// the version and the update times set when saving are copied to the given record,
+		// so that it can be updated again
+		for name, f := range cfg.Fields {
+			if f.IsVersion || f.Timestamp == tagUpdated {
+				ref.FieldByName(name).Set(cref.FieldByName(name))
+			}
+		}
+
+		return nil
 	})
 }
 
+// refreshNestedFields points the nested fields of the config to the values of the given record,
+// so that they are reindexed even when only their parent was updated.
+func refreshNestedFields(cfg *structConfig, record *reflect.Value) {
+	for name, f := range cfg.Fields {
+		if !strings.Contains(name, ".") {
+			continue
+		}
+
+		v := internal.FieldByPath(*record, name)
+		f.Value = &v
+		f.IsZero = isZero(&v)
+		f.ForceUpdate = true
+	}
+}
+
 // Drop a bucket
 func (n *node) Drop(data interface{}) error {
 	var bucketName string
@@ -360,6 +554,8 @@ func (n *node) Drop(data interface{}) error {
 }
 
 func (n *node) drop(tx *bolt.Tx, bucketName string) error {
+	n.emit(tx, bucketName, Event{Type: EventDrop})
+
 	bucket := n.GetBucket(tx)
 	if bucket == nil {
 		return tx.DeleteBucket([]byte(bucketName))
@@ -387,7 +583,21 @@ func (n *node) DeleteStruct(data interface{}) error {
 	}
 
 	return n.readWriteTx(func(tx *bolt.Tx) error {
-		return n.deleteStruct(tx, cfg, id)
+		err := n.beforeDelete(tx, data)
+		if err != nil {
+			return err
+		}
+
+		if deletedField(cfg.Type) != "" {
+			err = n.softDelete(tx, cfg)
+		} else {
+			err = n.deleteStruct(tx, cfg, id)
+		}
+		if err != nil {
+			return err
+		}
+
+		return n.afterDelete(tx, data)
 	})
 }
 
@@ -397,6 +607,16 @@ func (n *node) deleteStruct(tx *bolt.Tx, cfg *structConfig, id []byte) error {
 		return ErrNotFound
 	}
 
+	raw := bucket.Get(id)
+	if raw == nil {
+		return ErrNotFound
+	}
+
+	refs, err := n.referrers(tx, cfg.Name, cfg.Type, id, raw)
+	if err != nil {
+		return err
+	}
+
 	for fieldName, fieldCfg := range cfg.Fields {
 		if fieldCfg.Index == "" {
 			continue
@@ -416,10 +636,75 @@ func (n *node) deleteStruct(tx *bolt.Tx, cfg *structConfig, id []byte) error {
 		}
 	}
 
+	for _, ci := range cfg.CompoundIndexes {
+		idx, err := getIndex(bucket, ci.Kind, ci.Name)
+		if err != nil {
+			return err
+		}
+
+		err = idx.RemoveID(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the value is read again, the bucket was modified
+	raw = bucket.Get(id)
+	n.emit(tx, cfg.Name, Event{Type: EventDelete, ID: id, Old: raw})
+	err = bucket.Delete(id)
+	if err != nil {
+		return err
+	}
+
+	return n.deleteRefs(tx, refs)
+}
+
+// deleteRecord deletes a record whose type is unknown, removing it from the indexes
+// stored in the metadata of its bucket.
+func (n *node) deleteRecord(tx *bolt.Tx, bucketName string, id []byte) error {
+	bucket := n.GetBucket(tx, bucketName)
+	if bucket == nil {
+		return ErrNotFound
+	}
+
 	raw := bucket.Get(id)
 	if raw == nil {
 		return ErrNotFound
 	}
 
-	return bucket.Delete(id)
+	// the indexes of buckets saved by older versions are not stored
+	var indexes *bolt.Bucket
+	if m := bucket.Bucket([]byte(metadataBucket)); m != nil {
+		indexes = m.Bucket([]byte(metaIndexes))
+	}
+	if indexes == nil {
+		return ErrUnknownRef
+	}
+
+	refs, err := n.referrers(tx, bucketName, nil, id, raw)
+	if err != nil {
+		return err
+	}
+
+	err = indexes.ForEach(func(name, kind []byte) error {
+		idx, err := getIndex(bucket, string(kind), string(name))
+		if err != nil {
+			return err
+		}
+
+		return idx.RemoveID(id)
+	})
+	if err != nil {
+		return err
+	}
+
+	// the value is read again, the bucket was modified
+	raw = bucket.Get(id)
+	n.emit(tx, bucketName, Event{Type: EventDelete, ID: id, Old: raw})
+	err = bucket.Delete(id)
+	if err != nil {
+		return err
+	}
+
+	return n.deleteRefs(tx, refs)
 }
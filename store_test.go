@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/asdine/storm/v3/codec/aes"
 	"github.com/asdine/storm/v3/codec/gob"
 	"github.com/asdine/storm/v3/codec/json"
 	"github.com/asdine/storm/v3/q"
@@ -92,7 +93,15 @@ func TestReIndex(t *testing.T) {
 		Group string `storm:"unique"`
 	}
 
+	// the records are not rewritten
+	var events []Event
+	_, err := db.Watch(new(User), func(e Event) {
+		events = append(events, e)
+	})
+	require.NoError(t, err)
+
 	require.NoError(t, db.ReIndex(new(User)))
+	require.Empty(t, events)
 
 	db.Bolt.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte("User"))
@@ -672,3 +681,52 @@ func TestDeleteStruct(t *testing.T) {
 	require.Len(t, users, 8)
 	require.Equal(t, 3, users[0].ID)
 }
+
+func TestSaveBindLocation(t *testing.T) {
+	c, err := aes.NewAES(json.Codec, []byte("0123456789abcdef"), aes.BindLocation())
+	require.NoError(t, err)
+
+	db, cleanup := createDB(t, Codec(c))
+	defer cleanup()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, db.Save(&User{ID: i, Name: "John"}))
+		require.NoError(t, db.From("a").Save(&User{ID: i, Name: "Jack"}))
+	}
+	require.NoError(t, db.Set("config", "theme", "dark"))
+	require.NoError(t, db.Set("config", "lang", "en"))
+
+	var users []User
+	require.NoError(t, db.Find("Name", "John", &users))
+	require.Len(t, users, 3)
+	require.NoError(t, db.From("a").Select(q.Eq("Name", "Jack")).Find(&users))
+	require.Len(t, users, 3)
+
+	var names []map[string]interface{}
+	require.NoError(t, db.Select().Project(new(User), "Name").Find(&names))
+	require.Len(t, names, 3)
+
+	var theme string
+	require.NoError(t, db.Get("config", "theme", &theme))
+	require.Equal(t, "dark", theme)
+
+	// values moved to another record, key or bucket fail to decrypt
+	raw, err := db.GetBytes("User", 1)
+	require.NoError(t, err)
+	require.NoError(t, db.SetBytes("User", 2, raw))
+
+	var user User
+	require.Error(t, db.One("ID", 2, &user))
+	require.Error(t, db.Select(q.Eq("ID", 2)).First(&user))
+	require.NoError(t, db.One("ID", 1, &user))
+
+	require.NoError(t, db.From("a").SetBytes("User", 1, raw))
+	require.Error(t, db.From("a").One("ID", 1, &user))
+
+	raw, err = db.GetBytes("config", "theme")
+	require.NoError(t, err)
+	require.NoError(t, db.SetBytes("config", "lang", raw))
+
+	var lang string
+	require.Error(t, db.Get("config", "lang", &lang))
+}
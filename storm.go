@@ -3,6 +3,10 @@ package storm
 import (
 	"bytes"
 	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/asdine/storm/v3/codec"
@@ -30,7 +34,12 @@ func Open(path string, stormOptions ...func(*Options) error) (*DB, error) {
 	}
 
 	s := DB{
-		Bolt: opts.bolt,
+		Bolt:  opts.bolt,
+		clock: opts.clock,
+	}
+
+	if s.clock == nil {
+		s.clock = time.Now
 	}
 
 	n := node{
@@ -67,6 +76,12 @@ func Open(path string, stormOptions ...func(*Options) error) (*DB, error) {
 		return nil, err
 	}
 
+	if opts.sweepInterval > 0 && !s.Bolt.IsReadOnly() {
+		s.stopSweep = make(chan struct{})
+		s.sweepDone = make(chan struct{})
+		go s.sweep(opts.sweepInterval, opts.onSweepError)
+	}
+
 	return &s, nil
 }
 
@@ -78,10 +93,30 @@ type DB struct {
 
 	// Bolt is still easily accessible
 	Bolt *bolt.DB
+
+	watchers watchers
+
+	// Relations between the types saved or initialized
+	relations relations
+
+	// Returns the current time
+	clock func() time.Time
+
+	// Stop the sweeper, which closes sweepDone once stopped. Nil if there is no sweeper
+	stopSweep chan struct{}
+	sweepDone chan struct{}
+	closeOnce sync.Once
 }
 
 // Close the database
 func (s *DB) Close() error {
+	if s.stopSweep != nil {
+		s.closeOnce.Do(func() {
+			close(s.stopSweep)
+			<-s.sweepDone
+		})
+	}
+
 	return s.Bolt.Close()
 }
 
@@ -92,12 +127,104 @@ func (s *DB) checkVersion() error {
 		return err
 	}
 
-	// for now, we only set the current version if it doesn't exist.
-	// v1 and v2 database files are compatible.
+	// new database
 	if v == "" {
 		return s.Set(dbinfo, "version", Version)
 	}
 
+	if v == Version || s.Bolt.IsReadOnly() {
+		return nil
+	}
+
+	// v1 and v2 database files are compatible.
+	// Since v3, index values are encoded so that they sort like the values they represent.
+	// Buckets without indexes are marked as migrated. The others can't be reindexed without
+	// the type of their records, they must be rebuilt with ReIndex or the RebuildIndexes migration.
+	if compareVersions(v, "3.0.0") < 0 {
+		err = s.Bolt.Update(func(tx *bolt.Tx) error {
+			return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				return markIndexEncoding(b)
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.Set(dbinfo, "version", Version)
+}
+
+// compareVersions compares the numeric parts of the given versions. It returns -1 if a
+// is older than b, 1 if it is newer and 0 if they are equal.
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = leadingNumber(pa[i])
+		}
+		if i < len(pb) {
+			y = leadingNumber(pb[i])
+		}
+
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// leadingNumber returns the number made of the leading digits of s, as in "0-rc1".
+func leadingNumber(s string) int {
+	var n int
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			break
+		}
+		n = n*10 + int(r-'0')
+	}
+
+	return n
+}
+
+// markIndexEncoding marks the given bucket and its children as using the current index encoding
+// if they don't contain any index.
+func markIndexEncoding(b *bolt.Bucket) error {
+	m := b.Bucket([]byte(metadataBucket))
+
+	var children [][]byte
+	var hasIndexes bool
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil {
+			continue
+		}
+
+		if bytes.HasPrefix(k, []byte(indexPrefix)) {
+			hasIndexes = true
+		} else if !bytes.Equal(k, []byte(metadataBucket)) {
+			children = append(children, k)
+		}
+	}
+
+	if m != nil && !hasIndexes && m.Get([]byte(metaIndexEncoding)) == nil {
+		err := m.Put([]byte(metaIndexEncoding), []byte(indexEncoding))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range children {
+		err := markIndexEncoding(b.Bucket(name))
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -122,6 +249,110 @@ func toBytes(key interface{}, codec codec.MarshalUnmarshaler) ([]byte, error) {
 	}
 }
 
+// toIndexBytes turns a field value into the slice of bytes stored in an index.
+// Numbers and dates are encoded so that the encoded values sort like the values themselves.
+func toIndexBytes(value interface{}, codec codec.MarshalUnmarshaler) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	if t, ok := value.(time.Time); ok {
+		return timetob(t), nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return toIndexBytes(v.Elem().Interface(), codec)
+		}
+	case reflect.String:
+		return []byte(v.String()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sortableUint(uint64(v.Int()) ^ (1 << 63)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return sortableUint(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return floattob(v.Float()), nil
+	}
+
+	return codec.Marshal(value)
+}
+
+// toFieldIndexBytes converts the given value to the type of a field, if possible,
+// and turns it into the slice of bytes stored in the index of that field.
+func toFieldIndexBytes(value interface{}, field *fieldConfig, codec codec.MarshalUnmarshaler) ([]byte, error) {
+	if v, ok := convertIndexValue(value, field.indexType()); ok {
+		value = v.Interface()
+	}
+
+	return toIndexBytes(value, codec)
+}
+
+// toMultiIndexBytes encodes each element of the given slice or array, or each key of the given map.
+// Elements encoded as empty values are not indexed.
+func toMultiIndexBytes(value reflect.Value, codec codec.MarshalUnmarshaler) ([][]byte, error) {
+	var elems []reflect.Value
+	if value.Kind() == reflect.Map {
+		elems = value.MapKeys()
+	} else {
+		for i := 0; i < value.Len(); i++ {
+			elems = append(elems, value.Index(i))
+		}
+	}
+
+	list := make([][]byte, 0, len(elems))
+	for _, elem := range elems {
+		raw, err := toIndexBytes(elem.Interface(), codec)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(raw) > 0 {
+			list = append(list, raw)
+		}
+	}
+
+	return list, nil
+}
+
+func sortableUint(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// floattob encodes the IEEE 754 representation of the given number with the sign bit flipped,
+// and all the other bits flipped too for negative numbers.
+func floattob(f float64) []byte {
+	if f == 0 {
+		// -0 and +0 are equal
+		f = 0
+	}
+
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+
+	return sortableUint(bits)
+}
+
+// timetob encodes the number of seconds since January 1, 1970 UTC with the sign bit flipped,
+// followed by the nanoseconds.
+func timetob(t time.Time) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b, uint64(t.Unix())^(1<<63))
+	binary.BigEndian.PutUint32(b[8:], uint32(t.Nanosecond()))
+	return b
+}
+
 func numbertob(v interface{}) ([]byte, error) {
 	var buf bytes.Buffer
 	err := binary.Write(&buf, binary.BigEndian, v)
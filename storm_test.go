@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/asdine/storm/v3/codec/json"
+	"github.com/asdine/storm/v3/index"
+	"github.com/asdine/storm/v3/q"
 	bolt "go.etcd.io/bbolt"
 	"github.com/stretchr/testify/require"
 )
@@ -177,3 +179,153 @@ func createDB(t errorHandler, opts ...func(*Options) error) (*DB, func()) {
 type errorHandler interface {
 	Error(args ...interface{})
 }
+
+func TestToIndexBytes(t *testing.T) {
+	now := time.Now()
+
+	sorted := [][]interface{}{
+		{-300, int8(-math.MaxInt8), int16(-2), int64(-1), 0, int32(1), 2, int8(3), int64(math.MaxInt64)},
+		{uint(0), uint8(1), uint16(300), uint32(70000), uint64(math.MaxUint64)},
+		{math.Inf(-1), -math.MaxFloat64, -10.5, float32(-1), -math.SmallestNonzeroFloat64, 0.0, math.SmallestNonzeroFloat64, float32(1), 10.5, math.MaxFloat64, math.Inf(1)},
+		{time.Time{}.Add(time.Hour), time.Unix(-1e10, 0), time.Unix(-1, 999), time.Unix(0, 0), now, now.Add(time.Nanosecond), now.Add(time.Hour)},
+		{"", "a", "ab", "b"},
+	}
+
+	for _, list := range sorted {
+		var prev []byte
+		for i, v := range list {
+			b, err := toIndexBytes(v, json.Codec)
+			require.NoError(t, err)
+			if i > 0 {
+				require.Equal(t, -1, bytes.Compare(prev, b), "%v should sort before %v", list[i-1], v)
+			}
+			prev = b
+		}
+	}
+
+	// all the integer types share the same encoding
+	a, _ := toIndexBytes(int8(-5), json.Codec)
+	b, _ := toIndexBytes(int64(-5), json.Codec)
+	require.Equal(t, a, b)
+
+	// times are compared by instant
+	a, _ = toIndexBytes(now, json.Codec)
+	b, _ = toIndexBytes(now.UTC(), json.Codec)
+	require.Equal(t, a, b)
+
+	// pointers are dereferenced
+	b, _ = toIndexBytes(&now, json.Codec)
+	require.Equal(t, a, b)
+
+	a, _ = toIndexBytes(0.0, json.Codec)
+	b, _ = toIndexBytes(math.Copysign(0, -1), json.Codec)
+	require.Equal(t, a, b)
+
+	b, err := toIndexBytes(&SimpleUser{ID: 10, Name: "John", age: 100}, json.Codec)
+	require.NoError(t, err)
+	require.Equal(t, `{"ID":10,"Name":"John"}`, string(b))
+}
+
+func TestLegacyIndexMigration(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "storm")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "storm.db")
+
+	type Account struct {
+		ID      int `storm:"id"`
+		Balance int `storm:"index"`
+		Name    string
+	}
+
+	db, err := Open(path)
+	require.NoError(t, err)
+
+	for i := 1; i <= 10; i++ {
+		require.NoError(t, db.Save(&Account{ID: i, Balance: (i - 5) * 100}))
+	}
+	require.NoError(t, db.Set("config", "key", "value"))
+
+	// rewrite the indexes the way storm v2 did and downgrade the database
+	err = db.Bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("Account"))
+		require.NoError(t, b.DeleteBucket([]byte(indexPrefix+"Balance")))
+		require.NoError(t, b.DeleteBucket([]byte(indexPrefix+"ID")))
+		idx, err := index.NewListIndex(b, []byte(indexPrefix+"Balance"))
+		require.NoError(t, err)
+		for i := 1; i <= 10; i++ {
+			if i == 5 {
+				continue
+			}
+			id, _ := toBytes(i, nil)
+			value, _ := toBytes((i-5)*100, nil)
+			require.NoError(t, idx.Add(value, id))
+		}
+
+		for _, name := range []string{"Account", "config"} {
+			require.NoError(t, tx.Bucket([]byte(name)).Bucket([]byte(metadataBucket)).Delete([]byte(metaIndexEncoding)))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Set(dbinfo, "version", "2.0.0"))
+	require.NoError(t, db.Close())
+
+	db, err = Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var v string
+	require.NoError(t, db.Get(dbinfo, "version", &v))
+	require.Equal(t, Version, v)
+
+	err = db.Bolt.View(func(tx *bolt.Tx) error {
+		require.False(t, hasLegacyIndexes(tx.Bucket([]byte("config"))))
+		require.True(t, hasLegacyIndexes(tx.Bucket([]byte("Account"))))
+		return nil
+	})
+	require.NoError(t, err)
+
+	// legacy indexes are not used
+	var accounts []Account
+	require.NoError(t, db.Range("Balance", -300, 100, &accounts))
+	require.Len(t, accounts, 5)
+	require.NoError(t, db.Find("Balance", -100, &accounts))
+	require.Len(t, accounts, 1)
+	require.NoError(t, db.Select(q.Gte("Balance", -200)).Find(&accounts))
+	require.Len(t, accounts, 8)
+
+	// the indexes are not rebuilt by writes
+	require.Equal(t, ErrLegacyIndexes, db.Save(&Account{ID: 11, Balance: -1000}))
+	require.Equal(t, ErrLegacyIndexes, db.Init(&Account{}))
+
+	migration := Migration{ID: "indexes", Migrate: RebuildIndexes(&Account{}, &User{})}
+	require.NoError(t, Migrate(db, migration))
+	err = db.Bolt.View(func(tx *bolt.Tx) error {
+		require.False(t, hasLegacyIndexes(tx.Bucket([]byte("Account"))))
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Save(&Account{ID: 11, Balance: -1000}))
+
+	require.NoError(t, db.Range("Balance", -1000, 100, &accounts))
+	require.Len(t, accounts, 6)
+	require.Equal(t, 11, accounts[0].ID)
+	require.Equal(t, 1, accounts[1].ID)
+	require.Equal(t, 6, accounts[5].ID)
+
+	plan, err := db.Select(q.Gte("Balance", -200), q.Lt("Balance", 0)).Explain(&Account{})
+	require.NoError(t, err)
+	require.Equal(t, "Balance", plan.Index)
+	require.Equal(t, 2, plan.ReadRows)
+}
+
+func TestCompareVersions(t *testing.T) {
+	require.Equal(t, 0, compareVersions("3.0.0", "3.0.0"))
+	require.Equal(t, 0, compareVersions("3.0", "3.0.0"))
+	require.Equal(t, -1, compareVersions("2.2.1", "3.0.0"))
+	require.Equal(t, -1, compareVersions("3.0.0-rc1", "3.0.1"))
+	require.Equal(t, 1, compareVersions("10.0.0", "3.0.0"))
+	require.Equal(t, 1, compareVersions("3.10.0", "3.9.0"))
+}
@@ -135,3 +135,27 @@ type UniqueNameUser struct {
 	Name string `storm:"unique"`
 	Age  int    `storm:"index,increment"`
 }
+
+type Address struct {
+	ID      int    `storm:"id,increment"`
+	City    string `storm:"index"`
+	ZipCode string `storm:"unique"`
+	Street  string
+}
+
+type Customer struct {
+	ID      int      `storm:"increment"`
+	Name    string   `storm:"index"`
+	Address Address  `storm:"nested"`
+	Billing *Address `storm:"nested"`
+	Other   Address
+}
+
+type Post struct {
+	ID     int          `storm:"increment"`
+	Title  string       `storm:"index"`
+	Tags   []string     `storm:"index,multi"`
+	Voters map[int]bool `storm:"index,multi"`
+	Words  []string     `storm:"index"`
+	Meta   map[string]int
+}
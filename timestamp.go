@@ -0,0 +1,108 @@
+package storm
+
+import (
+	"reflect"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// isTimestamp reports whether a field of the given type can be tagged with created or updated.
+func isTimestamp(typ reflect.Type) bool {
+	if typ == timeType || typ == reflect.PtrTo(timeType) {
+		return true
+	}
+
+	kind := typ.Kind()
+	return kind >= reflect.Int && kind <= reflect.Uint64
+}
+
+// setTimestamps fills the fields of the record tagged with updated and, when inserting it,
+// those tagged with created. The created fields of a record replacing a saved one
+// keep their saved value if they are zero.
+func (n *node) setTimestamps(tx *bolt.Tx, cfg *structConfig, data interface{}, update bool) error {
+	now := n.s.clock()
+	record := reflect.Indirect(reflect.ValueOf(data))
+
+	var saved *reflect.Value
+	for name, f := range cfg.Fields {
+		if f.Timestamp == "" {
+			continue
+		}
+
+		v := record.FieldByName(name)
+		if f.Timestamp == tagCreated {
+			if update || !isZero(&v) {
+				continue
+			}
+
+			if saved == nil {
+				s, err := n.savedRecord(tx, cfg)
+				if err != nil {
+					return err
+				}
+				saved = &s
+			}
+
+			if saved.IsValid() {
+				v.Set(saved.FieldByName(name))
+				if !isZero(&v) {
+					f.Value = &v
+					f.IsZero = false
+					continue
+				}
+			}
+		}
+
+		setTime(v, now)
+		f.Value = &v
+		f.IsZero = false
+		f.ForceUpdate = true
+	}
+
+	return nil
+}
+
+// savedRecord returns the saved version of the record, or an invalid value if there is none.
+func (n *node) savedRecord(tx *bolt.Tx, cfg *structConfig) (reflect.Value, error) {
+	if cfg.ID.IsZero {
+		return reflect.Value{}, nil
+	}
+
+	bucket := n.GetBucket(tx, cfg.Name)
+	if bucket == nil {
+		return reflect.Value{}, nil
+	}
+
+	id, err := toBytes(cfg.ID.Value.Interface(), n.codec)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	raw := bucket.Get(id)
+	if raw == nil {
+		return reflect.Value{}, nil
+	}
+
+	record := reflect.New(cfg.Type)
+	err = n.codecAt(cfg.Name, id).Unmarshal(raw, record.Interface())
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	return record.Elem(), nil
+}
+
+// setTime sets the value of a timestamp field, integers are set to the Unix time.
+func setTime(v reflect.Value, t time.Time) {
+	switch kind := v.Kind(); {
+	case v.Type() == timeType:
+		v.Set(reflect.ValueOf(t))
+	case kind == reflect.Ptr:
+		v.Set(reflect.ValueOf(&t))
+	case kind >= reflect.Int && kind <= reflect.Int64:
+		v.SetInt(t.Unix())
+	default:
+		v.SetUint(uint64(t.Unix()))
+	}
+}
@@ -0,0 +1,76 @@
+package storm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type Draft struct {
+	ID        int `storm:"increment"`
+	Title     string
+	CreatedAt time.Time  `storm:"created,index"`
+	UpdatedAt *time.Time `storm:"updated"`
+	Touched   int64      `storm:"updated"`
+}
+
+func TestTimestamps(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, cleanup := createDB(t, Clock(func() time.Time {
+		return now
+	}))
+	defer cleanup()
+
+	p := Draft{Title: "a"}
+	require.NoError(t, db.Save(&p))
+	require.Equal(t, now, p.CreatedAt)
+	require.Equal(t, now, *p.UpdatedAt)
+	require.Equal(t, now.Unix(), p.Touched)
+
+	created := now
+	now = now.Add(time.Hour)
+
+	// the creation time of a saved record is kept
+	require.NoError(t, db.Save(&Draft{ID: 1, Title: "b"}))
+	require.NoError(t, db.One("ID", 1, &p))
+	require.Equal(t, created, p.CreatedAt)
+	require.Equal(t, now, *p.UpdatedAt)
+	require.NoError(t, db.One("CreatedAt", created, &p))
+
+	now = now.Add(time.Hour)
+	require.NoError(t, db.Update(&Draft{ID: 1, Title: "c"}))
+	require.NoError(t, db.One("ID", 1, &p))
+	require.Equal(t, created, p.CreatedAt)
+	require.Equal(t, now, *p.UpdatedAt)
+	require.Equal(t, now.Unix(), p.Touched)
+
+	now = now.Add(time.Hour)
+	require.NoError(t, db.UpdateField(&Draft{ID: 1}, "Title", "d"))
+	require.NoError(t, db.One("ID", 1, &p))
+	require.Equal(t, created, p.CreatedAt)
+	require.Equal(t, now, *p.UpdatedAt)
+	require.Equal(t, now.Unix(), p.Touched)
+
+	// the update times are set in the updated record
+	now = now.Add(time.Hour)
+	u := Draft{ID: 1, Title: "e"}
+	require.NoError(t, db.Update(&u))
+	require.Equal(t, now, *u.UpdatedAt)
+	require.Equal(t, now.Unix(), u.Touched)
+
+	now = now.Add(time.Hour)
+	require.NoError(t, db.UpdateField(&u, "Title", "f"))
+	require.Equal(t, now, *u.UpdatedAt)
+
+	// an explicit creation time is kept
+	require.NoError(t, db.Save(&Draft{Title: "e", CreatedAt: created}))
+	require.NoError(t, db.One("ID", 2, &p))
+	require.Equal(t, created, p.CreatedAt)
+
+	type BadTimestamp struct {
+		ID   int
+		Date string `storm:"created"`
+	}
+	require.Equal(t, ErrBadTimestamp, db.Save(&BadTimestamp{ID: 1}))
+}
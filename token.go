@@ -0,0 +1,104 @@
+package storm
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"reflect"
+
+	"github.com/asdine/storm/v3/index"
+)
+
+// After returns the records following the one the given continuation token was created for.
+// Unlike Skip, the records before the token are not read.
+func After(token string) func(*index.Options) {
+	return func(opts *index.Options) {
+		pos, err := decodeToken(token)
+		if err != nil {
+			// reported by checkAfter, a position always has an ID
+			pos = &index.Position{}
+		}
+		opts.After = pos
+	}
+}
+
+// checkAfter returns ErrInvalidToken if the options were given an invalid token.
+func checkAfter(opts *index.Options) error {
+	if opts.After != nil && len(opts.After.ID) == 0 {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// Token returns a continuation token for the given record, to be used with the After option
+// or with Query.After to get the records that follow it.
+// The name of the indexed field or compound index used to fetch the records must be given,
+// or an empty string if the records are fetched with All or Select.
+func (n *node) Token(fieldName string, record interface{}) (string, error) {
+	ref := reflect.ValueOf(record)
+
+	if !ref.IsValid() || ref.Kind() != reflect.Ptr || ref.Elem().Kind() != reflect.Struct {
+		return "", ErrStructPtrNeeded
+	}
+
+	cfg, err := extract(&ref)
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.ID.IsZero {
+		return "", ErrZeroID
+	}
+
+	id, err := toBytes(cfg.ID.Value.Interface(), n.codec)
+	if err != nil {
+		return "", err
+	}
+
+	pos := index.Position{ID: id}
+	if ci, ok := cfg.CompoundIndexes[fieldName]; ok {
+		pos.Value, err = ci.value(n.codec)
+	} else if f, ok := cfg.Fields[fieldName]; ok && !f.IsID && f.hasLookupIndex() {
+		// records can be referenced by several values of a multi index
+		if f.Index == tagMulti {
+			return "", ErrIncompatibleValue
+		}
+		pos.Value, err = toIndexBytes(f.Value.Interface(), n.codec)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return encodeToken(&pos), nil
+}
+
+// encodeToken encodes the length of the value, the value and the id using URL safe base64.
+func encodeToken(pos *index.Position) string {
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(pos.Value)+len(pos.ID))
+	buf = buf[:binary.PutUvarint(buf, uint64(len(pos.Value)))]
+	buf = append(buf, pos.Value...)
+	buf = append(buf, pos.ID...)
+
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodeToken(token string) (*index.Position, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	l, n := binary.Uvarint(raw)
+	if n <= 0 || uint64(len(raw)-n) <= l {
+		return nil, ErrInvalidToken
+	}
+
+	pos := index.Position{
+		ID: raw[n+int(l):],
+	}
+	if l > 0 {
+		pos.Value = raw[n : n+int(l)]
+	}
+
+	return &pos, nil
+}
@@ -0,0 +1,197 @@
+package storm
+
+import (
+	"testing"
+
+	"github.com/asdine/storm/v3/index"
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToken(t *testing.T) {
+	pos := &index.Position{Value: []byte("value"), ID: []byte("id")}
+	decoded, err := decodeToken(encodeToken(pos))
+	require.NoError(t, err)
+	require.Equal(t, pos, decoded)
+
+	pos = &index.Position{ID: []byte("id")}
+	decoded, err = decodeToken(encodeToken(pos))
+	require.NoError(t, err)
+	require.Equal(t, pos, decoded)
+
+	for _, token := range []string{"", "!", encodeToken(&index.Position{Value: []byte("value")})} {
+		_, err = decodeToken(token)
+		require.Equal(t, ErrInvalidToken, err)
+	}
+
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	_, err = db.Token("", &User{})
+	require.Equal(t, ErrZeroID, err)
+
+	_, err = db.Token("", User{ID: 10})
+	require.Equal(t, ErrStructPtrNeeded, err)
+}
+
+func TestPaginateAll(t *testing.T) {
+	db, cleanup := prepareScoreDB(t)
+	defer cleanup()
+
+	var pages [][]int
+	var token string
+	for {
+		options := []func(*index.Options){Limit(6)}
+		if token != "" {
+			options = append(options, After(token))
+		}
+
+		var scores []Score
+		err := db.All(&scores, options...)
+		require.NoError(t, err)
+		if len(scores) == 0 {
+			break
+		}
+
+		var page []int
+		for _, s := range scores {
+			page = append(page, s.Value)
+		}
+		pages = append(pages, page)
+
+		token, err = db.Token("", &scores[len(scores)-1])
+		require.NoError(t, err)
+	}
+
+	require.Len(t, pages, 4)
+	require.Equal(t, []int{6, 7, 8, 9, 10, 11}, pages[1])
+	require.Equal(t, []int{18, 19}, pages[3])
+
+	var scores []Score
+	token, err := db.Token("", &Score{ID: 10})
+	require.NoError(t, err)
+	err = db.All(&scores, After(token), Limit(2), Reverse())
+	require.NoError(t, err)
+	require.Len(t, scores, 2)
+	require.Equal(t, 8, scores[0].Value)
+	require.Equal(t, 7, scores[1].Value)
+
+	err = db.All(&scores, After("!"))
+	require.Equal(t, ErrInvalidToken, err)
+}
+
+func TestPaginateSelect(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	for i := 1; i <= 20; i++ {
+		group := "a"
+		if i%2 == 0 {
+			group = "b"
+		}
+		require.NoError(t, db.Save(&User{ID: i, Name: "John", Group: group}))
+	}
+
+	token, err := db.Token("", &User{ID: 10})
+	require.NoError(t, err)
+
+	// scans the bucket
+	var users []User
+	err = db.Select(q.Eq("Group", "b")).After(token).Limit(2).Find(&users)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, 12, users[0].ID)
+	require.Equal(t, 14, users[1].ID)
+
+	err = db.Select(q.Eq("Group", "b")).After(token).Reverse().Find(&users)
+	require.NoError(t, err)
+	require.Len(t, users, 4)
+	require.Equal(t, 8, users[0].ID)
+
+	// uses the index
+	query := db.Select(q.Eq("Name", "John")).After(token).Limit(2)
+	plan, err := query.Explain(new(User))
+	require.NoError(t, err)
+	require.False(t, plan.FullScan())
+
+	err = query.Find(&users)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, 11, users[0].ID)
+	require.Equal(t, 12, users[1].ID)
+
+	err = db.Select(q.Eq("Name", "John")).After(token).Reverse().Limit(1).Find(&users)
+	require.NoError(t, err)
+	require.Equal(t, 9, users[0].ID)
+
+	err = db.Select().After(token).OrderBy("Name").Find(&users)
+	require.Equal(t, ErrTokenWithOrderBy, err)
+
+	err = db.Select().After("!").Find(&users)
+	require.Equal(t, ErrInvalidToken, err)
+}
+
+func TestPaginateIndex(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	names := []string{"John", "Jack", "John", "Jane", "Jack", "John"}
+	for i, name := range names {
+		require.NoError(t, db.Save(&User{ID: i + 1, Name: name, Slug: name + string(rune('a'+i))}))
+	}
+
+	var users []User
+	err := db.AllByIndex("Name", &users, Limit(3))
+	require.NoError(t, err)
+	require.Len(t, users, 3)
+	require.Equal(t, []int{2, 5, 4}, []int{users[0].ID, users[1].ID, users[2].ID})
+
+	token, err := db.Token("Name", &users[2])
+	require.NoError(t, err)
+
+	err = db.AllByIndex("Name", &users, After(token))
+	require.NoError(t, err)
+	require.Len(t, users, 3)
+	require.Equal(t, []int{1, 3, 6}, []int{users[0].ID, users[1].ID, users[2].ID})
+
+	// the position is kept inside a list of equal values
+	token, err = db.Token("Name", &users[0])
+	require.NoError(t, err)
+
+	err = db.Find("Name", "John", &users, After(token))
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, 3, users[0].ID)
+
+	err = db.Range("Name", "Jack", "Jane", &users, After(token))
+	require.Equal(t, ErrNotFound, err)
+
+	token, err = db.Token("Name", &User{ID: 2, Name: "Jack"})
+	require.NoError(t, err)
+
+	err = db.Range("Name", "Jack", "Jane", &users, After(token))
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, 5, users[0].ID)
+	require.Equal(t, 4, users[1].ID)
+
+	err = db.Prefix("Name", "Ja", &users, After(token), Reverse())
+	require.Equal(t, ErrNotFound, err)
+
+	// unique index
+	token, err = db.Token("Slug", &User{ID: 3, Slug: "Johnc"})
+	require.NoError(t, err)
+
+	err = db.AllByIndex("Slug", &users, After(token))
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, 6, users[0].ID)
+
+	err = db.AllByIndex("Slug", &users, After(token), Reverse())
+	require.NoError(t, err)
+	require.Len(t, users, 4)
+	require.Equal(t, 1, users[0].ID)
+
+	err = db.AllByIndex("Slug", &users, After("!"))
+	require.Equal(t, ErrInvalidToken, err)
+}
@@ -13,7 +13,10 @@ type Tx interface {
 
 // Begin starts a new transaction.
 func (n node) Begin(writable bool) (Node, error) {
-	var err error
+	err := n.ctxErr()
+	if err != nil {
+		return nil, err
+	}
 
 	n.tx, err = n.s.Bolt.Begin(writable)
 	if err != nil {
@@ -0,0 +1,189 @@
+package storm
+
+import (
+	"reflect"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/asdine/storm/v3/index"
+	"github.com/asdine/storm/v3/q"
+	bolt "go.etcd.io/bbolt"
+)
+
+// trigramScore is the score of the plans reading a trigram index. The records it returns
+// must still be matched, it is less selective than a lookup but usually more than a range.
+const trigramScore = 3
+
+// similarityThreshold is the minimum similarity of the records returned by Similar.
+const similarityThreshold = 0.3
+
+// planTrigram returns a plan reading the trigram index of the field matched by the given pattern
+// matcher, if the pattern requires literal substrings of at least three characters.
+func planTrigram(m q.Matcher, cfg *structConfig) *indexPlan {
+	p, ok := q.InspectPattern(m)
+	if !ok {
+		return nil
+	}
+
+	f, ok := cfg.Fields[p.Field]
+	if !ok || f.Index != tagTrigram {
+		return nil
+	}
+
+	re, err := syntax.Parse(p.Regexp.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	var substrings [][]byte
+	for _, lit := range requiredLiterals(re.Simplify()) {
+		if utf8.RuneCountInString(lit) >= 3 {
+			substrings = append(substrings, []byte(strings.ToLower(lit)))
+		}
+	}
+
+	if len(substrings) == 0 {
+		return nil
+	}
+
+	return &indexPlan{
+		field:      p.Field,
+		kind:       tagTrigram,
+		substrings: substrings,
+	}
+}
+
+// requiredLiterals returns literal strings contained by all the strings matched by the given regular expression.
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var list []string
+		var lit []rune
+		for _, sub := range re.Sub {
+			// consecutive literals form a single one
+			if sub.Op == syntax.OpLiteral {
+				lit = append(lit, sub.Rune...)
+				continue
+			}
+
+			if len(lit) > 0 {
+				list = append(list, string(lit))
+				lit = nil
+			}
+			list = append(list, requiredLiterals(sub)...)
+		}
+
+		if len(lit) > 0 {
+			list = append(list, string(lit))
+		}
+		return list
+	}
+
+	return nil
+}
+
+// Like returns the records whose given string field matches the given pattern, ignoring case.
+// As in SQL, % matches any sequence of characters and _ matches a single character.
+// If the field is indexed with trigram, only the records containing the substrings
+// of at least three characters of the pattern are read.
+func (n *node) Like(fieldName string, pattern string, to interface{}, options ...func(*index.Options)) error {
+	sink, err := newListSink(n, to)
+	if err != nil {
+		return err
+	}
+
+	if sink.bucketName() == "" {
+		return ErrNoName
+	}
+
+	opts := index.NewOptions()
+	for _, fn := range options {
+		fn(opts)
+	}
+
+	err = checkAfter(opts)
+	if err != nil {
+		return err
+	}
+
+	query := newQuery(n, q.Like(fieldName, pattern))
+	query.Skip(opts.Skip).Limit(opts.Limit)
+	query.after = opts.After
+
+	if opts.Reverse {
+		query.Reverse()
+	}
+
+	err = n.readTx(func(tx *bolt.Tx) error {
+		return query.query(tx, sink)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return sink.flush()
+}
+
+// Similar returns the records whose given field is similar to the given value, ignoring case,
+// the most similar first. The field must be indexed with trigram. The similarity of two values
+// is the ratio of their sequences of three characters they share, records with less than 30% are ignored.
+// The Skip, Limit and Reverse options apply to the sorted records.
+func (n *node) Similar(fieldName string, value string, to interface{}, options ...func(*index.Options)) error {
+	sink, err := newListSink(n, to)
+	if err != nil {
+		return err
+	}
+
+	bucketName := sink.bucketName()
+	if bucketName == "" {
+		return ErrNoName
+	}
+
+	opts := index.NewOptions()
+	for _, fn := range options {
+		fn(opts)
+	}
+
+	ref := reflect.Indirect(reflect.New(sink.elemType))
+	cfg, err := extractSingleField(&ref, fieldName)
+	if err != nil {
+		return err
+	}
+
+	if field, ok := cfg.Fields[fieldName]; !ok || field.Index != tagTrigram {
+		return ErrIdxNotFound
+	}
+
+	return n.readTx(func(tx *bolt.Tx) error {
+		bucket := n.GetBucket(tx, bucketName)
+		if bucket == nil {
+			return ErrNotFound
+		}
+
+		idx, err := getIndex(bucket, tagTrigram, fieldName)
+		if err != nil {
+			if err == index.ErrNotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		list := idx.(*index.TrigramIndex).Similar([]byte(strings.ToLower(value)), similarityThreshold)
+		ids := make([][]byte, len(list))
+		for i := range list {
+			ids[i] = list[i].ID
+		}
+
+		return n.ranked(bucket, ids, sink, opts)
+	})
+}
@@ -0,0 +1,92 @@
+package storm
+
+import (
+	"testing"
+
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+)
+
+type Gadget struct {
+	ID    int    `storm:"increment"`
+	Name  string `storm:"trigram"`
+	Brand string
+}
+
+func gadgetNames(list []Gadget) []string {
+	var names []string
+	for _, g := range list {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+func TestTrigram(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	for _, name := range []string{"Smartphone X", "Phone case", "Headphones", "TV", "Tablet"} {
+		require.NoError(t, db.Save(&Gadget{Name: name, Brand: "Acme"}))
+	}
+
+	var gadgets []Gadget
+	require.NoError(t, db.Like("Name", "%PHONE%", &gadgets))
+	require.Equal(t, []string{"Smartphone X", "Phone case", "Headphones"}, gadgetNames(gadgets))
+
+	require.NoError(t, db.Like("Name", "%phone_", &gadgets, Reverse(), Limit(1)))
+	require.Equal(t, []string{"Headphones"}, gadgetNames(gadgets))
+
+	require.NoError(t, db.Like("Name", "t%", &gadgets))
+	require.Equal(t, []string{"TV", "Tablet"}, gadgetNames(gadgets))
+	require.Equal(t, ErrNotFound, db.Like("Name", "%laptop%", &gadgets))
+
+	// the patterns with literal substrings read the index
+	plan, err := db.Select(q.Like("Name", "%phone%")).Explain(&Gadget{})
+	require.NoError(t, err)
+	require.Equal(t, "Name", plan.Index)
+	require.Equal(t, "trigram", plan.IndexKind)
+	require.False(t, plan.Range)
+	require.Equal(t, 3, plan.ReadRows)
+	require.Len(t, plan.Filters, 1)
+
+	plan, err = db.Select(q.Re("Name", `(?i)head(phone)s?`), q.Eq("Brand", "Acme")).Explain(&Gadget{})
+	require.NoError(t, err)
+	require.Equal(t, "Name", plan.Index)
+	require.Equal(t, 1, plan.ReadRows)
+
+	// the residual pattern is case sensitive
+	require.NoError(t, db.Select(q.Re("Name", "Phone")).Find(&gadgets))
+	require.Equal(t, []string{"Phone case"}, gadgetNames(gadgets))
+
+	for _, re := range []string{"^t", "phone|tab", "(?i)ph?on"} {
+		plan, err = db.Select(q.Re("Name", re)).Explain(&Gadget{})
+		require.NoError(t, err)
+		require.True(t, plan.FullScan(), re)
+	}
+
+	// did you mean
+	require.NoError(t, db.Similar("Name", "smartfone x", &gadgets))
+	require.Equal(t, []string{"Smartphone X"}, gadgetNames(gadgets))
+	require.NoError(t, db.Similar("Name", "tablets", &gadgets))
+	require.Equal(t, []string{"Tablet"}, gadgetNames(gadgets))
+	require.Equal(t, ErrNotFound, db.Similar("Name", "laptop", &gadgets))
+
+	// updated and deleted records are removed from the index
+	require.NoError(t, db.UpdateField(&Gadget{ID: 1}, "Name", "Smartwatch"))
+	require.NoError(t, db.DeleteStruct(&Gadget{ID: 2}))
+	require.NoError(t, db.Like("Name", "%phone%", &gadgets))
+	require.Equal(t, []string{"Headphones"}, gadgetNames(gadgets))
+
+	// trigram indexes can't be used to look records up
+	var gadget Gadget
+	require.NoError(t, db.One("Name", "TV", &gadget))
+	require.Equal(t, 4, gadget.ID)
+
+	require.Equal(t, ErrIdxNotFound, db.Similar("Brand", "acme", &gadgets))
+
+	type Invalid struct {
+		ID    int
+		Count int `storm:"trigram"`
+	}
+	require.Equal(t, ErrBadTrigram, db.Save(&Invalid{ID: 1}))
+}
@@ -1,4 +1,4 @@
 package storm
 
 // Version of Storm
-const Version = "2.0.0"
+const Version = "3.0.0"
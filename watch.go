@@ -0,0 +1,166 @@
+package storm
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// EventType is the type of change described by an Event.
+type EventType int
+
+// Types of events.
+const (
+	// EventInsert is sent when a value is saved under a new key.
+	EventInsert EventType = iota + 1
+
+	// EventUpdate is sent when the value of a key is replaced.
+	EventUpdate
+
+	// EventDelete is sent when a key is deleted.
+	EventDelete
+
+	// EventDrop is sent when a bucket, or one of its parents, is dropped.
+	EventDrop
+)
+
+// An Event describes a change made to a bucket.
+type Event struct {
+	Type EventType
+
+	// Path of the bucket from the root of the database
+	Bucket []string
+
+	// Key of the value, nil for EventDrop
+	ID []byte
+
+	// Raw values before and after the change, nil if there is none
+	Old []byte
+	New []byte
+}
+
+type watcher struct {
+	path    []string
+	handler func(Event)
+}
+
+// watchers registered on a database.
+type watchers struct {
+	mu   sync.RWMutex
+	list []*watcher
+}
+
+func (w *watchers) add(wt *watcher) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.list = append(w.list, wt)
+}
+
+func (w *watchers) remove(wt *watcher) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := range w.list {
+		if w.list[i] == wt {
+			w.list = append(w.list[:i:i], w.list[i+1:]...)
+			return
+		}
+	}
+}
+
+// matching returns the watchers interested in the given event.
+func (w *watchers) matching(e *Event) []*watcher {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var list []*watcher
+	for _, wt := range w.list {
+		if wt.matches(e) {
+			list = append(list, wt)
+		}
+	}
+
+	return list
+}
+
+func (wt *watcher) matches(e *Event) bool {
+	if len(wt.path) < len(e.Bucket) || (e.Type != EventDrop && len(wt.path) != len(e.Bucket)) {
+		return false
+	}
+
+	for i := range e.Bucket {
+		if wt.path[i] != e.Bucket[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Watch calls the handler with the changes made to the given bucket, or to the bucket of the given type,
+// once the transaction that made them is committed. Handlers are called in the goroutine committing
+// the transaction, in the order of the changes, and must return quickly.
+// It returns a function that stops the watch.
+func (n *node) Watch(bucketOrType interface{}, handler func(Event)) (func(), error) {
+	var bucketName string
+
+	v := reflect.ValueOf(bucketOrType)
+	if v.Kind() == reflect.String {
+		bucketName = v.String()
+	} else {
+		cfg, err := extract(&v)
+		if err != nil {
+			return nil, err
+		}
+
+		bucketName = cfg.Name
+	}
+
+	wt := watcher{
+		path:    append(n.rootBucket[:len(n.rootBucket):len(n.rootBucket)], bucketName),
+		handler: handler,
+	}
+	n.s.watchers.add(&wt)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			n.s.watchers.remove(&wt)
+		})
+	}, nil
+}
+
+// putEvent returns the event describing the replacement of the old value of a key by a new one.
+func putEvent(id, old, raw []byte) Event {
+	if old == nil {
+		return Event{Type: EventInsert, ID: id, New: raw}
+	}
+
+	return Event{Type: EventUpdate, ID: id, Old: old, New: raw}
+}
+
+// emit sends the event to the watchers of the given bucket when the transaction is committed.
+// Updates that don't change the stored value are ignored.
+func (n *node) emit(tx *bolt.Tx, bucketName string, e Event) {
+	e.Bucket = append(n.rootBucket[:len(n.rootBucket):len(n.rootBucket)], bucketName)
+
+	if len(n.s.watchers.matching(&e)) == 0 || (e.Type == EventUpdate && bytes.Equal(e.Old, e.New)) {
+		return
+	}
+
+	// the values are only valid during the transaction
+	for _, b := range []*[]byte{&e.ID, &e.Old, &e.New} {
+		if *b != nil {
+			*b = append([]byte{}, *b...)
+		}
+	}
+
+	tx.OnCommit(func() {
+		for _, wt := range n.s.watchers.matching(&e) {
+			wt.handler(e)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package storm
+
+import (
+	"testing"
+
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	var events []Event
+	stop, err := db.Watch(&User{}, func(e Event) {
+		events = append(events, e)
+	})
+	require.NoError(t, err)
+
+	id := func(i int) []byte {
+		raw, err := toBytes(i, db.Codec())
+		require.NoError(t, err)
+		return raw
+	}
+
+	require.NoError(t, db.Save(&User{ID: 1, Name: "John"}))
+	require.Len(t, events, 1)
+	require.Equal(t, EventInsert, events[0].Type)
+	require.Equal(t, []string{"User"}, events[0].Bucket)
+	require.Equal(t, id(1), events[0].ID)
+	require.Nil(t, events[0].Old)
+
+	var user User
+	require.NoError(t, db.Codec().Unmarshal(events[0].New, &user))
+	require.Equal(t, "John", user.Name)
+
+	require.NoError(t, db.UpdateField(&User{ID: 1}, "Name", "Jack"))
+	require.Len(t, events, 2)
+	require.Equal(t, EventUpdate, events[1].Type)
+	require.Equal(t, events[0].New, events[1].Old)
+	require.NoError(t, db.Codec().Unmarshal(events[1].New, &user))
+	require.Equal(t, "Jack", user.Name)
+
+	// unchanged records are ignored
+	require.NoError(t, db.Save(&user))
+	require.Len(t, events, 2)
+
+	require.NoError(t, db.DeleteStruct(&User{ID: 1}))
+	require.Len(t, events, 3)
+	require.Equal(t, EventDelete, events[2].Type)
+	require.Equal(t, events[1].New, events[2].Old)
+	require.Nil(t, events[2].New)
+
+	// events are sent once the transaction is committed
+	events = nil
+	tx, err := db.Begin(true)
+	require.NoError(t, err)
+	require.NoError(t, tx.Save(&User{ID: 2, Name: "John"}))
+	require.NoError(t, tx.Save(&User{ID: 3, Name: "John"}))
+	require.Empty(t, events)
+	require.NoError(t, tx.Commit())
+	require.Len(t, events, 2)
+	require.Equal(t, id(2), events[0].ID)
+	require.Equal(t, id(3), events[1].ID)
+
+	events = nil
+	tx, err = db.Begin(true)
+	require.NoError(t, err)
+	require.NoError(t, tx.Save(&User{ID: 4, Name: "John"}))
+	require.NoError(t, tx.Rollback())
+	require.Empty(t, events)
+
+	require.NoError(t, db.Select(q.Eq("Name", "John")).Delete(new(User)))
+	require.Len(t, events, 2)
+	require.Equal(t, EventDelete, events[0].Type)
+	require.Equal(t, EventDelete, events[1].Type)
+
+	// other buckets are not watched
+	events = nil
+	require.NoError(t, db.Save(&SimpleUser{ID: 1, Name: "John"}))
+	require.NoError(t, db.From("a").Save(&User{ID: 1, Name: "John"}))
+	require.Empty(t, events)
+
+	require.NoError(t, db.Drop(&User{ID: 1}))
+	require.Len(t, events, 1)
+	require.Equal(t, EventDrop, events[0].Type)
+	require.Nil(t, events[0].ID)
+
+	stop()
+	stop()
+	require.NoError(t, db.Save(&User{ID: 1, Name: "John"}))
+	require.Len(t, events, 1)
+
+	_, err = db.Watch(10, func(Event) {})
+	require.Equal(t, ErrBadType, err)
+}
+
+func TestWatchKeyValue(t *testing.T) {
+	db, cleanup := createDB(t)
+	defer cleanup()
+
+	var events []Event
+	_, err := db.From("a").Watch("config", func(e Event) {
+		events = append(events, e)
+	})
+	require.NoError(t, err)
+
+	n := db.From("a")
+	require.NoError(t, n.Set("config", "theme", "dark"))
+	require.NoError(t, n.SetBytes("config", "theme", []byte("light")))
+	require.NoError(t, n.Delete("config", "theme"))
+	require.NoError(t, n.Delete("config", "theme"))
+	require.Len(t, events, 3)
+
+	require.Equal(t, EventInsert, events[0].Type)
+	require.Equal(t, []string{"a", "config"}, events[0].Bucket)
+	require.Equal(t, []byte("theme"), events[0].ID)
+
+	require.Equal(t, EventUpdate, events[1].Type)
+	require.Equal(t, []byte("light"), events[1].New)
+
+	require.Equal(t, EventDelete, events[2].Type)
+	require.Equal(t, []byte("light"), events[2].Old)
+
+	// dropping a parent bucket drops the watched bucket
+	require.NoError(t, db.Set("config", "theme", "dark"))
+	require.NoError(t, db.Drop("a"))
+	require.Len(t, events, 4)
+	require.Equal(t, EventDrop, events[3].Type)
+	require.Equal(t, []string{"a"}, events[3].Bucket)
+}